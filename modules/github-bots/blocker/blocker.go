@@ -21,7 +21,10 @@ func New() sdk.Bot {
 	handler := sdk.PullRequestHandler(func(ctx context.Context, pre github.PullRequestEvent) error {
 		log := clog.FromContext(ctx)
 
-		cli := sdk.NewGitHubClient(ctx, *pre.Repo.Owner.Login, *pre.Repo.Name, name)
+		cli, err := sdk.NewGitHubClient(ctx, *pre.Repo.Owner.Login, *pre.Repo.Name, name)
+		if err != nil {
+			return err
+		}
 		defer cli.Close(ctx)
 
 		owner, repo := *pre.Repo.Owner.Login, *pre.Repo.Name
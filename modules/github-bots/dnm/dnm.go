@@ -14,7 +14,10 @@ func New() sdk.Bot {
 	name := "dnm"
 
 	handler := sdk.PullRequestHandler(func(ctx context.Context, pre github.PullRequestEvent) error {
-		cli := sdk.NewGitHubClient(ctx, *pre.Repo.Owner.Login, *pre.Repo.Name, name)
+		cli, err := sdk.NewGitHubClient(ctx, *pre.Repo.Owner.Login, *pre.Repo.Name, name)
+		if err != nil {
+			return err
+		}
 		defer cli.Close(ctx)
 
 		// If the title contains some variant of "dnm" and the PR doesn't have the label, add it -- this will no-op if it already has it.
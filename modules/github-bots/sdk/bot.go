@@ -89,111 +89,118 @@ func Serve(b Bot) {
 	}
 
 	logger.Infof("starting bot %s receiver on port %d", b.Name, env.Port)
-	if err := c.StartReceiver(ctx, func(ctx context.Context, event cloudevents.Event) error {
-		clog.FromContext(ctx).With("event", event).Debugf("received event")
+	if err := c.StartReceiver(ctx, b.Receive); err != nil {
+		clog.Fatalf("failed to start event receiver, %v", err)
+	}
+}
+
+// Receive dispatches a single event to the bot's registered handler for its
+// type, and can be passed to a CloudEvents client's StartReceiver.
+func (b Bot) Receive(ctx context.Context, event cloudevents.Event) error {
+	ctx = withEventLogger(ctx, event)
+	logger := clog.FromContext(ctx)
+
+	clog.FromContext(ctx).With("event", event).Debugf("received event")
+
+	defer func() {
+		if err := recover(); err != nil {
+			clog.Errorf("panic: %s", debug.Stack())
+		}
+	}()
+
+	logger.Info("handling event", "type", event.Type())
 
-		defer func() {
-			if err := recover(); err != nil {
-				clog.Errorf("panic: %s", debug.Stack())
+	// dispatch event to n handlers
+	if handler, ok := b.Handlers[EventType(event.Type())]; ok {
+		// loop over all event headers and add them to the context so they can be used by the handlers
+		for k, v := range event.Context.GetExtensions() {
+			ctx = context.WithValue(ctx, contextKey(k), v)
+		}
+
+		// add existing event attributes to context so they can be used by the handlers
+		ctx = context.WithValue(ctx, ContextKeyAttributes, event.Extensions())
+		ctx = context.WithValue(ctx, ContextKeyType, event.Type())
+
+		switch h := handler.(type) {
+		case WorkflowRunArtifactHandler:
+			logger.Debug("handling workflow run artifact event")
+
+			var wre schemas.Wrapper[github.WorkflowRunEvent]
+			if err := event.DataAs(&wre); err != nil {
+				logger.Errorf("failed to unmarshal workflow run event: %v", err)
+				return err
 			}
-		}()
 
-		logger.Info("handling event", "type", event.Type())
+			if err := h(ctx, wre.Body); err != nil {
+				logger.Errorf("failed to handle workflow run event: %v", err)
+				return err
+			}
+			return nil
+
+		case WorkflowRunHandler:
+			logger.Debug("handling workflow run event")
 
-		// dispatch event to n handlers
-		if handler, ok := b.Handlers[EventType(event.Type())]; ok {
-			// loop over all event headers and add them to the context so they can be used by the handlers
-			for k, v := range event.Context.GetExtensions() {
-				ctx = context.WithValue(ctx, contextKey(k), v)
+			var wre schemas.Wrapper[github.WorkflowRunEvent]
+			if err := event.DataAs(&wre); err != nil {
+				logger.Errorf("failed to unmarshal workflow run event: %v", err)
+				return err
 			}
 
-			// add existing event attributes to context so they can be used by the handlers
-			ctx = context.WithValue(ctx, ContextKeyAttributes, event.Extensions())
-			ctx = context.WithValue(ctx, ContextKeyType, event.Type())
-
-			switch h := handler.(type) {
-			case WorkflowRunArtifactHandler:
-				logger.Debug("handling workflow run artifact event")
-
-				var wre schemas.Wrapper[github.WorkflowRunEvent]
-				if err := event.DataAs(&wre); err != nil {
-					logger.Errorf("failed to unmarshal workflow run event: %v", err)
-					return err
-				}
-
-				if err := h(ctx, wre.Body); err != nil {
-					logger.Errorf("failed to handle workflow run event: %v", err)
-					return err
-				}
-				return nil
-
-			case WorkflowRunHandler:
-				logger.Debug("handling workflow run event")
-
-				var wre schemas.Wrapper[github.WorkflowRunEvent]
-				if err := event.DataAs(&wre); err != nil {
-					logger.Errorf("failed to unmarshal workflow run event: %v", err)
-					return err
-				}
-
-				if err := h(ctx, wre.Body); err != nil {
-					logger.Errorf("failed to handle workflow run event: %v", err)
-					return err
-				}
-				return nil
-
-			case WorkflowRunLogsHandler:
-				logger.Debug("handling workflow run logs event")
-
-				var wre schemas.Wrapper[github.WorkflowRunEvent]
-				if err := event.DataAs(&wre); err != nil {
-					logger.Errorf("failed to unmarshal workflow run with logs event: %v", err)
-					return err
-				}
-
-				if err := h(ctx, wre.Body); err != nil {
-					logger.Errorf("failed to handle workflow run with logs event: %v", err)
-					return err
-				}
-				return nil
-
-			case PullRequestHandler:
-				logger.Debug("handling pull request event")
-
-				var pre schemas.Wrapper[github.PullRequestEvent]
-				if err := event.DataAs(&pre); err != nil {
-					logger.Errorf("failed to unmarshal pull request event: %v", err)
-					return err
-				}
-
-				if err := h(ctx, pre.Body); err != nil {
-					logger.Errorf("failed to handle pull request event: %v", err)
-					return err
-				}
-				return nil
-
-			case IssueCommentHandler:
-				logger.Debug("handling issue comment event")
-
-				var ice schemas.Wrapper[github.IssueCommentEvent]
-				if err := event.DataAs(&ice); err != nil {
-					logger.Errorf("failed to unmarshal issue comment event: %v", err)
-					return err
-				}
-
-				if err := h(ctx, ice.Body); err != nil {
-					logger.Errorf("failed to handle issue comment event: %v", err)
-					return err
-				}
-				return nil
+			if err := h(ctx, wre.Body); err != nil {
+				logger.Errorf("failed to handle workflow run event: %v", err)
+				return err
 			}
-		}
+			return nil
 
-		clog.FromContext(ctx).With("event", event).Debugf("ignoring event")
-		return nil
-	}); err != nil {
-		clog.Fatalf("failed to start event receiver, %v", err)
+		case WorkflowRunLogsHandler:
+			logger.Debug("handling workflow run logs event")
+
+			var wre schemas.Wrapper[github.WorkflowRunEvent]
+			if err := event.DataAs(&wre); err != nil {
+				logger.Errorf("failed to unmarshal workflow run with logs event: %v", err)
+				return err
+			}
+
+			if err := h(ctx, wre.Body); err != nil {
+				logger.Errorf("failed to handle workflow run with logs event: %v", err)
+				return err
+			}
+			return nil
+
+		case PullRequestHandler:
+			logger.Debug("handling pull request event")
+
+			var pre schemas.Wrapper[github.PullRequestEvent]
+			if err := event.DataAs(&pre); err != nil {
+				logger.Errorf("failed to unmarshal pull request event: %v", err)
+				return err
+			}
+
+			if err := h(ctx, pre.Body); err != nil {
+				logger.Errorf("failed to handle pull request event: %v", err)
+				return err
+			}
+			return nil
+
+		case IssueCommentHandler:
+			logger.Debug("handling issue comment event")
+
+			var ice schemas.Wrapper[github.IssueCommentEvent]
+			if err := event.DataAs(&ice); err != nil {
+				logger.Errorf("failed to unmarshal issue comment event: %v", err)
+				return err
+			}
+
+			if err := h(ctx, ice.Body); err != nil {
+				logger.Errorf("failed to handle issue comment event: %v", err)
+				return err
+			}
+			return nil
+		}
 	}
+
+	clog.FromContext(ctx).With("event", event).Debugf("ignoring event")
+	return nil
 }
 
 // AttributeFromContext retrieves an attribute by key from the context.
@@ -0,0 +1,361 @@
+// Package check helps bots build GitHub check runs.
+package check
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	// MaxOutputLength is the maximum length GitHub accepts for check run
+	// output text.
+	MaxOutputLength = 65535
+
+	// MaxActions is the maximum number of actions GitHub accepts on a
+	// check run, and MaxActionLabelLength, MaxActionDescriptionLength and
+	// MaxActionIdentifierLength are the maximum lengths, in characters, of
+	// their fields.
+	MaxActions                 = 3
+	MaxActionLabelLength       = 20
+	MaxActionDescriptionLength = 40
+	MaxActionIdentifierLength  = 20
+
+	truncationMessage = "\n\n_This output was truncated because it exceeded GitHub's maximum length._"
+)
+
+// Status is the status of a check run.
+type Status string
+
+const (
+	// StatusQueued is for checks that have been accepted but not started.
+	StatusQueued     Status = "queued"
+	StatusInProgress Status = "in_progress"
+	StatusCompleted  Status = "completed"
+)
+
+// Conclusion is the conclusion of a completed check run.
+type Conclusion string
+
+const (
+	ConclusionActionRequired Conclusion = "action_required"
+	ConclusionCancelled      Conclusion = "cancelled"
+	ConclusionFailure        Conclusion = "failure"
+	ConclusionNeutral        Conclusion = "neutral"
+	ConclusionSuccess        Conclusion = "success"
+	ConclusionSkipped        Conclusion = "skipped"
+	ConclusionTimedOut       Conclusion = "timed_out"
+)
+
+// ConclusionEmoji and StatusEmoji are the emoji that prefix the summaries of
+// Builders with EmojiSummary set: ConclusionEmoji for completed check runs,
+// and StatusEmoji otherwise. Callers can change them to customize the
+// prefixes, and summaries get no prefix for missing entries.
+var (
+	ConclusionEmoji = map[Conclusion]string{
+		ConclusionActionRequired: "⚠️",
+		ConclusionCancelled:      "🚫",
+		ConclusionFailure:        "❌",
+		ConclusionNeutral:        "➖",
+		ConclusionSuccess:        "✅",
+		ConclusionSkipped:        "⏭️",
+		ConclusionTimedOut:       "⏱️",
+	}
+	StatusEmoji = map[Status]string{
+		StatusQueued:     "⏳",
+		StatusInProgress: "🔄",
+	}
+)
+
+// Errors reported by Validate, wrapped with details.
+var (
+	ErrInvalidStatus     = errors.New("invalid check run status")
+	ErrMissingConclusion = errors.New("completed check run has no conclusion")
+	ErrInvalidConclusion = errors.New("invalid check run conclusion")
+	ErrOutputTooLong     = errors.New("check run output exceeds the maximum length")
+	ErrTooManyActions    = errors.New("check run has more than the maximum number of actions")
+	ErrInvalidAction     = errors.New("invalid check run action")
+)
+
+// Builder accumulates the state and markdown output of a check run.
+type Builder struct {
+	name, headSHA string
+
+	// Status is the status of the check run. It starts as StatusQueued.
+	Status Status
+	// Conclusion is only reported once Status is StatusCompleted.
+	Conclusion Conclusion
+	// Title is the title of the check run output. It defaults to the name
+	// of the check run, which stays stable for matching check runs, e.g. by
+	// Upsert, while Title can be friendlier.
+	Title string
+	// Summary is the summary of the check run output.
+	Summary string
+	// EmojiSummary prefixes the reported summary with an emoji for the
+	// conclusion or status, per ConclusionEmoji and StatusEmoji.
+	EmojiSummary bool
+	// CodeBlock renders the whole output as a fenced code block, e.g. for
+	// build logs, which stays terminated when the output is truncated.
+	// CodeBlockLanguage is the optional language of the block, for syntax
+	// highlighting.
+	CodeBlock         bool
+	CodeBlockLanguage string
+
+	md        strings.Builder
+	maxLength int
+	actions   []*github.CheckRunAction
+}
+
+// NewBuilder returns a Builder for the check run named name on the commit
+// headSHA.
+func NewBuilder(name, headSHA string) *Builder {
+	return &Builder{
+		name:      name,
+		headSHA:   headSHA,
+		Status:    StatusQueued,
+		maxLength: MaxOutputLength,
+	}
+}
+
+// Writef appends the formatted string to the check output, followed by a
+// newline.
+func (b *Builder) Writef(format string, args ...any) {
+	fmt.Fprintf(&b.md, format, args...)
+	b.md.WriteString("\n")
+}
+
+// WriteString appends s to the check output verbatim, followed by a newline.
+// Unlike Writef, s isn't interpreted as a format string.
+func (b *Builder) WriteString(s string) {
+	b.md.WriteString(s)
+	b.md.WriteString("\n")
+}
+
+// Append is like WriteString, for content that is already a byte slice.
+func (b *Builder) Append(p []byte) {
+	b.md.Write(p)
+	b.md.WriteString("\n")
+}
+
+// Write appends p to the check output verbatim, without adding a newline,
+// so that e.g. the output of a subprocess can be copied as it is produced,
+// partial lines included. It implements io.Writer, and never fails.
+func (b *Builder) Write(p []byte) (int, error) {
+	return b.md.Write(p)
+}
+
+// WriteLine appends s to the check output followed by exactly one newline,
+// replacing any trailing newlines of s.
+func (b *Builder) WriteLine(s string) {
+	b.md.WriteString(strings.TrimRight(s, "\n"))
+	b.md.WriteString("\n")
+}
+
+// detailsEndTag matches the closing tags that end a <details> block, which
+// HTML matches case-insensitively, and allows whitespace and attributes in.
+var detailsEndTag = regexp.MustCompile(`(?i)</details\b[^>]*>?`)
+
+// WriteDetails appends a collapsed <details> block with the given summary,
+// which expands to body. The summary is HTML-escaped, and closing tags in
+// body are escaped so that they can't end the block early.
+func (b *Builder) WriteDetails(summary, body string) {
+	body = strings.TrimRight(body, "\n")
+	body = detailsEndTag.ReplaceAllStringFunc(body, html.EscapeString)
+	b.Writef("<details><summary>%s</summary>\n\n%s\n\n</details>", html.EscapeString(summary), body)
+}
+
+// AddAction adds a button to the check run, which sends a check_run event
+// with the action "requested_action" and the given identifier when clicked.
+// GitHub allows at most MaxActions actions, and only the first are reported.
+// Labels and descriptions that are too long are truncated, but identifiers
+// are reported as is, as truncating them could make them ambiguous. Validate
+// reports all of these.
+func (b *Builder) AddAction(label, description, identifier string) {
+	b.actions = append(b.actions, &github.CheckRunAction{
+		Label:       label,
+		Description: description,
+		Identifier:  identifier,
+	})
+}
+
+// reportedActions returns the actions to report, within GitHub's limits.
+func (b *Builder) reportedActions() []*github.CheckRunAction {
+	if len(b.actions) == 0 {
+		return nil
+	}
+	actions := make([]*github.CheckRunAction, 0, min(len(b.actions), MaxActions))
+	for _, a := range b.actions[:min(len(b.actions), MaxActions)] {
+		actions = append(actions, &github.CheckRunAction{
+			Label:       truncateRunes(a.Label, MaxActionLabelLength),
+			Description: truncateRunes(a.Description, MaxActionDescriptionLength),
+			Identifier:  a.Identifier,
+		})
+	}
+	return actions
+}
+
+// truncateRunes returns s cut to at most n characters.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// parts returns the check output, and the fences wrapping it if CodeBlock
+// is set. The fence is longer than any run of backticks in the output, so
+// that the output can't end the block early.
+func (b *Builder) parts() (open, content, end string) {
+	content = b.md.String()
+	if !b.CodeBlock || content == "" {
+		return "", content, ""
+	}
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + b.CodeBlockLanguage + "\n", strings.TrimSuffix(content, "\n"), "\n" + fence
+}
+
+// text returns the check output, truncated to GitHub's maximum length. In a
+// code block, the truncation message goes inside the fences.
+func (b *Builder) text() string {
+	open, content, end := b.parts()
+	return open + truncate(content, b.maxLength-len(open)-len(end)) + end
+}
+
+// truncate returns content cut to at most n bytes, including the truncation
+// message if it is cut.
+func truncate(content string, n int) string {
+	if len(content) <= n {
+		return content
+	}
+
+	// Leave room for the truncation message, and don't cut a character in half.
+	n = max(0, n-len(truncationMessage))
+	for n > 0 && !utf8.RuneStart(content[n]) {
+		n--
+	}
+	return content[:n] + truncationMessage
+}
+
+// summary returns the summary to report, with an emoji prefix if
+// EmojiSummary is set.
+func (b *Builder) summary() string {
+	if !b.EmojiSummary {
+		return b.Summary
+	}
+	var emoji string
+	if b.Status == StatusCompleted {
+		emoji = ConclusionEmoji[b.Conclusion]
+	} else {
+		emoji = StatusEmoji[b.Status]
+	}
+	if emoji == "" {
+		return b.Summary
+	}
+	return strings.TrimSpace(emoji + " " + b.Summary)
+}
+
+// title returns the output title, which defaults to the check run name.
+func (b *Builder) title() string {
+	if b.Title == "" {
+		return b.name
+	}
+	return b.Title
+}
+
+func (b *Builder) output() *github.CheckRunOutput {
+	return &github.CheckRunOutput{
+		Title:   github.String(b.title()),
+		Summary: github.String(b.summary()),
+		Text:    github.String(b.text()),
+	}
+}
+
+// conclusion returns the conclusion to report, which GitHub only accepts
+// for completed check runs.
+func (b *Builder) conclusion() *string {
+	if b.Status != StatusCompleted || b.Conclusion == "" {
+		return nil
+	}
+	return github.String(string(b.Conclusion))
+}
+
+// Validate reports misuse of the Builder that CheckRunCreate and
+// CheckRunUpdate otherwise paper over, such as a completed check run without
+// a conclusion, or output or actions that will be truncated. Errors can be
+// checked with errors.Is.
+func (b *Builder) Validate() error {
+	var errs []error
+	switch b.Status {
+	case StatusQueued, StatusInProgress, StatusCompleted:
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", ErrInvalidStatus, b.Status))
+	}
+	switch b.Conclusion {
+	case "":
+		if b.Status == StatusCompleted {
+			errs = append(errs, ErrMissingConclusion)
+		}
+	case ConclusionActionRequired, ConclusionCancelled, ConclusionFailure, ConclusionNeutral,
+		ConclusionSuccess, ConclusionSkipped, ConclusionTimedOut:
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", ErrInvalidConclusion, b.Conclusion))
+	}
+	open, content, end := b.parts()
+	if n := len(open) + len(content) + len(end); n > b.maxLength {
+		errs = append(errs, fmt.Errorf("%w: %d > %d", ErrOutputTooLong, n, b.maxLength))
+	}
+	if n := len(b.actions); n > MaxActions {
+		errs = append(errs, fmt.Errorf("%w: %d > %d", ErrTooManyActions, n, MaxActions))
+	}
+	for _, a := range b.actions {
+		switch {
+		case a.Label == "" || a.Description == "" || a.Identifier == "":
+			errs = append(errs, fmt.Errorf("%w: %q has an empty field", ErrInvalidAction, a.Label))
+		case utf8.RuneCountInString(a.Label) > MaxActionLabelLength:
+			errs = append(errs, fmt.Errorf("%w: label %q is longer than %d characters", ErrInvalidAction, a.Label, MaxActionLabelLength))
+		case utf8.RuneCountInString(a.Description) > MaxActionDescriptionLength:
+			errs = append(errs, fmt.Errorf("%w: description of %q is longer than %d characters", ErrInvalidAction, a.Label, MaxActionDescriptionLength))
+		case utf8.RuneCountInString(a.Identifier) > MaxActionIdentifierLength:
+			errs = append(errs, fmt.Errorf("%w: identifier %q is longer than %d characters", ErrInvalidAction, a.Identifier, MaxActionIdentifierLength))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckRunCreate returns the options to create the check run.
+func (b *Builder) CheckRunCreate() *github.CreateCheckRunOptions {
+	return &github.CreateCheckRunOptions{
+		Name:       b.name,
+		HeadSHA:    b.headSHA,
+		Status:     github.String(string(b.Status)),
+		Conclusion: b.conclusion(),
+		Output:     b.output(),
+		Actions:    b.reportedActions(),
+	}
+}
+
+// CheckRunUpdate returns the options to update the check run to the current
+// state of the Builder.
+func (b *Builder) CheckRunUpdate() *github.UpdateCheckRunOptions {
+	return &github.UpdateCheckRunOptions{
+		Name:       b.name,
+		Status:     github.String(string(b.Status)),
+		Conclusion: b.conclusion(),
+		Output:     b.output(),
+		Actions:    b.reportedActions(),
+	}
+}
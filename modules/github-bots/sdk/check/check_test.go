@@ -0,0 +1,390 @@
+package check
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v61/github"
+)
+
+func TestWritef(t *testing.T) {
+	b := NewBuilder("lint", "abc123")
+	b.Writef("found %d issues", 2)
+	b.Writef("done")
+
+	if got, want := b.text(), "found 2 issues\ndone\n"; got != want {
+		t.Errorf("text() = %q, wanted %q", got, want)
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	b := NewBuilder("lint", "abc123")
+	b.WriteString("100% of %s passed")
+	b.Append([]byte("%d failures"))
+
+	if got, want := b.text(), "100% of %s passed\n%d failures\n"; got != want {
+		t.Errorf("text() = %q, wanted %q", got, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	b := NewBuilder("build", "abc123")
+	var w io.Writer = b
+	for _, chunk := range []string{"comp", "iling...", " done\nlin", "king", "..."} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+	}
+	b.WriteLine(" done")
+	b.WriteLine("tests passed\n\n")
+	b.WriteLine("")
+
+	if got, want := b.text(), "compiling... done\nlinking... done\ntests passed\n\n"; got != want {
+		t.Errorf("text() = %q, wanted %q", got, want)
+	}
+}
+
+func TestWriteDetails(t *testing.T) {
+	b := NewBuilder("build", "abc123")
+	b.WriteDetails("Logs for <step>", "line 1\n</details>line 2\n")
+
+	want := "<details><summary>Logs for &lt;step&gt;</summary>\n\nline 1\n&lt;/details&gt;line 2\n\n</details>\n"
+	if got := b.text(); got != want {
+		t.Errorf("text() = %q, wanted %q", got, want)
+	}
+}
+
+func TestWriteDetailsEscaping(t *testing.T) {
+	for _, tt := range []struct {
+		body, want string
+	}{
+		{"</DETAILS>", "&lt;/DETAILS&gt;"},
+		{"</details >", "&lt;/details &gt;"},
+		{"</Details\n>", "&lt;/Details\n&gt;"},
+		{"</detailsx> <details>", "</detailsx> <details>"},
+	} {
+		b := NewBuilder("build", "abc123")
+		b.WriteDetails("Logs", tt.body)
+
+		want := "<details><summary>Logs</summary>\n\n" + tt.want + "\n\n</details>\n"
+		if got := b.text(); got != want {
+			t.Errorf("WriteDetails(%q): text() = %q, wanted %q", tt.body, got, want)
+		}
+	}
+}
+
+func TestWriteDetailsTruncation(t *testing.T) {
+	b := NewBuilder("build", "abc123")
+	b.maxLength = 200
+	b.WriteDetails("Logs", strings.Repeat("verbose output\n", 100))
+
+	got := b.text()
+	if len(got) > 200 {
+		t.Errorf("len(text()) = %d, wanted <= 200", len(got))
+	}
+	if !strings.HasPrefix(got, "<details><summary>Logs</summary>") || !strings.HasSuffix(got, truncationMessage) {
+		t.Errorf("text() = %q, wanted details truncated", got)
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	b := NewBuilder("lint", "abc123")
+	b.maxLength = 200
+	for range 100 {
+		b.Writef("line of output é")
+	}
+
+	got := *b.CheckRunUpdate().Output.Text
+	if len(got) > 200 {
+		t.Errorf("len(Text) = %d, wanted <= 200", len(got))
+	}
+	if !strings.HasSuffix(got, truncationMessage) {
+		t.Errorf("Text = %q, wanted truncation message suffix", got)
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	b := NewBuilder("build", "abc123")
+	b.CodeBlock = true
+	b.CodeBlockLanguage = "console"
+	b.Writef("$ make")
+	b.Writef("```not a fence")
+
+	if got, want := b.text(), "````console\n$ make\n```not a fence\n````"; got != want {
+		t.Errorf("text() = %q, wanted %q", got, want)
+	}
+}
+
+func TestCodeBlockTruncation(t *testing.T) {
+	for _, maxLength := range []int{100, 150, 200} {
+		b := NewBuilder("build", "abc123")
+		b.CodeBlock = true
+		b.CodeBlockLanguage = "go"
+		b.maxLength = maxLength
+		for range 100 {
+			b.Writef("line of output é")
+		}
+
+		got := b.text()
+		if len(got) > maxLength {
+			t.Errorf("len(text()) = %d, wanted <= %d", len(got), maxLength)
+		}
+		if !strings.HasPrefix(got, "```go\n") || !strings.HasSuffix(got, truncationMessage+"\n```") {
+			t.Errorf("text() = %q, wanted truncation message inside the fence", got)
+		}
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	b := NewBuilder("lint", "abc123")
+	b.Summary = "Waiting for a worker"
+
+	if diff := cmp.Diff(&github.CreateCheckRunOptions{
+		Name:    "lint",
+		HeadSHA: "abc123",
+		Status:  github.String("queued"),
+		Output: &github.CheckRunOutput{
+			Title:   github.String("lint"),
+			Summary: github.String("Waiting for a worker"),
+			Text:    github.String(""),
+		},
+	}, b.CheckRunCreate()); diff != "" {
+		t.Errorf("CheckRunCreate() (-want +got): %s", diff)
+	}
+
+	b.Status = StatusInProgress
+	b.Summary = "Linting"
+	// A conclusion isn't reported before the check completes.
+	b.Conclusion = ConclusionSuccess
+	if diff := cmp.Diff(&github.UpdateCheckRunOptions{
+		Name:   "lint",
+		Status: github.String("in_progress"),
+		Output: &github.CheckRunOutput{
+			Title:   github.String("lint"),
+			Summary: github.String("Linting"),
+			Text:    github.String(""),
+		},
+	}, b.CheckRunUpdate()); diff != "" {
+		t.Errorf("CheckRunUpdate() (-want +got): %s", diff)
+	}
+
+	b.Status = StatusCompleted
+	b.Summary = "No issues"
+	b.Writef("all good")
+	if diff := cmp.Diff(&github.UpdateCheckRunOptions{
+		Name:       "lint",
+		Status:     github.String("completed"),
+		Conclusion: github.String("success"),
+		Output: &github.CheckRunOutput{
+			Title:   github.String("lint"),
+			Summary: github.String("No issues"),
+			Text:    github.String("all good\n"),
+		},
+	}, b.CheckRunUpdate()); diff != "" {
+		t.Errorf("CheckRunUpdate() (-want +got): %s", diff)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	b := NewBuilder("lint", "abc123")
+	b.Title = "Lint results"
+	b.Summary = "No issues"
+
+	if diff := cmp.Diff(&github.CreateCheckRunOptions{
+		Name:    "lint",
+		HeadSHA: "abc123",
+		Status:  github.String("queued"),
+		Output: &github.CheckRunOutput{
+			Title:   github.String("Lint results"),
+			Summary: github.String("No issues"),
+			Text:    github.String(""),
+		},
+	}, b.CheckRunCreate()); diff != "" {
+		t.Errorf("CheckRunCreate() (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff(&github.UpdateCheckRunOptions{
+		Name:   "lint",
+		Status: github.String("queued"),
+		Output: &github.CheckRunOutput{
+			Title:   github.String("Lint results"),
+			Summary: github.String("No issues"),
+			Text:    github.String(""),
+		},
+	}, b.CheckRunUpdate()); diff != "" {
+		t.Errorf("CheckRunUpdate() (-want +got): %s", diff)
+	}
+}
+
+func TestEmojiSummary(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		disabled   bool
+		status     Status
+		conclusion Conclusion
+		want       string
+	}{
+		{"disabled", true, StatusCompleted, ConclusionSuccess, "All good"},
+		{"success", false, StatusCompleted, ConclusionSuccess, "✅ All good"},
+		{"failure", false, StatusCompleted, ConclusionFailure, "❌ All good"},
+		{"in progress", false, StatusInProgress, ConclusionFailure, "🔄 All good"},
+		{"unknown conclusion", false, StatusCompleted, "passed", "All good"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilder("lint", "abc123")
+			b.Summary = "All good"
+			b.EmojiSummary = !tt.disabled
+			b.Status = tt.status
+			b.Conclusion = tt.conclusion
+
+			if got := *b.CheckRunCreate().Output.Summary; got != tt.want {
+				t.Errorf("CheckRunCreate() Summary = %q, wanted %q", got, tt.want)
+			}
+			if got := *b.CheckRunUpdate().Output.Summary; got != tt.want {
+				t.Errorf("CheckRunUpdate() Summary = %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActions(t *testing.T) {
+	b := NewBuilder("lint", "abc123")
+	b.AddAction("Apply fix", "Push a commit with the suggested fixes", "fix")
+	b.AddAction("Re-run with verbose output", "Run the linter again with verbose logging enabled", "verbose")
+	b.AddAction("Ignore", "Mark these findings as ignored", "ignore")
+	b.AddAction("Extra", "A fourth action, which GitHub rejects", "extra")
+
+	want := []*github.CheckRunAction{{
+		Label:       "Apply fix",
+		Description: "Push a commit with the suggested fixes",
+		Identifier:  "fix",
+	}, {
+		Label:       "Re-run with verbose ",
+		Description: "Run the linter again with verbose loggin",
+		Identifier:  "verbose",
+	}, {
+		Label:       "Ignore",
+		Description: "Mark these findings as ignored",
+		Identifier:  "ignore",
+	}}
+	if diff := cmp.Diff(want, b.CheckRunCreate().Actions); diff != "" {
+		t.Errorf("CheckRunCreate().Actions (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff(want, b.CheckRunUpdate().Actions); diff != "" {
+		t.Errorf("CheckRunUpdate().Actions (-want +got): %s", diff)
+	}
+	if err := b.Validate(); !errors.Is(err, ErrTooManyActions) || !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("Validate() = %v, wanted %v and %v", err, ErrTooManyActions, ErrInvalidAction)
+	}
+}
+
+func TestNoActions(t *testing.T) {
+	b := NewBuilder("lint", "abc123")
+	if got := b.CheckRunCreate().Actions; got != nil {
+		t.Errorf("CheckRunCreate().Actions = %v, wanted nil", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		setup func(b *Builder)
+		want  []error
+	}{{
+		name:  "queued",
+		setup: func(*Builder) {},
+	}, {
+		name: "completed",
+		setup: func(b *Builder) {
+			b.Status = StatusCompleted
+			b.Conclusion = ConclusionFailure
+		},
+	}, {
+		name: "invalid status",
+		setup: func(b *Builder) {
+			b.Status = "running"
+		},
+		want: []error{ErrInvalidStatus},
+	}, {
+		name: "missing conclusion",
+		setup: func(b *Builder) {
+			b.Status = StatusCompleted
+		},
+		want: []error{ErrMissingConclusion},
+	}, {
+		name: "invalid conclusion",
+		setup: func(b *Builder) {
+			b.Status = StatusCompleted
+			b.Conclusion = "passed"
+		},
+		want: []error{ErrInvalidConclusion},
+	}, {
+		name: "output too long",
+		setup: func(b *Builder) {
+			b.maxLength = 10
+			b.Writef("more than ten characters")
+		},
+		want: []error{ErrOutputTooLong},
+	}, {
+		name: "actions",
+		setup: func(b *Builder) {
+			b.AddAction("Apply fix", "Push the suggested fixes", "fix")
+		},
+	}, {
+		name: "too many actions",
+		setup: func(b *Builder) {
+			for range MaxActions + 1 {
+				b.AddAction("Apply fix", "Push the suggested fixes", "fix")
+			}
+		},
+		want: []error{ErrTooManyActions},
+	}, {
+		name: "action label too long",
+		setup: func(b *Builder) {
+			b.AddAction("Apply all of the suggested fixes", "Push the suggested fixes", "fix")
+		},
+		want: []error{ErrInvalidAction},
+	}, {
+		name: "action description too long",
+		setup: func(b *Builder) {
+			b.AddAction("Apply fix", "Push a commit with all of the suggested fixes", "fix")
+		},
+		want: []error{ErrInvalidAction},
+	}, {
+		name: "action identifier too long",
+		setup: func(b *Builder) {
+			b.AddAction("Apply fix", "Push the suggested fixes", "apply-all-suggested-fixes")
+		},
+		want: []error{ErrInvalidAction},
+	}, {
+		name: "action missing identifier",
+		setup: func(b *Builder) {
+			b.AddAction("Apply fix", "Push the suggested fixes", "")
+		},
+		want: []error{ErrInvalidAction},
+	}, {
+		name: "several problems",
+		setup: func(b *Builder) {
+			b.Status = "running"
+			b.Conclusion = "passed"
+		},
+		want: []error{ErrInvalidStatus, ErrInvalidConclusion},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilder("lint", "abc123")
+			tt.setup(b)
+
+			err := b.Validate()
+			if len(tt.want) == 0 && err != nil {
+				t.Errorf("Validate() = %v, wanted nil", err)
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("Validate() = %v, wanted %v", err, want)
+				}
+			}
+		})
+	}
+}
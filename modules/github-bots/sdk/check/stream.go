@@ -0,0 +1,110 @@
+package check
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// Streamer reports the output of a Builder to GitHub as it is written, for
+// long-running jobs. Rather than updating the check run on every write, it
+// coalesces writes and updates the check run at most once per interval, so
+// that chatty jobs don't exhaust the GitHub API rate limit. Writes within the
+// interval of the last update are reported by the next update, at the latest
+// by Complete.
+//
+// The check run is created, or found, with Upsert on the first update.
+type Streamer struct {
+	client      *github.Client
+	owner, repo string
+	interval    time.Duration
+
+	// now is the clock, which tests replace.
+	now func() time.Time
+
+	m     sync.Mutex
+	b     *Builder
+	id    int64
+	last  time.Time
+	dirty bool
+}
+
+// NewStreamer returns a Streamer reporting b to the check run on owner/repo,
+// updating it at most once per interval.
+func NewStreamer(client *github.Client, owner, repo string, b *Builder, interval time.Duration) *Streamer {
+	return &Streamer{
+		client:   client,
+		owner:    owner,
+		repo:     repo,
+		interval: interval,
+		now:      time.Now,
+		b:        b,
+	}
+}
+
+// Writef appends to the output like Builder.Writef, and updates the check
+// run if the interval has passed since the last update.
+func (s *Streamer) Writef(ctx context.Context, format string, args ...any) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.b.Writef(format, args...)
+	return s.write(ctx)
+}
+
+// WriteString appends to the output like Builder.WriteString, and updates
+// the check run if the interval has passed since the last update.
+func (s *Streamer) WriteString(ctx context.Context, str string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.b.WriteString(str)
+	return s.write(ctx)
+}
+
+// Flush updates the check run with any writes that haven't been reported
+// yet, regardless of the interval.
+func (s *Streamer) Flush(ctx context.Context) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.dirty && s.id != 0 {
+		return nil
+	}
+	return s.update(ctx)
+}
+
+// Complete completes the check run with the conclusion and summary, and
+// reports the final output.
+func (s *Streamer) Complete(ctx context.Context, conclusion Conclusion, summary string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.b.Status = StatusCompleted
+	s.b.Conclusion = conclusion
+	s.b.Summary = summary
+	return s.update(ctx)
+}
+
+// write records a write, and updates the check run if it is due. s.m must be
+// held.
+func (s *Streamer) write(ctx context.Context) error {
+	s.dirty = true
+	if !s.last.IsZero() && s.now().Sub(s.last) < s.interval {
+		return nil
+	}
+	return s.update(ctx)
+}
+
+// update reports the Builder to the check run. s.m must be held.
+func (s *Streamer) update(ctx context.Context) error {
+	if s.id == 0 {
+		run, err := Upsert(ctx, s.client, s.owner, s.repo, s.b)
+		if err != nil {
+			return err
+		}
+		s.id = run.GetID()
+	} else if _, err := updateCheckRun(ctx, s.client, s.owner, s.repo, s.id, s.b); err != nil {
+		return err
+	}
+	s.last, s.dirty = s.now(), false
+	return nil
+}
@@ -0,0 +1,83 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamer(t *testing.T) {
+	f := &fakeChecks{}
+	client := newFakeClient(t, f)
+	ctx := context.Background()
+
+	s := NewStreamer(client, "org", "repo", NewBuilder("build", "abc123"), time.Second)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// 100 writes over 2.5s update the check run when it is created, and
+	// then once per second.
+	for i := range 100 {
+		if err := s.Writef(ctx, "step %d", i); err != nil {
+			t.Fatalf("Writef() = %v", err)
+		}
+		now = now.Add(25 * time.Millisecond)
+	}
+	if got, want := len(f.created)+len(f.updated), 3; got != want {
+		t.Errorf("made %d create or update calls, wanted %d", got, want)
+	}
+	if strings.Contains(f.text, "step 99") {
+		t.Errorf("text = %q, wanted the last write not reported yet", f.text)
+	}
+
+	if err := s.Complete(ctx, ConclusionSuccess, "Built"); err != nil {
+		t.Fatalf("Complete() = %v", err)
+	}
+	if got, want := len(f.created)+len(f.updated), 4; got != want {
+		t.Errorf("made %d create or update calls, wanted %d", got, want)
+	}
+	if len(f.created) != 1 {
+		t.Errorf("created %d check runs, wanted 1", len(f.created))
+	}
+	for i := range 100 {
+		if line := fmt.Sprintf("step %d\n", i); !strings.Contains(f.text, line) {
+			t.Errorf("text is missing %q", line)
+		}
+	}
+}
+
+func TestStreamerFlush(t *testing.T) {
+	f := &fakeChecks{}
+	client := newFakeClient(t, f)
+	ctx := context.Background()
+
+	s := NewStreamer(client, "org", "repo", NewBuilder("build", "abc123"), time.Hour)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// Flushing creates the check run even without writes.
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if err := s.WriteString(ctx, "compiling"); err != nil {
+		t.Fatalf("WriteString() = %v", err)
+	}
+	if f.text != "" {
+		t.Errorf("text = %q, wanted the write coalesced", f.text)
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if f.text != "compiling\n" {
+		t.Errorf("text = %q, wanted %q", f.text, "compiling\n")
+	}
+	// Flushing without new writes makes no calls.
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if got, want := len(f.created)+len(f.updated), 2; got != want {
+		t.Errorf("made %d create or update calls, wanted %d", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package check
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// testEvent is an event emitted by `go test -json`.
+//
+// https://pkg.go.dev/cmd/test2json
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// packageResult tallies the results of the tests in a package.
+type packageResult struct {
+	name                    string
+	passed, failed, skipped int
+	// packageFailed is set if the package failed, e.g. to build, even when
+	// no test did.
+	packageFailed bool
+	output        strings.Builder
+}
+
+// FromTest2JSON returns a completed Builder for the check run named name on
+// the commit headSHA, summarizing the `go test -json` output read from r. The
+// output has a table of pass, fail and skip counts per package, followed by
+// the output of each failure. The conclusion is failure if any test or
+// package failed, and success otherwise.
+//
+// Lines that aren't test2json events, e.g. interleaved build errors, are
+// ignored.
+func FromTest2JSON(name, headSHA string, r io.Reader) (*Builder, error) {
+	var pkgs []*packageResult
+	byName := map[string]*packageResult{}
+	// Output of each test, keyed by package and test name, kept until the
+	// test finishes.
+	outputs := map[[2]string]*strings.Builder{}
+	type failure struct{ pkg, test, output string }
+	var failures []failure
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var ev testEvent
+			if json.Unmarshal(line, &ev) == nil && ev.Package != "" {
+				p, ok := byName[ev.Package]
+				if !ok {
+					p = &packageResult{name: ev.Package}
+					byName[ev.Package] = p
+					pkgs = append(pkgs, p)
+				}
+				key := [2]string{ev.Package, ev.Test}
+				switch ev.Action {
+				case "output":
+					if ev.Test == "" {
+						p.output.WriteString(ev.Output)
+						break
+					}
+					out, ok := outputs[key]
+					if !ok {
+						out = &strings.Builder{}
+						outputs[key] = out
+					}
+					out.WriteString(ev.Output)
+				case "pass", "fail", "skip":
+					if ev.Test == "" {
+						p.packageFailed = p.packageFailed || ev.Action == "fail"
+						break
+					}
+					switch ev.Action {
+					case "pass":
+						p.passed++
+					case "fail":
+						p.failed++
+						var out string
+						if o, ok := outputs[key]; ok {
+							out = o.String()
+						}
+						failures = append(failures, failure{ev.Package, ev.Test, out})
+					case "skip":
+						p.skipped++
+					}
+					delete(outputs, key)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading test2json output: %w", err)
+		}
+	}
+
+	b := NewBuilder(name, headSHA)
+	b.Status = StatusCompleted
+	b.Conclusion = ConclusionSuccess
+
+	var passed, failed, skipped int
+	b.WriteString("| Package | Passed | Failed | Skipped |")
+	b.WriteString("| --- | ---: | ---: | ---: |")
+	for _, p := range pkgs {
+		passed, failed, skipped = passed+p.passed, failed+p.failed, skipped+p.skipped
+		b.Writef("| `%s` | %d | %d | %d |", p.name, p.passed, p.failed, p.skipped)
+		if p.failed > 0 || p.packageFailed {
+			b.Conclusion = ConclusionFailure
+		}
+	}
+	b.Summary = fmt.Sprintf("%d passed, %d failed, %d skipped", passed, failed, skipped)
+
+	if b.Conclusion == ConclusionFailure {
+		b.WriteString("\n### Failures\n")
+		for _, f := range failures {
+			b.WriteDetails(f.pkg+"."+f.test, codeBlock(f.output))
+		}
+		// Packages that failed without a failing test, e.g. because they
+		// don't build or a test binary crashed.
+		for _, p := range pkgs {
+			if p.packageFailed && p.failed == 0 {
+				b.WriteDetails(p.name, codeBlock(p.output.String()))
+			}
+		}
+	}
+	return b, nil
+}
+
+// codeBlock fences s as preformatted text.
+func codeBlock(s string) string {
+	return "```\n" + strings.TrimRight(s, "\n") + "\n```"
+}
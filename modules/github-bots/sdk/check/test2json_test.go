@@ -0,0 +1,59 @@
+package check
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFromTest2JSON(t *testing.T) {
+	f, err := os.Open("testdata/test2json.json")
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	defer f.Close()
+
+	b, err := FromTest2JSON("test", "abc123", f)
+	if err != nil {
+		t.Fatalf("FromTest2JSON() = %v", err)
+	}
+	if b.Status != StatusCompleted || b.Conclusion != ConclusionFailure {
+		t.Errorf("Status, Conclusion = %q, %q, wanted %q, %q", b.Status, b.Conclusion, StatusCompleted, ConclusionFailure)
+	}
+	if want := "2 passed, 1 failed, 1 skipped"; b.Summary != want {
+		t.Errorf("Summary = %q, wanted %q", b.Summary, want)
+	}
+
+	want := "| Package | Passed | Failed | Skipped |\n" +
+		"| --- | ---: | ---: | ---: |\n" +
+		"| `example.com/m/bad` | 1 | 1 | 0 |\n" +
+		"| `example.com/m/ok` | 1 | 0 | 1 |\n" +
+		"\n### Failures\n\n" +
+		"<details><summary>example.com/m/bad.TestFail</summary>\n\n" +
+		"```\n=== RUN   TestFail\n    bad_test.go:7: got 1, wanted 2\n--- FAIL: TestFail (0.00s)\n```\n\n" +
+		"</details>\n"
+	if diff := cmp.Diff(want, b.text()); diff != "" {
+		t.Errorf("text() (-want +got): %s", diff)
+	}
+}
+
+func TestFromTest2JSONPackageFailure(t *testing.T) {
+	stream := `# example.com/m/broken
+broken.go:3:1: syntax error
+{"Action":"start","Package":"example.com/m/broken"}
+{"Action":"output","Package":"example.com/m/broken","Output":"FAIL\texample.com/m/broken [build failed]\n"}
+{"Action":"fail","Package":"example.com/m/broken"}
+`
+	b, err := FromTest2JSON("test", "abc123", strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("FromTest2JSON() = %v", err)
+	}
+	if b.Conclusion != ConclusionFailure {
+		t.Errorf("Conclusion = %q, wanted %q", b.Conclusion, ConclusionFailure)
+	}
+	if want := "<details><summary>example.com/m/broken</summary>"; !strings.Contains(b.text(), want) {
+		t.Errorf("text() = %q, wanted it to contain %q", b.text(), want)
+	}
+}
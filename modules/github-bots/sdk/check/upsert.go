@@ -0,0 +1,75 @@
+package check
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-bots/sdk"
+	"github.com/google/go-github/v61/github"
+)
+
+// Upsert updates the check run of b on its head SHA, or creates it if there
+// is none, and returns the resulting check run. If there are several check
+// runs with the name, the newest is updated, as that is the one GitHub
+// shows.
+//
+// When two Upserts race to create the check run, both create one. Each then
+// also updates the newest check run, if it isn't the one it created, so
+// that the check run GitHub shows reflects the last Upsert, as it would had
+// they updated an existing check run.
+func Upsert(ctx context.Context, client *github.Client, owner, repo string, b *Builder) (*github.CheckRun, error) {
+	existing, err := findCheckRun(ctx, client, owner, repo, b)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return updateCheckRun(ctx, client, owner, repo, existing.GetID(), b)
+	}
+
+	created, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, *b.CheckRunCreate())
+	if err != nil {
+		return nil, fmt.Errorf("creating check run %q: %w", b.name, err)
+	}
+	newest, err := findCheckRun(ctx, client, owner, repo, b)
+	if err != nil {
+		return nil, err
+	}
+	if newest != nil && newest.GetID() > created.GetID() {
+		return updateCheckRun(ctx, client, owner, repo, newest.GetID(), b)
+	}
+	return created, nil
+}
+
+// findCheckRun returns the newest check run named like b on its head SHA, or
+// nil if there is none.
+func findCheckRun(ctx context.Context, client *github.Client, owner, repo string, b *Builder) (*github.CheckRun, error) {
+	runs, err := sdk.ListAll(ctx, func(opts github.ListOptions) ([]*github.CheckRun, *github.Response, error) {
+		res, resp, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, b.headSHA, &github.ListCheckRunsOptions{
+			CheckName:   github.String(b.name),
+			Filter:      github.String("all"),
+			ListOptions: opts,
+		})
+		if err != nil {
+			return nil, resp, err
+		}
+		return res.CheckRuns, resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing check runs for %s: %w", b.headSHA, err)
+	}
+	var newest *github.CheckRun
+	for _, run := range runs {
+		if run.GetName() == b.name && run.GetID() > newest.GetID() {
+			newest = run
+		}
+	}
+	return newest, nil
+}
+
+func updateCheckRun(ctx context.Context, client *github.Client, owner, repo string, id int64, b *Builder) (*github.CheckRun, error) {
+	run, _, err := client.Checks.UpdateCheckRun(ctx, owner, repo, id, *b.CheckRunUpdate())
+	if err != nil {
+		return nil, fmt.Errorf("updating check run %d: %w", id, err)
+	}
+	return run, nil
+}
@@ -0,0 +1,188 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// fakeChecks is a fake of the GitHub check runs API for a single repository,
+// which lists one check run per page to exercise pagination.
+type fakeChecks struct {
+	m       sync.Mutex
+	runs    []*github.CheckRun
+	nextID  int64
+	created []int64
+	updated []int64
+	// text is the last output text reported.
+	text string
+	// onCreate, if set, is called after a check run is created, e.g. to
+	// simulate a concurrent creation.
+	onCreate func(f *fakeChecks)
+}
+
+func (f *fakeChecks) add(name, sha string) int64 {
+	f.nextID++
+	f.runs = append(f.runs, &github.CheckRun{ID: github.Int64(f.nextID), Name: github.String(name), HeadSHA: github.String(sha)})
+	return f.nextID
+}
+
+func (f *fakeChecks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/org/repo/commits/abc123/check-runs":
+		var matching []*github.CheckRun
+		for _, run := range f.runs {
+			if run.GetHeadSHA() == "abc123" && run.GetName() == r.URL.Query().Get("check_name") {
+				matching = append(matching, run)
+			}
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		res := &github.ListCheckRunsResults{Total: github.Int(len(matching))}
+		if page <= len(matching) {
+			res.CheckRuns = matching[page-1 : page]
+		}
+		if page < len(matching) {
+			next := *r.URL
+			q := next.Query()
+			q.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = q.Encode()
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+		}
+		json.NewEncoder(w).Encode(res)
+
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/org/repo/check-runs":
+		var opts github.CreateCheckRunOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		id := f.add(opts.Name, opts.HeadSHA)
+		f.created = append(f.created, id)
+		f.text = opts.GetOutput().GetText()
+		if f.onCreate != nil {
+			f.onCreate(f)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&github.CheckRun{ID: github.Int64(id), Name: github.String(opts.Name), Status: opts.Status})
+
+	case r.Method == http.MethodPatch:
+		id, err := strconv.ParseInt(r.URL.Path[len("/repos/org/repo/check-runs/"):], 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var opts github.UpdateCheckRunOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.updated = append(f.updated, id)
+		f.text = opts.GetOutput().GetText()
+		json.NewEncoder(w).Encode(&github.CheckRun{ID: github.Int64(id), Name: github.String(opts.Name), Status: opts.Status})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newFakeClient(t *testing.T, f *fakeChecks) *github.Client {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(srv.Client())
+	base, err := url.Parse(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("url.Parse() = %v", err)
+	}
+	client.BaseURL = base
+	return client
+}
+
+func TestUpsertCreates(t *testing.T) {
+	f := &fakeChecks{}
+	f.add("lint", "def456")
+	f.add("test", "abc123")
+	client := newFakeClient(t, f)
+
+	run, err := Upsert(context.Background(), client, "org", "repo", NewBuilder("lint", "abc123"))
+	if err != nil {
+		t.Fatalf("Upsert() = %v", err)
+	}
+	if got, want := run.GetID(), int64(3); got != want {
+		t.Errorf("Upsert() ID = %d, wanted %d", got, want)
+	}
+	if len(f.created) != 1 || len(f.updated) != 0 {
+		t.Errorf("created %v and updated %v, wanted a single creation", f.created, f.updated)
+	}
+}
+
+func TestUpsertUpdates(t *testing.T) {
+	f := &fakeChecks{}
+	f.add("lint", "abc123")
+	f.add("test", "abc123")
+	newest := f.add("lint", "abc123")
+	client := newFakeClient(t, f)
+
+	b := NewBuilder("lint", "abc123")
+	b.Status = StatusInProgress
+	run, err := Upsert(context.Background(), client, "org", "repo", b)
+	if err != nil {
+		t.Fatalf("Upsert() = %v", err)
+	}
+	if got := run.GetID(); got != newest {
+		t.Errorf("Upsert() ID = %d, wanted %d", got, newest)
+	}
+	if got, want := run.GetStatus(), "in_progress"; got != want {
+		t.Errorf("Upsert() Status = %q, wanted %q", got, want)
+	}
+	if len(f.created) != 0 || len(f.updated) != 1 || f.updated[0] != newest {
+		t.Errorf("created %v and updated %v, wanted an update of %d", f.created, f.updated, newest)
+	}
+}
+
+func TestUpsertConcurrentCreate(t *testing.T) {
+	f := &fakeChecks{}
+	var concurrent int64
+	f.onCreate = func(f *fakeChecks) {
+		// Another Upsert creates the check run right after this one.
+		concurrent = f.add("lint", "abc123")
+		f.onCreate = nil
+	}
+	client := newFakeClient(t, f)
+
+	run, err := Upsert(context.Background(), client, "org", "repo", NewBuilder("lint", "abc123"))
+	if err != nil {
+		t.Fatalf("Upsert() = %v", err)
+	}
+	if got := run.GetID(); got != concurrent {
+		t.Errorf("Upsert() ID = %d, wanted the newest check run %d", got, concurrent)
+	}
+	if len(f.updated) != 1 || f.updated[0] != concurrent {
+		t.Errorf("updated %v, wanted %d", f.updated, concurrent)
+	}
+}
+
+func TestUpsertError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	client := github.NewClient(srv.Client())
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	if _, err := Upsert(context.Background(), client, "org", "repo", NewBuilder("lint", "abc123")); err == nil {
+		t.Error("Upsert() = nil, wanted error")
+	}
+}
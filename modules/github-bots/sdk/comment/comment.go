@@ -0,0 +1,94 @@
+// Package comment helps bots build GitHub issue and pull request comments.
+package comment
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxBodyLength is the maximum length GitHub accepts for a comment body.
+	MaxBodyLength = 65536
+
+	truncationMessage = "\n\n_This comment was truncated because it exceeded GitHub's maximum length._"
+)
+
+// markerRE matches the hidden marker produced by Marker.
+var markerRE = regexp.MustCompile(`<!-- bot:([^\s]+) -->`)
+
+// Marker returns the hidden HTML comment used to identify comments for the
+// given key. This is the same marker used by GitHubClient.SetComment.
+func Marker(key string) string {
+	return fmt.Sprintf("<!-- bot:%s -->", key)
+}
+
+// KeyFromBody returns the key of the first marker in body, if any.
+func KeyFromBody(body string) (string, bool) {
+	m := markerRE.FindStringSubmatch(body)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// HasMarker returns whether body contains the marker for key.
+func HasMarker(body, key string) bool {
+	return strings.Contains(body, Marker(key))
+}
+
+// Builder accumulates markdown for a comment body.
+type Builder struct {
+	key       string
+	md        strings.Builder
+	maxLength int
+}
+
+// NewBuilder returns a Builder for a comment identified by key. If key is
+// non-empty, the body includes a hidden marker so that bots can find and
+// update their prior comment instead of posting a new one.
+func NewBuilder(key string) *Builder {
+	return &Builder{
+		key:       key,
+		maxLength: MaxBodyLength,
+	}
+}
+
+// Writef appends the formatted string to the comment, followed by a newline.
+func (b *Builder) Writef(format string, args ...any) {
+	fmt.Fprintf(&b.md, format, args...)
+	b.md.WriteString("\n")
+}
+
+// Heading appends a markdown heading of the given level.
+func (b *Builder) Heading(level int, text string) {
+	level = max(1, min(level, 6))
+	b.Writef("%s %s\n", strings.Repeat("#", level), text)
+}
+
+// ListItem appends a markdown list item.
+func (b *Builder) ListItem(format string, args ...any) {
+	b.Writef("- %s", fmt.Sprintf(format, args...))
+}
+
+// Body returns the comment body, including the marker if a key was given,
+// truncated to GitHub's maximum comment length.
+func (b *Builder) Body() string {
+	var prefix string
+	if b.key != "" {
+		prefix = Marker(b.key) + "\n\n"
+	}
+
+	content := b.md.String()
+	if len(prefix)+len(content) <= b.maxLength {
+		return prefix + content
+	}
+
+	// Leave room for the truncation message, and don't cut a character in half.
+	n := max(0, b.maxLength-len(prefix)-len(truncationMessage))
+	for n > 0 && !utf8.RuneStart(content[n]) {
+		n--
+	}
+	return prefix + content[:n] + truncationMessage
+}
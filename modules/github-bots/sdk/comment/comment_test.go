@@ -0,0 +1,81 @@
+package comment
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBody(t *testing.T) {
+	b := NewBuilder("my-bot")
+	b.Heading(2, "Results")
+	b.ListItem("%d passed", 3)
+	b.Writef("done")
+
+	want := "<!-- bot:my-bot -->\n\n## Results\n\n- 3 passed\ndone\n"
+	if got := b.Body(); got != want {
+		t.Errorf("Body() = %q, wanted %q", got, want)
+	}
+}
+
+func TestBodyNoKey(t *testing.T) {
+	b := NewBuilder("")
+	b.Writef("hello %s", "world")
+
+	if got, want := b.Body(), "hello world\n"; got != want {
+		t.Errorf("Body() = %q, wanted %q", got, want)
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	b := NewBuilder("my-bot")
+	b.maxLength = 200
+	for range 100 {
+		b.Writef("line of output é")
+	}
+
+	got := b.Body()
+	if len(got) > 200 {
+		t.Errorf("len(Body()) = %d, wanted <= 200", len(got))
+	}
+	if !strings.HasSuffix(got, truncationMessage) {
+		t.Errorf("Body() = %q, wanted truncation message", got)
+	}
+	if !HasMarker(got, "my-bot") {
+		t.Errorf("Body() = %q, wanted marker to survive truncation", got)
+	}
+	if !strings.ContainsRune(got, 'é') || strings.ContainsRune(got, '�') {
+		t.Errorf("Body() = %q, truncated mid-character", got)
+	}
+
+	// The default limit is GitHub's.
+	b = NewBuilder("my-bot")
+	b.Writef("%s", strings.Repeat("x", MaxBodyLength))
+	if got := len(b.Body()); got != MaxBodyLength {
+		t.Errorf("len(Body()) = %d, wanted %d", got, MaxBodyLength)
+	}
+}
+
+func TestMarkerRoundTrip(t *testing.T) {
+	for _, key := range []string{"my-bot", "release/v1.2", "dnm"} {
+		b := NewBuilder(key)
+		b.Writef("some content mentioning <!-- other -->")
+
+		got, ok := KeyFromBody(b.Body())
+		if !ok {
+			t.Fatalf("KeyFromBody() found no marker in %q", b.Body())
+		}
+		if got != key {
+			t.Errorf("KeyFromBody() = %q, wanted %q", got, key)
+		}
+		if !HasMarker(b.Body(), key) {
+			t.Errorf("HasMarker(%q) = false", key)
+		}
+		if HasMarker(b.Body(), key+"-other") {
+			t.Errorf("HasMarker(%q) = true", key+"-other")
+		}
+	}
+
+	if _, ok := KeyFromBody("no marker here"); ok {
+		t.Error("KeyFromBody() found a marker where there is none")
+	}
+}
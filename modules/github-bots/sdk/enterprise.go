@@ -0,0 +1,25 @@
+package sdk
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// newGitHubClient returns a GitHub client using httpClient. If the
+// GITHUB_BASE_URL env var is set, the client talks to that GitHub Enterprise
+// Server instead of github.com, uploading to GITHUB_UPLOAD_URL, which
+// defaults to the base URL.
+func newGitHubClient(httpClient *http.Client) (*github.Client, error) {
+	c := github.NewClient(httpClient)
+	baseURL := os.Getenv("GITHUB_BASE_URL")
+	if baseURL == "" {
+		return c, nil
+	}
+	uploadURL := os.Getenv("GITHUB_UPLOAD_URL")
+	if uploadURL == "" {
+		uploadURL = baseURL
+	}
+	return c.WithEnterpriseURLs(baseURL, uploadURL)
+}
@@ -0,0 +1,56 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestNewGitHubClientEnterprise(t *testing.T) {
+	for _, tt := range []struct {
+		name, baseURL, uploadURL string
+		wantBase, wantUpload     string
+	}{{
+		name:       "github.com",
+		wantBase:   "https://api.github.com/",
+		wantUpload: "https://uploads.github.com/",
+	}, {
+		name:       "enterprise",
+		baseURL:    "https://github.example.com",
+		uploadURL:  "https://uploads.github.example.com",
+		wantBase:   "https://github.example.com/api/v3/",
+		wantUpload: "https://uploads.github.example.com/api/uploads/",
+	}, {
+		name:       "enterprise without upload URL",
+		baseURL:    "https://github.example.com/",
+		wantBase:   "https://github.example.com/api/v3/",
+		wantUpload: "https://github.example.com/api/uploads/",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_BASE_URL", tt.baseURL)
+			t.Setenv("GITHUB_UPLOAD_URL", tt.uploadURL)
+
+			c, err := newGitHubClient(http.DefaultClient)
+			if err != nil {
+				t.Fatalf("newGitHubClient() = %v", err)
+			}
+			if got := c.BaseURL.String(); got != tt.wantBase {
+				t.Errorf("BaseURL = %q, wanted %q", got, tt.wantBase)
+			}
+			if got := c.UploadURL.String(); got != tt.wantUpload {
+				t.Errorf("UploadURL = %q, wanted %q", got, tt.wantUpload)
+			}
+		})
+	}
+}
+
+func TestNewGitHubClientInvalidURL(t *testing.T) {
+	t.Setenv("GITHUB_BASE_URL", "://not a url")
+	if _, err := newGitHubClient(http.DefaultClient); err == nil {
+		t.Error("newGitHubClient() = nil, wanted error")
+	}
+	// Bots get the error back rather than exiting.
+	if _, err := NewGitHubClient(context.Background(), "org", "repo", "policy"); err == nil {
+		t.Error("NewGitHubClient() = nil, wanted error")
+	}
+}
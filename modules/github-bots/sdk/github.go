@@ -27,18 +27,25 @@ import (
 //
 // A new token is created for each client, and is not refreshed. It can be
 // revoked with Close.
-func NewGitHubClient(ctx context.Context, org, repo, policyName string) GitHubClient {
+//
+// Set GITHUB_BASE_URL, and optionally GITHUB_UPLOAD_URL, to use a GitHub
+// Enterprise Server. An error is returned if they are invalid.
+func NewGitHubClient(ctx context.Context, org, repo, policyName string) (GitHubClient, error) {
 	ts := &tokenSource{
 		org:        org,
 		repo:       repo,
 		policyName: policyName,
 	}
+	inner, err := newGitHubClient(oauth2.NewClient(ctx, ts))
+	if err != nil {
+		return GitHubClient{}, fmt.Errorf("invalid GitHub Enterprise URLs: %w", err)
+	}
 	return GitHubClient{
-		inner: github.NewClient(oauth2.NewClient(ctx, ts)),
+		inner: inner,
 		ts:    ts,
 		// TODO: Make this configurable?
 		bufSize: 1024 * 1024, // 1MB buffer for requests
-	}
+	}, nil
 }
 
 type tokenSource struct {
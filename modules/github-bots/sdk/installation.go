@@ -0,0 +1,214 @@
+package sdk
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	"github.com/google/go-github/v61/github"
+)
+
+const (
+	// jwtClockSkew backdates the app JWT's issued-at time, as recommended by
+	// GitHub, to allow for drift between our clock and GitHub's.
+	jwtClockSkew = time.Minute
+	// jwtLifetime is how long the app JWT is valid for; GitHub allows at most 10 minutes.
+	jwtLifetime = 9 * time.Minute
+	// tokenRefreshWindow is how long before expiry an installation token is refreshed.
+	tokenRefreshWindow = 5 * time.Minute
+)
+
+// NewInstallationClient returns a GitHub client that authenticates as the
+// given GitHub App installation. The installation token is minted on first use
+// and refreshed transparently before it expires.
+//
+// Like NewGitHubClient, it honors GITHUB_BASE_URL and GITHUB_UPLOAD_URL.
+func NewInstallationClient(appID, installationID int64, privateKey []byte) (*github.Client, error) {
+	t, err := NewInstallationTransport(http.DefaultTransport, appID, installationID, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newGitHubClient(&http.Client{Transport: t})
+	if err != nil {
+		return nil, err
+	}
+	// Mint tokens from the same API the client talks to.
+	t.BaseURL = c.BaseURL.String()
+	return c, nil
+}
+
+// InstallationIDFromContext returns the installation ID from the event's
+// webhook.InstallationExtension, which the trampoline sets for deliveries to
+// GitHub Apps.
+func InstallationIDFromContext(ctx context.Context) (int64, bool) {
+	v := AttributeFromContext(ctx, webhook.InstallationExtension)
+	if v == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// InstallationTransport is an http.RoundTripper that authenticates requests
+// with a GitHub App installation token.
+type InstallationTransport struct {
+	// BaseURL is the GitHub API URL used to mint installation tokens.
+	BaseURL string
+
+	appID, installationID int64
+	key                   *rsa.PrivateKey
+	inner                 http.RoundTripper
+	now                   func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewInstallationTransport returns an InstallationTransport wrapping inner,
+// for the app and installation with the given PEM-encoded private key.
+func NewInstallationTransport(inner http.RoundTripper, appID, installationID int64, privateKey []byte) (*InstallationTransport, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &InstallationTransport{
+		BaseURL:        "https://api.github.com/",
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		inner:          inner,
+		now:            time.Now,
+	}, nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is %T, not RSA", k)
+	}
+	return key, nil
+}
+
+func (t *InstallationTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tok, err := t.Token(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the request, so send a copy.
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "token "+tok)
+	return t.inner.RoundTrip(r)
+}
+
+// Token returns a valid installation token, minting a new one if the current
+// token is missing or about to expire.
+func (t *InstallationTransport) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && t.now().Before(t.expiry.Add(-tokenRefreshWindow)) {
+		return t.token, nil
+	}
+
+	clog.FromContext(ctx).Debugf("refreshing installation token for installation %d", t.installationID)
+	tok, expiry, err := t.mint(ctx)
+	if err != nil {
+		return "", fmt.Errorf("refreshing installation token for installation %d: %w", t.installationID, err)
+	}
+	t.token, t.expiry = tok, expiry
+	return tok, nil
+}
+
+func (t *InstallationTransport) mint(ctx context.Context) (string, time.Time, error) {
+	jwt, err := t.appJWT()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("signing app JWT: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(t.BaseURL, "/"), t.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("unexpected status code: %s", resp.Status)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("decoding token response: %w", err)
+	}
+	if body.Token == "" {
+		return "", time.Time{}, errors.New("token response contained no token")
+	}
+	return body.Token, body.ExpiresAt, nil
+}
+
+// appJWT returns a JWT authenticating as the GitHub App.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func (t *InstallationTransport) appJWT() (string, error) {
+	now := t.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-jwtClockSkew).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(t.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, t.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,151 @@
+package sdk
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+)
+
+func TestInstallationTransport(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	minted := 0
+	failMint := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			if failMint {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if err := verifyJWT(&key.PublicKey, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), now); err != nil {
+				t.Errorf("invalid app JWT: %v", err)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			minted++
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{
+				"token":      fmt.Sprintf("token-%d", minted),
+				"expires_at": now.Add(time.Hour),
+			})
+		default:
+			fmt.Fprint(w, r.Header.Get("Authorization"))
+		}
+	}))
+	defer srv.Close()
+
+	tr, err := NewInstallationTransport(http.DefaultTransport, 1234, 42, keyPEM)
+	if err != nil {
+		t.Fatalf("NewInstallationTransport() = %v", err)
+	}
+	tr.BaseURL = srv.URL
+	tr.now = func() time.Time { return now }
+	client := &http.Client{Transport: tr}
+
+	get := func() (string, error) {
+		resp, err := client.Get(srv.URL + "/repos/foo/bar")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	// The first request mints a token, and subsequent ones reuse it.
+	for range 3 {
+		got, err := get()
+		if err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+		if want := "token token-1"; got != want {
+			t.Errorf("Authorization = %q, wanted %q", got, want)
+		}
+	}
+
+	// Close to expiry, the token is refreshed.
+	now = now.Add(time.Hour - time.Minute)
+	got, err := get()
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if want := "token token-2"; got != want {
+		t.Errorf("Authorization = %q, wanted %q", got, want)
+	}
+
+	// Refresh failures surface as errors.
+	now = now.Add(2 * time.Hour)
+	failMint = true
+	if _, err := get(); err == nil || !strings.Contains(err.Error(), "refreshing installation token for installation 42") {
+		t.Errorf("Get() = %v, wanted refresh error", err)
+	}
+}
+
+func verifyJWT(pub *rsa.PublicKey, jwt string, now time.Time) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT %q", jwt)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+		return err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		IAT int64  `json:"iat"`
+		EXP int64  `json:"exp"`
+		ISS string `json:"iss"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return err
+	}
+	if claims.ISS != "1234" {
+		return fmt.Errorf("iss = %q, wanted 1234", claims.ISS)
+	}
+	if claims.IAT >= now.Unix() {
+		return fmt.Errorf("iat = %d is not backdated for clock skew", claims.IAT)
+	}
+	if claims.EXP <= now.Unix() || claims.EXP > now.Add(10*time.Minute).Unix() {
+		return fmt.Errorf("exp = %d outside of allowed window", claims.EXP)
+	}
+	return nil
+}
+
+func TestInstallationIDFromContext(t *testing.T) {
+	// The trampoline sets extensions as strings.
+	ctx := context.WithValue(context.Background(), contextKey(webhook.InstallationExtension), "12345")
+	if id, ok := InstallationIDFromContext(ctx); !ok || id != 12345 {
+		t.Errorf("InstallationIDFromContext() = %d, %t, wanted 12345, true", id, ok)
+	}
+	if id, ok := InstallationIDFromContext(context.Background()); ok {
+		t.Errorf("InstallationIDFromContext() = %d, %t, wanted not found", id, ok)
+	}
+}
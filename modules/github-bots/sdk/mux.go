@@ -0,0 +1,106 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// MuxHandlerFunc handles a single CloudEvent. Returning an error NACKs the
+// event so that it may be redelivered.
+type MuxHandlerFunc func(ctx context.Context, event cloudevents.Event) error
+
+// Mux dispatches incoming CloudEvents to the handler registered for their type.
+type Mux struct {
+	handlers map[string]MuxHandlerFunc
+	unknown  MuxHandlerFunc
+}
+
+type MuxOption func(*Mux)
+
+// MuxWithUnknownHandler overrides how events without a registered handler are
+// handled. By default they are acknowledged and ignored.
+func MuxWithUnknownHandler(fn MuxHandlerFunc) MuxOption {
+	return func(m *Mux) {
+		m.unknown = fn
+	}
+}
+
+func NewMux(opts ...MuxOption) *Mux {
+	m := &Mux{
+		handlers: make(map[string]MuxHandlerFunc),
+		unknown: func(ctx context.Context, event cloudevents.Event) error {
+			clog.FromContext(ctx).Debugf("ignoring event with no handler: %s", event.Type())
+			return nil
+		},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Handle registers fn for events of the given type, e.g.
+// "dev.chainguard.github.pull_request".
+func (m *Mux) Handle(eventType string, fn MuxHandlerFunc) {
+	if _, ok := m.handlers[eventType]; ok {
+		panic(fmt.Sprintf("handler for event type %s already registered", eventType))
+	}
+	m.handlers[eventType] = fn
+}
+
+// Receive dispatches the event to its handler, and can be passed directly to
+// a CloudEvents client's StartReceiver.
+func (m *Mux) Receive(ctx context.Context, event cloudevents.Event) cloudevents.Result {
+	ctx = withEventLogger(ctx, event)
+	fn, ok := m.handlers[event.Type()]
+	if !ok {
+		fn = m.unknown
+	}
+	if err := fn(ctx, event); err != nil {
+		clog.FromContext(ctx).Errorf("failed to handle event %s: %v", event.Type(), err)
+		return cloudevents.NewReceipt(false, "%w", err)
+	}
+	return cloudevents.ResultACK
+}
+
+// withEventLogger returns ctx with its logger annotated with the event's
+// type, subject and ID, and the action and GitHub delivery ID the trampoline
+// sets as extensions, if any, so that handler logs can be correlated with the
+// event and its webhook delivery.
+func withEventLogger(ctx context.Context, event cloudevents.Event) context.Context {
+	args := []any{"event-type", event.Type(), "event-id", event.ID()}
+	if s := event.Subject(); s != "" {
+		args = append(args, "subject", s)
+	}
+	for _, f := range []struct{ field, ext string }{
+		{"action", "action"},
+		{"delivery", webhook.DeliveryExtension},
+	} {
+		if v, ok := event.Extensions()[f.ext]; ok {
+			args = append(args, f.field, v)
+		}
+	}
+	return clog.WithLogger(ctx, clog.FromContext(ctx).With(args...))
+}
+
+// ServeHTTP implements http.Handler for CloudEvents delivered over HTTP.
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	event, err := cloudevents.NewEventFromHTTPRequest(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse event: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if res := m.Receive(r.Context(), *event); !cloudevents.IsACK(res) {
+		http.Error(w, res.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chainguard-dev/clog"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func newTestEvent(t *testing.T, eventType string) cloudevents.Event {
+	t.Helper()
+	event := cloudevents.NewEvent()
+	event.SetID("id")
+	event.SetType(eventType)
+	event.SetSource("test")
+	if err := event.SetData(cloudevents.ApplicationJSON, map[string]string{"action": "opened"}); err != nil {
+		t.Fatalf("SetData() = %v", err)
+	}
+	return event
+}
+
+func serveEvent(t *testing.T, h http.Handler, event cloudevents.Event) int {
+	t.Helper()
+	req, err := cloudevents.NewHTTPRequestFromEvent(context.Background(), "http://localhost", event)
+	if err != nil {
+		t.Fatalf("NewHTTPRequestFromEvent() = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMux(t *testing.T) {
+	var got []string
+	m := NewMux()
+	m.Handle(string(PullRequestEvent), func(_ context.Context, event cloudevents.Event) error {
+		got = append(got, event.Type())
+		return nil
+	})
+	m.Handle(string(IssueCommentEvent), func(context.Context, cloudevents.Event) error {
+		return errors.New("boom")
+	})
+
+	for _, tt := range []struct {
+		name       string
+		eventType  string
+		wantStatus int
+		wantCalled bool
+	}{{
+		name:       "pull_request",
+		eventType:  string(PullRequestEvent),
+		wantStatus: http.StatusOK,
+		wantCalled: true,
+	}, {
+		name:       "handler error",
+		eventType:  string(IssueCommentEvent),
+		wantStatus: http.StatusInternalServerError,
+	}, {
+		name:       "unknown type",
+		eventType:  "dev.chainguard.github.unknown",
+		wantStatus: http.StatusOK,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			if status := serveEvent(t, m, newTestEvent(t, tt.eventType)); status != tt.wantStatus {
+				t.Errorf("status = %d, wanted %d", status, tt.wantStatus)
+			}
+			if called := len(got) == 1 && got[0] == tt.eventType; called != tt.wantCalled {
+				t.Errorf("handler called: %t, wanted %t", called, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestMuxUnknownHandler(t *testing.T) {
+	m := NewMux(MuxWithUnknownHandler(func(context.Context, cloudevents.Event) error {
+		return errors.New("unknown event")
+	}))
+
+	if res := m.Receive(context.Background(), newTestEvent(t, "dev.chainguard.github.unknown")); !cloudevents.IsNACK(res) {
+		t.Errorf("Receive() = %v, wanted NACK", res)
+	}
+	if status := serveEvent(t, m, newTestEvent(t, "dev.chainguard.github.unknown")); status != http.StatusInternalServerError {
+		t.Errorf("status = %d, wanted %d", status, http.StatusInternalServerError)
+	}
+}
+
+func TestMuxLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := clog.WithLogger(context.Background(), clog.New(slog.NewJSONHandler(&buf, nil)))
+
+	m := NewMux()
+	m.Handle(string(PullRequestEvent), func(ctx context.Context, _ cloudevents.Event) error {
+		clog.FromContext(ctx).Info("handling")
+		return nil
+	})
+
+	event := newTestEvent(t, string(PullRequestEvent))
+	event.SetSubject("org/repo")
+	event.SetExtension("action", "opened")
+	event.SetExtension("ghdelivery", "72d3162e")
+	if res := m.Receive(ctx, event); !cloudevents.IsACK(res) {
+		t.Fatalf("Receive() = %v, wanted ACK", res)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%q) = %v", buf.String(), err)
+	}
+	for k, want := range map[string]string{
+		"event-type": string(PullRequestEvent),
+		"event-id":   "id",
+		"subject":    "org/repo",
+		"action":     "opened",
+		"delivery":   "72d3162e",
+	} {
+		if got[k] != want {
+			t.Errorf("log field %q = %v, wanted %q", k, got[k], want)
+		}
+	}
+}
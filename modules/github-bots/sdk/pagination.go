@@ -0,0 +1,38 @@
+package sdk
+
+import (
+	"context"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// ListAll drives a paginated GitHub list call to completion and returns the
+// aggregated results. The list function is called with the options for each
+// page, starting with the first page of 100 items.
+//
+// If a page fails, or the context is cancelled between pages, ListAll returns
+// the results gathered so far along with the first error encountered.
+//
+//	prs, err := sdk.ListAll(ctx, func(opts github.ListOptions) ([]*github.PullRequest, *github.Response, error) {
+//		return client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{ListOptions: opts})
+//	})
+func ListAll[T any](ctx context.Context, list func(opts github.ListOptions) ([]T, *github.Response, error)) ([]T, error) {
+	var all []T
+	opts := github.ListOptions{PerPage: 100}
+	for {
+		if err := ctx.Err(); err != nil {
+			return all, err
+		}
+
+		items, resp, err := list(opts)
+		if err := handleGithubResponse(ctx, resp, err); err != nil {
+			return all, err
+		}
+		all = append(all, items...)
+
+		if resp == nil || resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v61/github"
+)
+
+// fakePages returns a list function serving the given pages in order.
+func fakePages(pages [][]string, errAt int) (func(github.ListOptions) ([]string, *github.Response, error), *[]int) {
+	var requested []int
+	return func(opts github.ListOptions) ([]string, *github.Response, error) {
+		page := max(opts.Page, 1)
+		requested = append(requested, page)
+		if page == errAt {
+			return nil, nil, errors.New("page failed")
+		}
+		resp := &github.Response{Response: &http.Response{StatusCode: http.StatusOK}}
+		if page < len(pages) {
+			resp.NextPage = page + 1
+		}
+		return pages[page-1], resp, nil
+	}, &requested
+}
+
+func TestListAll(t *testing.T) {
+	list, requested := fakePages([][]string{{"a", "b"}, {"c"}}, 0)
+
+	got, err := ListAll(context.Background(), list)
+	if err != nil {
+		t.Fatalf("ListAll() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, got); diff != "" {
+		t.Errorf("ListAll() (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff([]int{1, 2}, *requested); diff != "" {
+		t.Errorf("requested pages (-want +got): %s", diff)
+	}
+}
+
+func TestListAllError(t *testing.T) {
+	list, _ := fakePages([][]string{{"a", "b"}, {"c"}}, 2)
+
+	got, err := ListAll(context.Background(), list)
+	if err == nil {
+		t.Fatal("ListAll() succeeded, wanted error")
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, got); diff != "" {
+		t.Errorf("partial results (-want +got): %s", diff)
+	}
+}
+
+func TestListAllCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inner, requested := fakePages([][]string{{"a"}, {"b"}, {"c"}}, 0)
+	list := func(opts github.ListOptions) ([]string, *github.Response, error) {
+		defer cancel() // Cancel after the first page.
+		return inner(opts)
+	}
+
+	got, err := ListAll(ctx, list)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ListAll() = %v, wanted %v", err, context.Canceled)
+	}
+	if diff := cmp.Diff([]string{"a"}, got); diff != "" {
+		t.Errorf("partial results (-want +got): %s", diff)
+	}
+	if len(*requested) != 1 {
+		t.Errorf("requested %d pages, wanted 1", len(*requested))
+	}
+}
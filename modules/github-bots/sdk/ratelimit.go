@@ -0,0 +1,113 @@
+package sdk
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// RateLimitTransport is an http.RoundTripper that waits out GitHub rate limits
+// and retries idempotent requests.
+//
+// See https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+type RateLimitTransport struct {
+	// MaxRetries is the number of times a rate limited request is retried.
+	MaxRetries int
+	// MaxWait caps how long a single retry will wait for the limit to reset.
+	// Requests that would need to wait longer fail with the rate limited
+	// response instead.
+	MaxWait time.Duration
+
+	inner http.RoundTripper
+	now   func() time.Time
+}
+
+// NewRateLimitTransport wraps inner with rate limit aware retries.
+func NewRateLimitTransport(inner http.RoundTripper) *RateLimitTransport {
+	return &RateLimitTransport{
+		MaxRetries: 3,
+		MaxWait:    2 * time.Minute,
+		inner:      inner,
+		now:        time.Now,
+	}
+}
+
+func (t *RateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	for attempt := 0; ; attempt++ {
+		resp, err := t.inner.RoundTrip(r)
+		if err != nil {
+			return resp, err
+		}
+		if attempt >= t.MaxRetries || !isIdempotent(r) {
+			return resp, nil
+		}
+		delay, limited := t.rateLimitDelay(resp)
+		if !limited || delay > t.MaxWait {
+			return resp, nil
+		}
+		if r.Body != nil && r.GetBody == nil {
+			return resp, nil // We can't replay the body.
+		}
+
+		clog.FromContext(ctx).Warnf("rate limited by GitHub, retrying %s %s in %v", r.Method, r.URL.Path, delay)
+		resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(ctx)
+			r.Body = body
+		}
+	}
+}
+
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// rateLimitDelay returns how long to wait before retrying, and whether the
+// response indicates we were rate limited at all.
+func (t *RateLimitTransport) rateLimitDelay(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// Secondary rate limits tell us how long to wait.
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+
+	// Primary rate limits tell us when the window resets.
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return max(0, time.Unix(reset, 0).Sub(t.now())), true
+	}
+
+	// A 403 without rate limit headers is a permissions problem, not a rate limit.
+	if resp.StatusCode == http.StatusForbidden {
+		return 0, false
+	}
+	return time.Minute, true
+}
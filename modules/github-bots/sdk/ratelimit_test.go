@@ -0,0 +1,143 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		status     int
+		maxWait    time.Duration
+		wantCalls  int
+		wantStatus int
+	}{{
+		name:       "retry-after then success",
+		method:     http.MethodGet,
+		headers:    map[string]string{"Retry-After": "0"},
+		status:     http.StatusForbidden,
+		wantCalls:  2,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "429 retried",
+		method:     http.MethodGet,
+		headers:    map[string]string{"Retry-After": "0"},
+		status:     http.StatusTooManyRequests,
+		wantCalls:  2,
+		wantStatus: http.StatusOK,
+	}, {
+		name:   "primary rate limit reset",
+		method: http.MethodGet,
+		headers: map[string]string{
+			"X-RateLimit-Remaining": "0",
+			"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Unix(), 10),
+		},
+		status:     http.StatusForbidden,
+		wantCalls:  2,
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "plain 403 not retried",
+		method:     http.MethodGet,
+		status:     http.StatusForbidden,
+		wantCalls:  1,
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "non-idempotent not retried",
+		method:     http.MethodPost,
+		headers:    map[string]string{"Retry-After": "0"},
+		status:     http.StatusForbidden,
+		wantCalls:  1,
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "wait exceeds cap",
+		method:     http.MethodGet,
+		headers:    map[string]string{"Retry-After": "3600"},
+		status:     http.StatusForbidden,
+		wantCalls:  1,
+		wantStatus: http.StatusForbidden,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				calls++
+				if calls == 1 {
+					for k, v := range tt.headers {
+						w.Header().Set(k, v)
+					}
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: NewRateLimitTransport(http.DefaultTransport)}
+			req, err := http.NewRequest(tt.method, srv.URL, nil)
+			if err != nil {
+				t.Fatalf("NewRequest() = %v", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() = %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, wanted %d", resp.StatusCode, tt.wantStatus)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, wanted %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportMaxRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	tr := NewRateLimitTransport(http.DefaultTransport)
+	tr.MaxRetries = 2
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+	if calls != 3 {
+		t.Errorf("calls = %d, wanted 3", calls)
+	}
+}
+
+func TestRateLimitTransportContextCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() = %v", err)
+	}
+	start := time.Now()
+	if _, err := (&http.Client{Transport: NewRateLimitTransport(http.DefaultTransport)}).Do(req); err == nil {
+		t.Error("Do() succeeded, wanted context error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do() took %v, wanted it to stop at context cancellation", elapsed)
+	}
+}
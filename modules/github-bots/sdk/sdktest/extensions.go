@@ -0,0 +1,36 @@
+package sdktest
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// AssertExtension reports an error unless event has the extension name with
+// the value want. Values are compared in their canonical string form, e.g.
+// "42" for an integer extension.
+func AssertExtension(t testing.TB, event cloudevents.Event, name, want string) {
+	t.Helper()
+	v, ok := event.Extensions()[name]
+	if !ok {
+		t.Errorf("extension %q is unset, wanted %q", name, want)
+		return
+	}
+	got, err := types.Format(v)
+	if err != nil {
+		t.Errorf("extension %q has an invalid value %v: %v", name, v, err)
+		return
+	}
+	if got != want {
+		t.Errorf("extension %q = %q, wanted %q", name, got, want)
+	}
+}
+
+// AssertNoExtension reports an error if event has the extension name.
+func AssertNoExtension(t testing.TB, event cloudevents.Event, name string) {
+	t.Helper()
+	if v, ok := event.Extensions()[name]; ok {
+		t.Errorf("extension %q = %v, wanted it unset", name, v)
+	}
+}
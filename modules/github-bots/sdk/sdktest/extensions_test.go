@@ -0,0 +1,68 @@
+package sdktest_test
+
+import (
+	"fmt"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-bots/sdk/sdktest"
+)
+
+// recorder is a testing.TB that records reported errors.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertExtension(t *testing.T) {
+	event := cloudevents.NewEvent()
+	event.SetExtension("org", "chainguard-dev")
+	event.SetExtension("number", 42)
+
+	for _, tt := range []struct {
+		name   string
+		assert func(t testing.TB)
+		want   []string
+	}{{
+		name:   "matching string",
+		assert: func(t testing.TB) { sdktest.AssertExtension(t, event, "org", "chainguard-dev") },
+	}, {
+		name:   "matching integer",
+		assert: func(t testing.TB) { sdktest.AssertExtension(t, event, "number", "42") },
+	}, {
+		name:   "mismatch",
+		assert: func(t testing.TB) { sdktest.AssertExtension(t, event, "org", "wolfi-dev") },
+		want:   []string{`extension "org" = "chainguard-dev", wanted "wolfi-dev"`},
+	}, {
+		name:   "unset",
+		assert: func(t testing.TB) { sdktest.AssertExtension(t, event, "repo", "org/repo") },
+		want:   []string{`extension "repo" is unset, wanted "org/repo"`},
+	}, {
+		name:   "absent",
+		assert: func(t testing.TB) { sdktest.AssertNoExtension(t, event, "repo") },
+	}, {
+		name:   "present",
+		assert: func(t testing.TB) { sdktest.AssertNoExtension(t, event, "org") },
+		want:   []string{`extension "org" = chainguard-dev, wanted it unset`},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			tt.assert(r)
+			if len(r.errors) != len(tt.want) {
+				t.Fatalf("errors = %q, wanted %q", r.errors, tt.want)
+			}
+			for i := range tt.want {
+				if r.errors[i] != tt.want[i] {
+					t.Errorf("error = %q, wanted %q", r.errors[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
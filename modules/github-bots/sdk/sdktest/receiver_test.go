@@ -0,0 +1,71 @@
+package sdktest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-github/v61/github"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-bots/sdk"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-bots/sdk/sdktest"
+)
+
+func TestReceiver(t *testing.T) {
+	var gotTitle string
+	var gotAttr any
+	bot := sdk.NewBot("test",
+		sdk.BotWithHandler(sdk.PullRequestHandler(func(ctx context.Context, pre github.PullRequestEvent) error {
+			gotTitle = pre.GetPullRequest().GetTitle()
+			gotAttr = sdk.AttributeFromContext(ctx, "action")
+			if gotTitle == "fail" {
+				return errors.New("handler failed")
+			}
+			return nil
+		})),
+	)
+	r := sdktest.NewReceiver(t, bot.Receive)
+
+	for _, tt := range []struct {
+		name    string
+		title   string
+		wantACK bool
+	}{
+		{"ack", "hello", true},
+		{"nack", "fail", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res := r.Send(t, string(sdk.PullRequestEvent), github.PullRequestEvent{
+				PullRequest: &github.PullRequest{Title: github.String(tt.title)},
+			}, map[string]string{"action": "opened"})
+
+			if got := cloudevents.IsACK(res); got != tt.wantACK {
+				t.Errorf("IsACK() = %t, wanted %t (%v)", got, tt.wantACK, res)
+			}
+			if gotTitle != tt.title {
+				t.Errorf("handler saw title %q, wanted %q", gotTitle, tt.title)
+			}
+			if gotAttr != "opened" {
+				t.Errorf("handler saw action extension %v, wanted opened", gotAttr)
+			}
+		})
+	}
+}
+
+func TestReceiverMux(t *testing.T) {
+	m := sdk.NewMux()
+	called := false
+	m.Handle(string(sdk.IssueCommentEvent), func(context.Context, cloudevents.Event) error {
+		called = true
+		return nil
+	})
+	r := sdktest.NewReceiver(t, m.Receive)
+
+	if res := r.Send(t, string(sdk.IssueCommentEvent), map[string]any{}, nil); !cloudevents.IsACK(res) {
+		t.Errorf("Send() = %v, wanted ACK", res)
+	}
+	if !called {
+		t.Error("handler was not called")
+	}
+}
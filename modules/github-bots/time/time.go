@@ -13,7 +13,10 @@ func New() sdk.Bot {
 	name := "time"
 
 	handler := sdk.PullRequestHandler(func(ctx context.Context, pre github.PullRequestEvent) error {
-		cli := sdk.NewGitHubClient(ctx, *pre.Repo.Owner.Login, *pre.Repo.Name, name)
+		cli, err := sdk.NewGitHubClient(ctx, *pre.Repo.Owner.Login, *pre.Repo.Name, name)
+		if err != nil {
+			return err
+		}
 		defer cli.Close(ctx)
 
 		return cli.SetComment(ctx, pre.PullRequest, name, fmt.Sprintf("The time is now %s", time.Now().Format(time.RFC3339)))
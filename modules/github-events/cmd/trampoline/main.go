@@ -7,26 +7,88 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"cloud.google.com/go/storage"
 	"github.com/chainguard-dev/clog"
 	_ "github.com/chainguard-dev/clog/gcp/init"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/internal/trampoline"
 	"github.com/chainguard-dev/terraform-infra-common/pkg/httpmetrics"
 	mce "github.com/chainguard-dev/terraform-infra-common/pkg/httpmetrics/cloudevents"
+	"github.com/chainguard-dev/terraform-infra-common/pkg/profiler"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
-	"github.com/google/go-github/v60/github"
 	"github.com/kelseyhightower/envconfig"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type envConfig struct {
-	Port          int    `envconfig:"PORT" default:"8080" required:"true"`
-	IngressURI    string `envconfig:"EVENT_INGRESS_URI" required:"true"`
-	WebhookSecret string `envconfig:"WEBHOOK_SECRET" required:"true"`
+	Port          int           `envconfig:"PORT" default:"8080" required:"true"`
+	IngressURI    string        `envconfig:"EVENT_INGRESS_URI" required:"true"`
+	WebhookSecret string        `envconfig:"WEBHOOK_SECRET"`
+	Secrets       string        `envconfig:"WEBHOOK_SECRETS"`
+	EventSource   string        `envconfig:"EVENT_SOURCE"`
+	AllowedTypes  []string      `envconfig:"EVENT_TYPES_ALLOW"`
+	MaxEventAge   time.Duration `envconfig:"MAX_EVENT_AGE"`
+	PayloadTime   bool          `envconfig:"PAYLOAD_EVENT_TIME"`
+	ShadowURI     string        `envconfig:"SHADOW_INGRESS_URI"`
+	MetricRepos   []string      `envconfig:"METRIC_REPO_ALLOWLIST"`
+	ShutdownGrace time.Duration `envconfig:"SHUTDOWN_GRACE" default:"10s"`
+	MergedOnly    bool          `envconfig:"MERGED_ONLY_PULL_REQUESTS"`
+	DropDrafts    bool          `envconfig:"DROP_DRAFT_PULL_REQUESTS"`
+	FanOutFiles   bool          `envconfig:"FAN_OUT_PUSH_FILES"`
+	FanOutMax     int           `envconfig:"FAN_OUT_PUSH_MAX_FILES"`
+	QueueBucket   string        `envconfig:"QUEUE_BUCKET"`
+	AsyncWorkers  int           `envconfig:"ASYNC_WORKERS"`
+	ReplayBucket  string        `envconfig:"REPLAY_BUCKET"`
+	ReplayToken   string        `envconfig:"REPLAY_TOKEN"`
+	RejectTypes   bool          `envconfig:"REJECT_INVALID_EVENT_TYPES"`
+	GitHubHost    string        `envconfig:"GITHUB_HOST"`
+	RetryJitter   float64       `envconfig:"RETRY_JITTER" default:"0.5"`
+	DropBots      bool          `envconfig:"DROP_BOT_SENDERS"`
+	DropSenders   []string      `envconfig:"DROP_SENDERS"`
+	HookType      string        `envconfig:"HOOK_TARGET_TYPE"`
+	HookTargetID  string        `envconfig:"HOOK_TARGET_ID"`
+	DropNACKs     bool          `envconfig:"DROP_NACKS"`
+	RedactFields  []string      `envconfig:"REDACT_FIELDS"`
+	HeaderExts    bool          `envconfig:"INCLUDE_DELIVERY_HEADERS"`
+	SelfTestToken string        `envconfig:"SELFTEST_TOKEN"`
+	TLSCertFile   string        `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile    string        `envconfig:"TLS_KEY_FILE"`
+	ClientCAFile  string        `envconfig:"TLS_CLIENT_CA_FILE"`
+	MaxConcurrent int           `envconfig:"MAX_CONCURRENT"`
+	ReadTimeout   time.Duration `envconfig:"READ_TIMEOUT"`
+	WriteTimeout  time.Duration `envconfig:"WRITE_TIMEOUT"`
+	IdleTimeout   time.Duration `envconfig:"IDLE_TIMEOUT"`
+	HeaderBytes   int           `envconfig:"MAX_HEADER_BYTES"`
+	H2C           bool          `envconfig:"ENABLE_H2C"`
+	OrgRate       float64       `envconfig:"ORG_RATE_LIMIT"`
+	OrgBurst      int           `envconfig:"ORG_RATE_BURST"`
+	BranchFilter  []string      `envconfig:"BRANCH_FILTER"`
+	Region        string        `envconfig:"DEPLOY_REGION"`
+	InstanceID    string        `envconfig:"INSTANCE_ID"`
+	Verbose       bool          `envconfig:"VERBOSE_RESPONSES"`
+
+	// ExtraExts maps extension names to payload paths, e.g.
+	// "sender:sender.login,ref:ref".
+	ExtraExts map[string]string `envconfig:"EXTRA_EXTENSIONS"`
+
+	// Bindings is a JSON list of additional webhook paths, e.g. one per
+	// GitHub App, with their own secrets and ingress (see appBinding).
+	Bindings string `envconfig:"APP_BINDINGS"`
 }
 
 func main() {
@@ -34,74 +96,329 @@ func main() {
 	if err := envconfig.Process("", &env); err != nil {
 		clog.Fatalf("failed to process env var: %s", err)
 	}
+	bindings, err := parseBindings(env.Bindings)
+	if err != nil {
+		clog.Fatalf("APP_BINDINGS: %v", err)
+	}
+	// The default path only needs secrets if there are no bindings.
+	secrets, err := webhookSecrets(env.Secrets, env.WebhookSecret)
+	if err != nil && len(bindings) == 0 {
+		clog.Fatalf("%v", err)
+	}
+	if env.EventSource != "" {
+		if err := checkEventSource(env.EventSource); err != nil {
+			clog.Fatalf("EVENT_SOURCE: %v", err)
+		}
+	}
+	tlsConfig, err := serverTLSConfig(env.TLSCertFile, env.TLSKeyFile, env.ClientCAFile)
+	if err != nil {
+		clog.Fatalf("failed to configure TLS: %v", err)
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	go httpmetrics.ServeMetrics()
 	defer httpmetrics.SetupTracer(ctx)()
+	// Profiles are named after the Cloud Run service and revision.
+	defer profiler.Setup(ctx, "", "")()
 
 	ceclient, err := mce.NewClientHTTP("trampoline", mce.WithTarget(ctx, env.IngressURI)...)
 	if err != nil {
 		clog.FatalContextf(ctx, "failed to create cloudevents client: %v", err)
 	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		log := clog.FromContext(ctx)
+	opts := trampoline.ServerOptions{
+		Source:                 env.EventSource,
+		AllowedEventTypes:      env.AllowedTypes,
+		BranchFilter:           env.BranchFilter,
+		MaxEventAge:            env.MaxEventAge,
+		PayloadEventTime:       env.PayloadTime,
+		MetricRepoAllowlist:    env.MetricRepos,
+		MergedOnlyPullRequests: env.MergedOnly,
+		DropDraftPullRequests:  env.DropDrafts,
+		FanOutPushFiles:        env.FanOutFiles,
+		FanOutPushMaxFiles:     env.FanOutMax,
+		GitHubHost:             env.GitHubHost,
+		RetryJitter:            env.RetryJitter,
+		DropBotSenders:         env.DropBots,
+		DropSenders:            env.DropSenders,
+		ExpectedHookTargetType: env.HookType,
+		ExpectedHookTargetID:   env.HookTargetID,
+		DropNACKs:              env.DropNACKs,
+		RedactFields:           env.RedactFields,
+		IncludeDeliveryHeaders: env.HeaderExts,
+		ExtraExtensions:        env.ExtraExts,
+		Region:                 env.Region,
+		Instance:               env.InstanceID,
+		VerboseResponses:       env.Verbose,
+		MaxConcurrent:          env.MaxConcurrent,
+		OrgRateLimit:           trampoline.RateLimit{Rate: env.OrgRate, Burst: env.OrgBurst},
+	}
+	if env.RejectTypes {
+		opts.EventTypePolicy = trampoline.EventTypeReject
+	}
+	if env.ShadowURI != "" {
+		opts.ShadowIngress, err = mce.NewClientHTTP("trampoline-shadow", mce.WithTarget(ctx, env.ShadowURI)...)
+		if err != nil {
+			clog.FatalContextf(ctx, "failed to create shadow cloudevents client: %v", err)
+		}
+	}
 
-		defer r.Body.Close()
+	// Queued events are stored in GCS, as are the events that are replayed.
+	var gcs *storage.Client
+	if env.QueueBucket != "" || env.ReplayBucket != "" {
+		if gcs, err = storage.NewClient(ctx); err != nil {
+			clog.FatalContextf(ctx, "failed to create storage client: %v", err)
+		}
+		defer gcs.Close()
+	}
+	// Events that fail delivery are stored in the replay bucket, from which
+	// /replay re-sends them.
+	var replayStore trampoline.GCSStore
+	if env.ReplayBucket != "" {
+		replayStore = trampoline.GCSStore{Bucket: gcs.Bucket(env.ReplayBucket)}
+		opts.DeadLetter = replayStore
+	}
+	var asyncQueue *trampoline.AsyncQueue
+	switch {
+	case env.QueueBucket != "" && env.AsyncWorkers > 0:
+		clog.FatalContextf(ctx, "only one of QUEUE_BUCKET and ASYNC_WORKERS can be set")
+	case env.QueueBucket != "":
+		opts.Queue = trampoline.GCSStore{Bucket: gcs.Bucket(env.QueueBucket)}
+	case env.AsyncWorkers > 0:
+		asyncQueue = trampoline.NewAsyncQueue(ceclient, trampoline.AsyncQueueOptions{
+			Workers:    env.AsyncWorkers,
+			DeadLetter: opts.DeadLetter,
+		})
+		opts.Queue = asyncQueue
+	}
 
-		// https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
-		payload, err := github.ValidatePayload(r, []byte(env.WebhookSecret))
-		if err != nil {
-			log.Errorf("failed to verify webhook: %v", err)
-			w.WriteHeader(http.StatusForbidden)
-			fmt.Fprintf(w, "failed to verify webhook: %v", err)
-			return
+	if len(secrets) > 0 {
+		if err := opts.Validate(secrets); err != nil {
+			clog.FatalContextf(ctx, "invalid configuration: %v", err)
+		}
+		http.Handle("/", httpmetrics.Handler("webhook", trampoline.NewServer(ceclient, secrets, opts)))
+	}
+	if err := registerBindings(http.DefaultServeMux, bindings, opts, func(ingress string) (cloudevents.Client, error) {
+		return mce.NewClientHTTP("trampoline", mce.WithTarget(ctx, ingress)...)
+	}); err != nil {
+		clog.FatalContextf(ctx, "APP_BINDINGS: %v", err)
+	}
+
+	if env.ReplayBucket != "" && env.ReplayToken != "" {
+		http.Handle("/replay", httpmetrics.Handler("replay", trampoline.NewReplayServer(ceclient, replayStore, [][]byte{[]byte(env.ReplayToken)})))
+	}
+
+	if env.SelfTestToken != "" {
+		http.Handle("/selftest", httpmetrics.Handler("selftest", trampoline.NewSelfTestServer(ceclient, env.EventSource, [][]byte{[]byte(env.SelfTestToken)})))
+	}
+
+	srv := newHTTPServer(env, http.DefaultServeMux)
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		clog.FatalContextf(ctx, "failed to listen on %s: %v", srv.Addr, err)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	if err := serve(ctx, srv, ln, env.ShutdownGrace); err != nil {
+		clog.FatalContextf(ctx, "serve: %v", err)
+	}
+	if asyncQueue != nil {
+		wctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), env.ShutdownGrace)
+		defer cancel()
+		if err := asyncQueue.Wait(wctx); err != nil {
+			clog.ErrorContextf(ctx, "events still pending at shutdown: %v", err)
+		}
+	}
+}
+
+// appBinding is an additional webhook path, with its own secrets, ingress
+// and, optionally, allowed event types. The other options are shared with
+// the default path, except that events are neither queued nor
+// dead-lettered, as the queue and /replay deliver to the default ingress.
+//
+// Provider is the webhook provider, "github", "bitbucket" or "gitlab", which
+// defaults to "github". Bindings of other providers don't inherit the GitHub
+// event types and hook target of the default path.
+type appBinding struct {
+	Path              string   `json:"path"`
+	Provider          string   `json:"provider"`
+	Secrets           []string `json:"secrets"`
+	Ingress           string   `json:"ingress"`
+	AllowedEventTypes []string `json:"allowed_event_types"`
+}
+
+// newServers maps the providers of bindings to the constructors of their
+// servers.
+var newServers = map[string]func(cloudevents.Client, [][]byte, trampoline.ServerOptions) *trampoline.Server{
+	"":          trampoline.NewServer,
+	"github":    trampoline.NewServer,
+	"bitbucket": trampoline.NewBitbucketServer,
+	"gitlab":    trampoline.NewGitLabServer,
+}
+
+// parseBindings parses the JSON list of bindings in s, which may be empty.
+// Empty secrets are rejected, as anyone can sign deliveries with them.
+func parseBindings(s string) ([]appBinding, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var bindings []appBinding
+	if err := json.Unmarshal([]byte(s), &bindings); err != nil {
+		return nil, err
+	}
+	paths := make(map[string]bool, len(bindings))
+	for _, b := range bindings {
+		switch {
+		case !strings.HasPrefix(b.Path, "/") || b.Path == "/":
+			return nil, fmt.Errorf("path %q must start with / and not be the default path", b.Path)
+		case paths[b.Path]:
+			return nil, fmt.Errorf("path %q is bound more than once", b.Path)
+		case b.Ingress == "":
+			return nil, fmt.Errorf("path %q has no ingress", b.Path)
+		case slices.ContainsFunc(b.Secrets, func(s string) bool { return strings.TrimSpace(s) == "" }):
+			return nil, fmt.Errorf("path %q has an empty secret", b.Path)
+		case newServers[b.Provider] == nil:
+			return nil, fmt.Errorf("path %q has unknown provider %q", b.Path, b.Provider)
 		}
+		paths[b.Path] = true
+	}
+	return bindings, nil
+}
 
-		// https://docs.github.com/en/webhooks/webhook-events-and-payloads#delivery-headers
-		t := github.WebHookType(r)
-		if t == "" {
-			log.Errorf("missing X-GitHub-Event header")
-			w.WriteHeader(http.StatusBadRequest)
-			return
+// registerBindings registers a trampoline server for the provider of each
+// binding on mux, with opts overridden by the binding, forwarding to a client
+// for its ingress from newClient.
+func registerBindings(mux *http.ServeMux, bindings []appBinding, opts trampoline.ServerOptions, newClient func(ingress string) (cloudevents.Client, error)) error {
+	for _, b := range bindings {
+		secrets := make([][]byte, 0, len(b.Secrets))
+		for _, s := range b.Secrets {
+			secrets = append(secrets, []byte(s))
+		}
+		o := opts
+		// The queue delivers, and /replay re-sends dead-lettered events, to
+		// the default ingress, so bindings forward to their own directly.
+		o.Queue, o.DeadLetter = nil, nil
+		if b.Provider != "" && b.Provider != "github" {
+			o.AllowedEventTypes = nil
+			o.ExpectedHookTargetType, o.ExpectedHookTargetID = "", ""
+		}
+		if len(b.AllowedEventTypes) > 0 {
+			o.AllowedEventTypes = b.AllowedEventTypes
 		}
-		t = "dev.chainguard.github." + t
-		log = log.With("event-type", t)
-		log.Debugf("forwarding event: %s", t)
-
-		event := cloudevents.NewEvent()
-		event.SetType(t)
-		event.SetSource(r.Host)
-		// TODO: Extract organization and repo to set in subject, for better filtering.
-		// event.SetSubject(fmt.Sprintf("%s/%s", org, repo))
-		if err := event.SetData(cloudevents.ApplicationJSON, struct {
-			When time.Time       `json:"when"`
-			Body json.RawMessage `json:"body"`
-		}{
-			When: time.Now(),
-			Body: payload,
-		}); err != nil {
-			log.Errorf("failed to set data: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		if err := o.Validate(secrets); err != nil {
+			return fmt.Errorf("path %q: %w", b.Path, err)
+		}
+		client, err := newClient(b.Ingress)
+		if err != nil {
+			return fmt.Errorf("path %q: creating cloudevents client: %w", b.Path, err)
 		}
+		mux.Handle(b.Path, httpmetrics.Handler("webhook"+b.Path, newServers[b.Provider](client, secrets, o)))
+	}
+	return nil
+}
+
+// checkEventSource checks that source, the CloudEvents source of events, is
+// an absolute URI, e.g. "https://github.com/org", or an absolute path, e.g.
+// "/github", as url.Parse accepts almost any string.
+func checkEventSource(source string) error {
+	if _, err := url.ParseRequestURI(source); err != nil {
+		return fmt.Errorf("%q is not an absolute URI or path", source)
+	}
+	return nil
+}
 
-		const retryDelay = 10 * time.Millisecond
-		const maxRetry = 3
-		rctx := cloudevents.ContextWithRetriesExponentialBackoff(context.WithoutCancel(ctx), retryDelay, maxRetry)
-		if ceresult := ceclient.Send(rctx, event); cloudevents.IsUndelivered(ceresult) || cloudevents.IsNACK(ceresult) {
-			log.Errorf("Failed to deliver event: %v", ceresult)
-			w.WriteHeader(http.StatusInternalServerError)
+// webhookSecrets returns the webhook secrets in list, separated by commas or
+// newlines, e.g. the old and new secrets during a rotation. Whitespace around
+// secrets is trimmed and empty entries are ignored. If list has no secrets,
+// single is used instead.
+func webhookSecrets(list, single string) ([][]byte, error) {
+	var secrets [][]byte
+	for _, s := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if s = strings.TrimSpace(s); s != "" {
+			secrets = append(secrets, []byte(s))
 		}
-		log.Debugf("event forwarded")
-	})
+	}
+	if len(secrets) == 0 && single != "" {
+		secrets = append(secrets, []byte(single))
+	}
+	if len(secrets) == 0 {
+		return nil, errors.New("one of WEBHOOK_SECRETS or WEBHOOK_SECRET must be set")
+	}
+	return secrets, nil
+}
 
-	srv := &http.Server{
+// serverTLSConfig returns the TLS configuration of the server, or nil to
+// serve plaintext if certFile is unset. If clientCAFile is set, clients must
+// present a certificate signed by one of its CAs, whose identity is then
+// logged with each delivery.
+func serverTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" {
+		if keyFile != "" || clientCAFile != "" {
+			return nil, errors.New("TLS_CERT_FILE must be set to use TLS_KEY_FILE or TLS_CLIENT_CA_FILE")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// newHTTPServer returns the server for handler, with the timeouts and limits
+// of env. Unset ones keep the net/http defaults. If env.H2C is set, the
+// server also accepts HTTP/2 without TLS, for load balancers that terminate
+// TLS and keep long-lived HTTP/2 connections to the backend.
+func newHTTPServer(env envConfig, handler http.Handler) *http.Server {
+	if env.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: env.IdleTimeout})
+	}
+	return &http.Server{
 		Addr:              fmt.Sprintf(":%d", env.Port),
+		Handler:           handler,
 		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       env.ReadTimeout,
+		WriteTimeout:      env.WriteTimeout,
+		IdleTimeout:       env.IdleTimeout,
+		MaxHeaderBytes:    env.HeaderBytes,
 	}
-	clog.FatalContextf(ctx, "ListenAndServe: %v", srv.ListenAndServe())
+}
+
+// serve serves srv on ln until ctx is cancelled, and then shuts it down,
+// giving in-flight requests up to grace to complete.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, grace time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	clog.InfoContextf(ctx, "shutting down, waiting up to %v for in-flight requests", grace)
+	sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), grace)
+	defer cancel()
+	return srv.Shutdown(sctx)
 }
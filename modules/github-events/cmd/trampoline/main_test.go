@@ -0,0 +1,642 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/internal/trampoline"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/http2"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			<-release
+			io.WriteString(w, "forwarded")
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, srv, ln, 5*time.Second)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		resCh <- result{body: string(b), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-serveErr:
+		t.Fatalf("serve() returned %v before the in-flight request completed", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	res := <-resCh
+	if res.err != nil {
+		t.Fatalf("in-flight request failed: %v", res.err)
+	}
+	if res.body != "forwarded" {
+		t.Errorf("body = %q, wanted %q", res.body, "forwarded")
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("serve() = %v", err)
+	}
+}
+
+func TestCheckEventSource(t *testing.T) {
+	for _, tt := range []struct {
+		source  string
+		wantErr bool
+	}{
+		{"https://github.com/org", false},
+		{"urn:example:github", false},
+		{"/github", false},
+		{"github", true},
+		{"not a uri", true},
+		{"https://github.com/%zz", true},
+	} {
+		if err := checkEventSource(tt.source); (err != nil) != tt.wantErr {
+			t.Errorf("checkEventSource(%q) = %v, wanted error %t", tt.source, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebhookSecrets(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		list    string
+		single  string
+		want    []string
+		wantErr bool
+	}{
+		{"single", "", "hunter2", []string{"hunter2"}, false},
+		{"comma separated", "old, new", "", []string{"old", "new"}, false},
+		{"newline separated", "old\nnew\n", "", []string{"old", "new"}, false},
+		{"empties ignored", ",old,, \n ,new,", "", []string{"old", "new"}, false},
+		{"list wins", "old,new", "hunter2", []string{"old", "new"}, false},
+		{"empty list falls back", " , ", "hunter2", []string{"hunter2"}, false},
+		{"none", "", "", nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets, err := webhookSecrets(tt.list, tt.single)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("webhookSecrets() = %v, wanted error %t", err, tt.wantErr)
+			}
+			var got []string
+			for _, s := range secrets {
+				got = append(got, string(s))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("webhookSecrets() (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestWebhookSecretsVerify(t *testing.T) {
+	secrets, err := webhookSecrets("old,\nnew", "")
+	if err != nil {
+		t.Fatalf("webhookSecrets() = %v", err)
+	}
+	v := trampoline.GitHubVerifier{Secrets: secrets}
+
+	body := []byte(`{"zen":"Keep it logically awesome."}`)
+	for _, tt := range []struct {
+		secret string
+		want   bool
+	}{
+		{"old", true},
+		{"new", true},
+		{"other", false},
+	} {
+		t.Run(tt.secret, func(t *testing.T) {
+			mac := hmac.New(sha256.New, []byte(tt.secret))
+			mac.Write(body)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+			if _, err := v.Verify(req); (err == nil) != tt.want {
+				t.Errorf("Verify() = %v, wanted accepted %t", err, tt.want)
+			}
+		})
+	}
+}
+
+// ackClient is a cloudevents.Client acknowledging and counting every event.
+type ackClient struct {
+	cloudevents.Client
+	sent int
+}
+
+func (c *ackClient) Send(context.Context, cloudevents.Event) cloudevents.Result {
+	c.sent++
+	return cloudevents.ResultACK
+}
+
+func TestRegisterBindings(t *testing.T) {
+	bindings, err := parseBindings(`[
+		{"path": "/app-a", "secrets": ["secret-a"], "ingress": "https://a.example.com"},
+		{"path": "/app-b", "secrets": ["secret-b"], "ingress": "https://b.example.com", "allowed_event_types": ["push"]}
+	]`)
+	if err != nil {
+		t.Fatalf("parseBindings() = %v", err)
+	}
+
+	clients := map[string]*ackClient{}
+	mux := http.NewServeMux()
+	if err := registerBindings(mux, bindings, trampoline.ServerOptions{}, func(ingress string) (cloudevents.Client, error) {
+		clients[ingress] = &ackClient{}
+		return clients[ingress], nil
+	}); err != nil {
+		t.Fatalf("registerBindings() = %v", err)
+	}
+
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	for _, tt := range []struct {
+		name, path, secret string
+		want               int
+		wantA, wantB       int
+	}{
+		{"app a", "/app-a", "secret-a", http.StatusOK, 1, 0},
+		{"app a with app b's secret", "/app-a", "secret-b", http.StatusForbidden, 0, 0},
+		{"app b", "/app-b", "secret-b", http.StatusOK, 0, 1},
+		{"app b with app a's secret", "/app-b", "secret-a", http.StatusForbidden, 0, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := clients["https://a.example.com"].sent, clients["https://b.example.com"].sent
+
+			mac := hmac.New(sha256.New, []byte(tt.secret))
+			mac.Write(body)
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if got := clients["https://a.example.com"].sent - a; got != tt.wantA {
+				t.Errorf("app a ingress received %d events, wanted %d", got, tt.wantA)
+			}
+			if got := clients["https://b.example.com"].sent - b; got != tt.wantB {
+				t.Errorf("app b ingress received %d events, wanted %d", got, tt.wantB)
+			}
+		})
+	}
+}
+
+func TestRegisterBindingsBitbucket(t *testing.T) {
+	bindings, err := parseBindings(`[
+		{"path": "/bitbucket", "provider": "bitbucket", "secrets": ["secret-a"], "ingress": "https://a.example.com"}
+	]`)
+	if err != nil {
+		t.Fatalf("parseBindings() = %v", err)
+	}
+
+	client := &ackClient{}
+	mux := http.NewServeMux()
+	// GitHub event types of the default path don't apply to Bitbucket.
+	opts := trampoline.ServerOptions{AllowedEventTypes: []string{"push"}}
+	if err := registerBindings(mux, bindings, opts, func(string) (cloudevents.Client, error) {
+		return client, nil
+	}); err != nil {
+		t.Fatalf("registerBindings() = %v", err)
+	}
+
+	body := []byte(`{"repository":{"full_name":"workspace/repo"}}`)
+	mac := hmac.New(sha256.New, []byte("secret-a"))
+	mac.Write(body)
+	req := httptest.NewRequest(http.MethodPost, "/bitbucket", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(trampoline.BitbucketEventHeader, "repo:push")
+	req.Header.Set(trampoline.BitbucketSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if client.sent != 1 {
+		t.Errorf("ingress received %d events, wanted 1", client.sent)
+	}
+}
+
+// nackClient is a cloudevents.Client NACKing every event.
+type nackClient struct {
+	cloudevents.Client
+}
+
+func (nackClient) Send(context.Context, cloudevents.Event) cloudevents.Result {
+	return cloudevents.NewReceipt(false, "nope")
+}
+
+// countQueue is a trampoline.Queue counting the events enqueued.
+type countQueue struct {
+	enqueued int
+}
+
+func (q *countQueue) Enqueue(context.Context, string, string, []byte) error {
+	q.enqueued++
+	return nil
+}
+
+func TestRegisterBindingsGitLab(t *testing.T) {
+	bindings, err := parseBindings(`[
+		{"path": "/gitlab", "provider": "gitlab", "secrets": ["token-a"], "ingress": "https://a.example.com"}
+	]`)
+	if err != nil {
+		t.Fatalf("parseBindings() = %v", err)
+	}
+
+	client := &ackClient{}
+	mux := http.NewServeMux()
+	// GitHub event types of the default path don't apply to GitLab.
+	opts := trampoline.ServerOptions{AllowedEventTypes: []string{"pull_request"}}
+	if err := registerBindings(mux, bindings, opts, func(string) (cloudevents.Client, error) {
+		return client, nil
+	}); err != nil {
+		t.Fatalf("registerBindings() = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/gitlab", bytes.NewReader([]byte(`{"project":{"path_with_namespace":"group/project"}}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(trampoline.GitLabEventHeader, "Push Hook")
+	req.Header.Set(trampoline.GitLabTokenHeader, "token-a")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if client.sent != 1 {
+		t.Errorf("ingress received %d events, wanted 1", client.sent)
+	}
+}
+
+func TestRegisterBindingsDeadLetter(t *testing.T) {
+	bindings, err := parseBindings(`[
+		{"path": "/app-a", "secrets": ["secret-a"], "ingress": "https://a.example.com"}
+	]`)
+	if err != nil {
+		t.Fatalf("parseBindings() = %v", err)
+	}
+
+	// The default path's dead letters are replayed to its ingress, so
+	// the binding's failed deliveries must not be stored with them.
+	deadLetter := &countQueue{}
+	mux := http.NewServeMux()
+	if err := registerBindings(mux, bindings, trampoline.ServerOptions{DeadLetter: deadLetter}, func(string) (cloudevents.Client, error) {
+		return nackClient{}, nil
+	}); err != nil {
+		t.Fatalf("registerBindings() = %v", err)
+	}
+
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte("secret-a"))
+	mac.Write(body)
+	req := httptest.NewRequest(http.MethodPost, "/app-a", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, wanted %d: %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+	if deadLetter.enqueued != 0 {
+		t.Errorf("dead-lettered %d events, wanted 0", deadLetter.enqueued)
+	}
+}
+
+func TestRegisterBindingsQueue(t *testing.T) {
+	bindings, err := parseBindings(`[
+		{"path": "/app-a", "secrets": ["secret-a"], "ingress": "https://a.example.com"}
+	]`)
+	if err != nil {
+		t.Fatalf("parseBindings() = %v", err)
+	}
+
+	// The default path's queue delivers to its ingress, so the binding's
+	// events must be forwarded to the binding's ingress instead.
+	queue := &countQueue{}
+	client := &ackClient{}
+	mux := http.NewServeMux()
+	if err := registerBindings(mux, bindings, trampoline.ServerOptions{Queue: queue}, func(string) (cloudevents.Client, error) {
+		return client, nil
+	}); err != nil {
+		t.Fatalf("registerBindings() = %v", err)
+	}
+
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte("secret-a"))
+	mac.Write(body)
+	req := httptest.NewRequest(http.MethodPost, "/app-a", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if queue.enqueued != 0 {
+		t.Errorf("enqueued %d events, wanted 0", queue.enqueued)
+	}
+	if client.sent != 1 {
+		t.Errorf("ingress received %d events, wanted 1", client.sent)
+	}
+}
+
+func TestParseBindingsErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name, bindings string
+	}{
+		{"invalid json", `{"path": "/app-a"}`},
+		{"default path", `[{"path": "/", "ingress": "https://a.example.com"}]`},
+		{"relative path", `[{"path": "app-a", "ingress": "https://a.example.com"}]`},
+		{"duplicate path", `[{"path": "/app-a", "ingress": "https://a.example.com"}, {"path": "/app-a", "ingress": "https://b.example.com"}]`},
+		{"no ingress", `[{"path": "/app-a", "secrets": ["secret-a"]}]`},
+		{"empty secret", `[{"path": "/app-a", "secrets": ["secret-a", ""], "ingress": "https://a.example.com"}]`},
+		{"blank secret", `[{"path": "/app-a", "secrets": [" "], "ingress": "https://a.example.com"}]`},
+		{"unknown provider", `[{"path": "/app-a", "provider": "gitea", "secrets": ["secret-a"], "ingress": "https://a.example.com"}]`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseBindings(tt.bindings); err == nil {
+				t.Errorf("parseBindings() = nil, wanted error")
+			}
+		})
+	}
+}
+
+func TestRegisterBindingsNoSecrets(t *testing.T) {
+	bindings := []appBinding{{Path: "/app-a", Ingress: "https://a.example.com"}}
+	if err := registerBindings(http.NewServeMux(), bindings, trampoline.ServerOptions{}, func(string) (cloudevents.Client, error) {
+		return &ackClient{}, nil
+	}); err == nil {
+		t.Error("registerBindings() = nil, wanted error for a binding without secrets")
+	}
+}
+
+// testCert is a certificate and its key.
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert returns a certificate from tmpl, signed by parent or else
+// self-signed.
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() = %v", err)
+	}
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+// writePEM writes the certificate and key of c to files in dir, returning
+// their paths.
+func (c *testCert) writePEM(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() = %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	server := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "trampoline"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	certFile, keyFile := server.writePEM(t, dir, "server")
+	spiffe, err := url.Parse("spiffe://example.com/ingress")
+	if err != nil {
+		t.Fatalf("url.Parse() = %v", err)
+	}
+	client := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "ingress"},
+		URIs:         []*url.URL{spiffe},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	cfg, err := serverTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("serverTLSConfig() = %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, trampoline.ClientIdentity(r))
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs []tls.Certificate) (string, error) {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certs,
+			MinVersion:   tls.VersionTLS12,
+		}}}
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	got, err := get([]tls.Certificate{{Certificate: [][]byte{client.der}, PrivateKey: client.key}})
+	if err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	if want := spiffe.String(); got != want {
+		t.Errorf("ClientIdentity() = %q, wanted %q", got, want)
+	}
+
+	if _, err := get(nil); err == nil {
+		t.Error("request without client certificate succeeded")
+	}
+
+	// A certificate from another CA is rejected.
+	other := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "impostor"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil)
+	if _, err := get([]tls.Certificate{{Certificate: [][]byte{other.der}, PrivateKey: other.key}}); err == nil {
+		t.Error("request with untrusted client certificate succeeded")
+	}
+}
+
+func TestServerTLSConfigPlaintext(t *testing.T) {
+	if cfg, err := serverTLSConfig("", "", ""); cfg != nil || err != nil {
+		t.Errorf("serverTLSConfig() = %v, %v, wanted nil, nil", cfg, err)
+	}
+	if _, err := serverTLSConfig("", "", "ca.pem"); err == nil {
+		t.Error("serverTLSConfig() = nil, wanted error for a client CA without a certificate")
+	}
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	srv := newHTTPServer(envConfig{
+		Port:         8080,
+		ReadTimeout:  time.Minute,
+		WriteTimeout: 2 * time.Minute,
+		IdleTimeout:  5 * time.Minute,
+		HeaderBytes:  1 << 16,
+	}, http.NotFoundHandler())
+
+	if got, want := srv.Addr, ":8080"; got != want {
+		t.Errorf("Addr = %q, wanted %q", got, want)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"ReadHeaderTimeout", srv.ReadHeaderTimeout, 10 * time.Second},
+		{"ReadTimeout", srv.ReadTimeout, time.Minute},
+		{"WriteTimeout", srv.WriteTimeout, 2 * time.Minute},
+		{"IdleTimeout", srv.IdleTimeout, 5 * time.Minute},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, wanted %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if got, want := srv.MaxHeaderBytes, 1<<16; got != want {
+		t.Errorf("MaxHeaderBytes = %d, wanted %d", got, want)
+	}
+}
+
+func TestNewHTTPServerDefaults(t *testing.T) {
+	srv := newHTTPServer(envConfig{Port: 8080}, http.NotFoundHandler())
+	if srv.ReadTimeout != 0 || srv.WriteTimeout != 0 || srv.IdleTimeout != 0 || srv.MaxHeaderBytes != 0 {
+		t.Errorf("newHTTPServer() = %+v, wanted net/http defaults", srv)
+	}
+}
+
+func TestNewHTTPServerH2C(t *testing.T) {
+	srv := newHTTPServer(envConfig{H2C: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() = %v", err)
+	}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	// Speak HTTP/2 with prior knowledge over plaintext.
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	}
+	if got, want := string(b), "HTTP/2.0"; got != want {
+		t.Errorf("Proto = %q, wanted %q", got, want)
+	}
+}
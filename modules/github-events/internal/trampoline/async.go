@@ -0,0 +1,145 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/chainguard-dev/clog"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// defaultAsyncWorkers is the default of AsyncQueueOptions.Workers.
+const defaultAsyncWorkers = 10
+
+// AsyncQueueOptions configures optional behavior of the AsyncQueue.
+type AsyncQueueOptions struct {
+	// Workers limits the number of events delivered at once. It defaults
+	// to 10.
+	Workers int
+
+	// RetryPolicy is the retry policy of deliveries.
+	RetryPolicy RetryPolicy
+
+	// DeadLetter, if set, stores the events whose delivery fails, keyed by
+	// the delivery ID, like ServerOptions.DeadLetter. Otherwise they are
+	// only logged and counted.
+	DeadLetter Queue
+}
+
+// AsyncQueue is a Queue that delivers events to a client in the background,
+// so that deliveries are acknowledged without waiting for the ingress. The
+// events of each ordering key are delivered one at a time, in the order they
+// were enqueued, while events of distinct keys are delivered concurrently.
+//
+// Events are only held in memory, so those still pending when the process
+// exits are lost unless Wait returns first. Enqueueing a key that is still
+// pending is ignored, but a key is forgotten once its event is delivered.
+type AsyncQueue struct {
+	client cloudevents.Client
+	opts   AsyncQueueOptions
+	// sem holds a token for each event being delivered.
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	m sync.Mutex
+	// pending holds the keys of the events not yet delivered.
+	pending map[string]bool
+	// tails holds, for each ordering key with pending events, a channel
+	// that is closed once the last event enqueued with the key is done.
+	tails map[string]chan struct{}
+}
+
+var _ Queue = (*AsyncQueue)(nil)
+
+// NewAsyncQueue returns an AsyncQueue delivering events to client.
+func NewAsyncQueue(client cloudevents.Client, opts AsyncQueueOptions) *AsyncQueue {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultAsyncWorkers
+	}
+	opts.RetryPolicy = opts.RetryPolicy.withDefaults()
+	return &AsyncQueue{
+		client:  client,
+		opts:    opts,
+		sem:     make(chan struct{}, opts.Workers),
+		pending: make(map[string]bool),
+		tails:   make(map[string]chan struct{}),
+	}
+}
+
+// Enqueue schedules the delivery of the event after the events enqueued
+// before with the same ordering key.
+func (q *AsyncQueue) Enqueue(ctx context.Context, key, orderingKey string, b []byte) error {
+	var event cloudevents.Event
+	if err := json.Unmarshal(b, &event); err != nil {
+		return fmt.Errorf("parsing event %s: %w", key, err)
+	}
+
+	q.m.Lock()
+	if q.pending[key] {
+		q.m.Unlock()
+		return nil
+	}
+	q.pending[key] = true
+	prev, done := q.tails[orderingKey], make(chan struct{})
+	if orderingKey != "" {
+		q.tails[orderingKey] = done
+	}
+	q.wg.Add(1)
+	q.m.Unlock()
+
+	go func() {
+		defer q.wg.Done()
+		defer q.finish(key, orderingKey, done)
+		if prev != nil {
+			<-prev
+		}
+		q.sem <- struct{}{}
+		defer func() { <-q.sem }()
+		q.deliver(context.WithoutCancel(ctx), key, orderingKey, event, b)
+	}()
+	return nil
+}
+
+// deliver sends the event, dead-lettering it if that fails.
+func (q *AsyncQueue) deliver(ctx context.Context, key, orderingKey string, event cloudevents.Event, b []byte) {
+	if reason, _ := send(ctx, q.client, q.opts.RetryPolicy, event); reason == "success" || q.opts.DeadLetter == nil {
+		return
+	}
+	if err := q.opts.DeadLetter.Enqueue(ctx, key, orderingKey, b); err != nil {
+		clog.FromContext(ctx).Errorf("failed to dead-letter event %s: %v", key, err)
+	}
+}
+
+// finish forgets the event of key, and lets the next event of orderingKey
+// proceed.
+func (q *AsyncQueue) finish(key, orderingKey string, done chan struct{}) {
+	q.m.Lock()
+	delete(q.pending, key)
+	if q.tails[orderingKey] == done {
+		delete(q.tails, orderingKey)
+	}
+	q.m.Unlock()
+	close(done)
+}
+
+// Wait waits until the events enqueued so far are delivered, or ctx is done.
+func (q *AsyncQueue) Wait(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
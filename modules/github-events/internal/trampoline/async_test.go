@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+// sendFuncClient is a cloudevents.Client that sends with a function.
+type sendFuncClient struct {
+	cloudevents.Client
+	send func(cloudevents.Event) cloudevents.Result
+}
+
+func (c sendFuncClient) Send(_ context.Context, event cloudevents.Event) cloudevents.Result {
+	return c.send(event)
+}
+
+// newSerializedEvent returns the serialized event with the given ID.
+func newSerializedEvent(t *testing.T, id string) []byte {
+	t.Helper()
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("dev.chainguard.github.push")
+	event.SetSource("test")
+	b, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	return b
+}
+
+func TestAsyncQueueOrdering(t *testing.T) {
+	var m sync.Mutex
+	var sent []string
+	// The first event of org/a blocks until the event of org/b is sent,
+	// which would deadlock if org/b waited behind org/a.
+	unblock := make(chan struct{})
+	client := sendFuncClient{send: func(event cloudevents.Event) cloudevents.Result {
+		switch event.ID() {
+		case "a1":
+			<-unblock
+		case "b1":
+			defer close(unblock)
+		}
+		m.Lock()
+		defer m.Unlock()
+		sent = append(sent, event.ID())
+		return nil
+	}}
+
+	q := NewAsyncQueue(client, AsyncQueueOptions{Workers: 2})
+	ctx := context.Background()
+	for _, e := range []struct{ id, orderingKey string }{
+		{"a1", "org/a"},
+		{"a2", "org/a"},
+		{"b1", "org/b"},
+		{"a3", "org/a"},
+	} {
+		if err := q.Enqueue(ctx, e.id, e.orderingKey, newSerializedEvent(t, e.id)); err != nil {
+			t.Fatalf("Enqueue(%s) = %v", e.id, err)
+		}
+	}
+	wctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := q.Wait(wctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"b1", "a1", "a2", "a3"}, sent); diff != "" {
+		t.Errorf("sent events (-want +got): %s", diff)
+	}
+}
+
+func TestAsyncQueueDeduplicatesPending(t *testing.T) {
+	var m sync.Mutex
+	var sent []string
+	unblock := make(chan struct{})
+	client := sendFuncClient{send: func(event cloudevents.Event) cloudevents.Result {
+		<-unblock
+		m.Lock()
+		defer m.Unlock()
+		sent = append(sent, event.ID())
+		return nil
+	}}
+
+	q := NewAsyncQueue(client, AsyncQueueOptions{})
+	ctx := context.Background()
+	for range 2 {
+		if err := q.Enqueue(ctx, "delivery-1", "org/a", newSerializedEvent(t, "delivery-1")); err != nil {
+			t.Fatalf("Enqueue() = %v", err)
+		}
+	}
+	close(unblock)
+	if err := q.Wait(ctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"delivery-1"}, sent); diff != "" {
+		t.Errorf("sent events (-want +got): %s", diff)
+	}
+}
+
+func TestAsyncQueueDeadLetter(t *testing.T) {
+	client := sendFuncClient{send: func(cloudevents.Event) cloudevents.Result {
+		return cloudevents.NewReceipt(false, "nope")
+	}}
+	deadLetter := &memQueue{}
+
+	q := NewAsyncQueue(client, AsyncQueueOptions{DeadLetter: deadLetter})
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, "delivery-1", "org/a", newSerializedEvent(t, "delivery-1")); err != nil {
+		t.Fatalf("Enqueue() = %v", err)
+	}
+	if err := q.Wait(ctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if _, ok := deadLetter.items["delivery-1"]; !ok {
+		t.Errorf("dead letter queue has no item for delivery-1: %v", deadLetter.items)
+	}
+}
+
+func TestAsyncQueueInvalidEvent(t *testing.T) {
+	q := NewAsyncQueue(sendFuncClient{}, AsyncQueueOptions{})
+	if err := q.Enqueue(context.Background(), "delivery-1", "", []byte("not json")); err == nil {
+		t.Error("Enqueue() = nil, wanted error")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DeliveryOutcome summarizes how the Server handled a webhook delivery.
+type DeliveryOutcome struct {
+	// DeliveryID is the X-GitHub-Delivery header of the delivery.
+	DeliveryID string
+	// EventType is the GitHub event type, e.g. "pull_request", after
+	// sanitization.
+	EventType string
+	// Status is the HTTP status of the response to the delivery.
+	Status int
+	// Latency is the time from receiving the delivery to responding.
+	Latency time.Duration
+}
+
+// AuditSink receives the outcome of each delivery, e.g. to build a delivery
+// ledger without parsing logs. Implementations are called synchronously
+// before the response completes, so they should be quick and must be safe
+// for concurrent use.
+type AuditSink interface {
+	RecordDelivery(ctx context.Context, outcome DeliveryOutcome)
+}
+
+// nopAuditSink is the default AuditSink, which discards outcomes.
+type nopAuditSink struct{}
+
+func (nopAuditSink) RecordDelivery(context.Context, DeliveryOutcome) {}
+
+// statusWriter records the status written to a http.ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Status returns the status written, which is 200 if none was written
+// explicitly.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
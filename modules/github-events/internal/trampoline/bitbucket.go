@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// Bitbucket Cloud delivery headers.
+// https://support.atlassian.com/bitbucket-cloud/docs/event-payloads/#HTTP-headers
+const (
+	BitbucketEventHeader     = "X-Event-Key"
+	BitbucketSignatureHeader = "X-Hub-Signature"
+	BitbucketDeliveryHeader  = "X-Request-UUID"
+)
+
+// NewBitbucketServer returns a Server forwarding Bitbucket Cloud events to
+// client, with a BitbucketVerifier using the given secrets and a
+// BitbucketParser unless overridden by opts.
+func NewBitbucketServer(client cloudevents.Client, secrets [][]byte, opts ServerOptions) *Server {
+	if opts.Verifier == nil {
+		opts.Verifier = BitbucketVerifier{Secrets: secrets}
+	}
+	if opts.Parser == nil {
+		opts.Parser = BitbucketParser{}
+	}
+	return NewServer(client, secrets, opts)
+}
+
+// BitbucketParser parses Bitbucket Cloud webhook deliveries. Event types are
+// the X-Event-Key with colons replaced by dots, e.g. "pullrequest.created",
+// and forwarded as "dev.chainguard.bitbucket." followed by it. The Info has
+// the full name and visibility of the repository, and events of pull
+// requests carry their URL, branches and head commit as extensions.
+type BitbucketParser struct{}
+
+var _ Parser = BitbucketParser{}
+
+// bitbucketPayload holds the fields of Bitbucket Cloud webhook payloads used
+// to populate CloudEvent attributes and extensions.
+type bitbucketPayload struct {
+	Repository struct {
+		FullName  string `json:"full_name"`
+		IsPrivate *bool  `json:"is_private"`
+	} `json:"repository"`
+	PullRequest struct {
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+		Source      bitbucketEndpoint `json:"source"`
+		Destination bitbucketEndpoint `json:"destination"`
+	} `json:"pullrequest"`
+}
+
+// bitbucketEndpoint is the source or destination of a pull request.
+type bitbucketEndpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+}
+
+func (BitbucketParser) Parse(r *http.Request, payload []byte) (Delivery, error) {
+	t := bitbucketEventType(r.Header.Get(BitbucketEventHeader))
+	if t == "" {
+		return Delivery{}, fmt.Errorf("%w: missing %s header", ErrInvalidEventType, BitbucketEventHeader)
+	}
+	d := Delivery{
+		Type:       "dev.chainguard.bitbucket." + t,
+		EventType:  t,
+		ID:         r.Header.Get(BitbucketDeliveryHeader),
+		Extensions: map[string]string{},
+	}
+
+	var info bitbucketPayload
+	err := json.Unmarshal(payload, &info)
+	d.Info.Repository.FullName = info.Repository.FullName
+	d.Info.Repository.Private = info.Repository.IsPrivate
+	pr := info.PullRequest
+	for name, value := range map[string]string{
+		"pullrequesturl": pr.Links.HTML.Href,
+		"basebranch":     pr.Destination.Branch.Name,
+		"headbranch":     pr.Source.Branch.Name,
+		"headsha":        pr.Source.Commit.Hash,
+	} {
+		if value != "" {
+			d.Extensions[name] = value
+		}
+	}
+	return d, err
+}
+
+// bitbucketEventType maps an X-Event-Key value like "pullrequest:created" to
+// an event type suffix like "pullrequest.created".
+func bitbucketEventType(h string) string {
+	return strings.ReplaceAll(strings.TrimSpace(h), ":", ".")
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newBitbucketRequest(t *testing.T, event string, secret []byte, payload any) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(BitbucketEventHeader, event)
+	if secret != nil {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		req.Header.Set(BitbucketSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return req
+}
+
+func TestBitbucketTrampoline(t *testing.T) {
+	secret := []byte("hunter2")
+	payload := map[string]any{
+		"repository": map[string]any{
+			"full_name":  "workspace/repo",
+			"is_private": true,
+		},
+		"pullrequest": map[string]any{
+			"id": float64(1),
+			"links": map[string]any{
+				"html": map[string]any{"href": "https://bitbucket.org/workspace/repo/pull-requests/1"},
+			},
+			"source": map[string]any{
+				"branch": map[string]any{"name": "feature"},
+				"commit": map[string]any{"hash": "abc123"},
+			},
+			"destination": map[string]any{
+				"branch": map[string]any{"name": "main"},
+				"commit": map[string]any{"hash": "def456"},
+			},
+		},
+	}
+
+	client := &fakeClient{}
+	rec := httptest.NewRecorder()
+	NewBitbucketServer(client, [][]byte{[]byte("old"), secret}, ServerOptions{}).ServeHTTP(rec, newBitbucketRequest(t, "pullrequest:created", secret, payload))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+	event := client.events[0]
+	if got, want := event.Type(), "dev.chainguard.bitbucket.pullrequest.created"; got != want {
+		t.Errorf("Type() = %q, wanted %q", got, want)
+	}
+	if got, want := event.Subject(), "workspace/repo"; got != want {
+		t.Errorf("Subject() = %q, wanted %q", got, want)
+	}
+	if diff := cmp.Diff(map[string]any{
+		"repovisibility": "private",
+		"pullrequesturl": "https://bitbucket.org/workspace/repo/pull-requests/1",
+		"basebranch":     "main",
+		"headbranch":     "feature",
+		"headsha":        "abc123",
+	}, event.Extensions()); diff != "" {
+		t.Errorf("Extensions() (-want +got): %s", diff)
+	}
+
+	var data struct {
+		Body map[string]any `json:"body"`
+	}
+	if err := event.DataAs(&data); err != nil {
+		t.Fatalf("DataAs() = %v", err)
+	}
+	if diff := cmp.Diff(payload, data.Body); diff != "" {
+		t.Errorf("forwarded body (-want +got): %s", diff)
+	}
+}
+
+func TestBitbucketTrampolineOptions(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	srv := NewBitbucketServer(client, [][]byte{secret}, ServerOptions{
+		Source:            "https://bitbucket.org/workspace",
+		AllowedEventTypes: []string{"pullrequest.*"},
+	})
+
+	for _, tt := range []struct {
+		event    string
+		want     int
+		wantSent int
+	}{
+		{"repo:push", http.StatusAccepted, 0},
+		{"pullrequest:created", http.StatusOK, 1},
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, newBitbucketRequest(t, tt.event, secret, map[string]any{}))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, wanted %d", tt.event, rec.Code, tt.want)
+		}
+		if len(client.events) != tt.wantSent {
+			t.Fatalf("%s: sent %d events, wanted %d", tt.event, len(client.events), tt.wantSent)
+		}
+	}
+	if got, want := client.events[0].Source(), "https://bitbucket.org/workspace"; got != want {
+		t.Errorf("Source() = %q, wanted %q", got, want)
+	}
+}
+
+func TestBitbucketTrampolineBadSignature(t *testing.T) {
+	for _, secret := range [][]byte{nil, []byte("wrong")} {
+		client := &fakeClient{}
+		rec := httptest.NewRecorder()
+		NewBitbucketServer(client, [][]byte{[]byte("hunter2")}, ServerOptions{}).ServeHTTP(rec, newBitbucketRequest(t, "repo:push", secret, map[string]any{}))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("secret %q: status = %d, wanted %d", secret, rec.Code, http.StatusForbidden)
+		}
+		if len(client.events) != 0 {
+			t.Errorf("secret %q: sent %d events, wanted 0", secret, len(client.events))
+		}
+	}
+}
@@ -0,0 +1,23 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import "net/http"
+
+// ClientIdentity returns the identity of the verified TLS client certificate
+// of r, for servers that require mutual TLS, e.g. from an ingress proxy. It
+// is the first URI SAN of the certificate, such as a SPIFFE ID, or else its
+// subject common name. It is empty if r carries no verified certificate.
+func ClientIdentity(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.CommonName
+}
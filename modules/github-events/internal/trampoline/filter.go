@@ -0,0 +1,61 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"path"
+	"strings"
+)
+
+// matcher matches strings against a list of entries, each of which matches
+// either exactly or as a path.Match pattern, e.g. "org/*". A "*" entry
+// matches everything, including values containing "/", which path.Match
+// would not. Entries match exactly too, so that e.g. "renovate[bot]" still
+// matches that login.
+type matcher struct {
+	all      bool
+	exact    map[string]bool
+	patterns []string
+}
+
+// newMatcher returns a matcher for the entries, or nil if there are none.
+func newMatcher(entries []string) *matcher {
+	if len(entries) == 0 {
+		return nil
+	}
+	m := &matcher{exact: make(map[string]bool, len(entries))}
+	for _, e := range entries {
+		m.exact[e] = true
+		switch {
+		case e == "*":
+			m.all = true
+		case strings.ContainsAny(e, `*?[\`) && validPattern(e):
+			m.patterns = append(m.patterns, e)
+		}
+	}
+	return m
+}
+
+func validPattern(p string) bool {
+	_, err := path.Match(p, "")
+	return err == nil
+}
+
+// match reports whether s matches any entry. A nil matcher matches nothing.
+func (m *matcher) match(s string) bool {
+	if m == nil {
+		return false
+	}
+	if m.all || m.exact[s] {
+		return true
+	}
+	for _, p := range m.patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		entries []string
+		value   string
+		want    bool
+	}{
+		{"no entries", nil, "anything", false},
+		{"exact", []string{"org/repo"}, "org/repo", true},
+		{"exact mismatch", []string{"org/repo"}, "org/other", false},
+		{"wildcard", []string{"*"}, "123456", true},
+		{"wildcard with slash", []string{"*"}, "org/repo", true},
+		{"glob", []string{"org/*"}, "org/repo", true},
+		{"glob other org", []string{"org/*"}, "other/repo", false},
+		{"glob does not cross slashes", []string{"org/*"}, "org/repo/sub", false},
+		{"brackets match exactly", []string{"renovate[bot]"}, "renovate[bot]", true},
+		{"brackets as a class", []string{"renovate[bot]"}, "renovateo", true},
+		{"escaped brackets", []string{`renovate\[bot\]`}, "renovateo", false},
+		{"invalid pattern matches exactly", []string{"org/[repo"}, "org/[repo", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newMatcher(tt.entries).match(tt.value); got != tt.want {
+				t.Errorf("match(%q) = %t, wanted %t", tt.value, got, tt.want)
+			}
+		})
+	}
+}
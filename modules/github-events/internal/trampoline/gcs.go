@@ -0,0 +1,65 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// GCSStore stores serialized events as objects in a GCS bucket, named by
+// their key. It can be used both as a Queue and as an EventStore.
+type GCSStore struct {
+	Bucket *storage.BucketHandle
+}
+
+var (
+	_ Queue      = GCSStore{}
+	_ EventStore = GCSStore{}
+)
+
+// Enqueue writes the event, unless an event with the same key was already
+// written. The ordering key is recorded as the "orderingkey" metadata of the
+// object, so that consumers can deliver the objects of each ordering key in
+// the order they were created.
+func (s GCSStore) Enqueue(ctx context.Context, key, orderingKey string, event []byte) error {
+	w := s.Bucket.Object(key).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.ContentType = "application/cloudevents+json"
+	if orderingKey != "" {
+		w.Metadata = map[string]string{"orderingkey": orderingKey}
+	}
+	if _, err := w.Write(event); err != nil {
+		w.Close()
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	if err := w.Close(); err != nil {
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed {
+			// Already stored by an earlier delivery attempt.
+			return nil
+		}
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get reads the event stored under key.
+func (s GCSStore) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.Bucket.Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrEventNotFound, key)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
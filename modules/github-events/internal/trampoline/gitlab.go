@@ -0,0 +1,102 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chainguard-dev/clog"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// GitLab delivery headers.
+// https://docs.gitlab.com/ee/user/project/integrations/webhooks.html
+const (
+	GitLabTokenHeader    = "X-Gitlab-Token"
+	GitLabEventHeader    = "X-Gitlab-Event"
+	GitLabDeliveryHeader = "X-Gitlab-Event-UUID"
+)
+
+// NewGitLabServer returns a Server forwarding GitLab events to client, with
+// a GitLabVerifier using the given secret tokens and a GitLabParser unless
+// overridden by opts.
+func NewGitLabServer(client cloudevents.Client, tokens [][]byte, opts ServerOptions) *Server {
+	if opts.Verifier == nil {
+		opts.Verifier = GitLabVerifier{Tokens: tokens}
+	}
+	if opts.Parser == nil {
+		opts.Parser = GitLabParser{EventTypePolicy: opts.EventTypePolicy}
+	}
+	return NewServer(client, tokens, opts)
+}
+
+// GitLabParser parses GitLab webhook deliveries. Event types are the
+// X-Gitlab-Event without the " Hook" suffix, in snake case, e.g.
+// "merge_request", and forwarded as "dev.chainguard.gitlab." followed by it.
+// The Info has the path of the project as the repository's full name, and
+// the ref of pushes. Events carry the action of the object, if any, as the
+// "action" extension, and merge_request events carry their URL.
+type GitLabParser struct {
+	// EventTypePolicy controls the handling of invalid event types.
+	EventTypePolicy EventTypePolicy
+}
+
+var _ Parser = GitLabParser{}
+
+// gitLabPayload holds the fields of GitLab webhook payloads used to populate
+// CloudEvent attributes.
+type gitLabPayload struct {
+	Ref     string `json:"ref"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		URL    string `json:"url"`
+		Action string `json:"action"`
+	} `json:"object_attributes"`
+}
+
+func (p GitLabParser) Parse(r *http.Request, payload []byte) (Delivery, error) {
+	t := gitLabEventType(r.Header.Get(GitLabEventHeader))
+	if t == "" {
+		return Delivery{}, fmt.Errorf("%w: missing %s header", ErrInvalidEventType, GitLabEventHeader)
+	}
+	if normalized := normalizeEventType(t); normalized != t {
+		if p.EventTypePolicy == EventTypeReject {
+			return Delivery{}, fmt.Errorf("%w: %q", ErrInvalidEventType, t)
+		}
+		clog.FromContext(r.Context()).Warnf("sanitized event type %q to %q", t, normalized)
+		t = normalized
+	}
+	d := Delivery{
+		Type:       "dev.chainguard.gitlab." + t,
+		EventType:  t,
+		ID:         r.Header.Get(GitLabDeliveryHeader),
+		Extensions: map[string]string{},
+	}
+
+	var info gitLabPayload
+	err := json.Unmarshal(payload, &info)
+	d.Info.Ref = info.Ref
+	d.Info.Repository.FullName = info.Project.PathWithNamespace
+	if info.ObjectAttributes.Action != "" {
+		d.Extensions["action"] = info.ObjectAttributes.Action
+	}
+	if t == "merge_request" && info.ObjectAttributes.URL != "" {
+		d.Extensions["mergerequesturl"] = info.ObjectAttributes.URL
+	}
+	return d, err
+}
+
+// gitLabEventType maps an X-Gitlab-Event value like "Merge Request Hook" to
+// an event type suffix like "merge_request".
+func gitLabEventType(h string) string {
+	h = strings.TrimSuffix(strings.TrimSpace(h), " Hook")
+	return strings.ReplaceAll(strings.ToLower(h), " ", "_")
+}
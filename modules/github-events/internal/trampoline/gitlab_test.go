@@ -0,0 +1,159 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func newGitLabRequest(t *testing.T, event, token string, payload any) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(GitLabEventHeader, event)
+	if token != "" {
+		req.Header.Set(GitLabTokenHeader, token)
+	}
+	return req
+}
+
+func TestGitLabTrampoline(t *testing.T) {
+	const token = "hunter2"
+
+	for _, tt := range []struct {
+		name           string
+		event          string
+		payload        map[string]any
+		wantType       string
+		wantSubject    string
+		wantExtensions map[string]any
+	}{{
+		name:  "push",
+		event: "Push Hook",
+		payload: map[string]any{
+			"object_kind": "push",
+			"ref":         "refs/heads/main",
+			"project": map[string]any{
+				"path_with_namespace": "group/project",
+			},
+		},
+		wantType:    "dev.chainguard.gitlab.push",
+		wantSubject: "group/project",
+	}, {
+		name:  "merge request",
+		event: "Merge Request Hook",
+		payload: map[string]any{
+			"object_kind": "merge_request",
+			"project": map[string]any{
+				"path_with_namespace": "group/project",
+			},
+			"object_attributes": map[string]any{
+				"action": "open",
+				"url":    "https://gitlab.com/group/project/-/merge_requests/1",
+			},
+		},
+		wantType:    "dev.chainguard.gitlab.merge_request",
+		wantSubject: "group/project",
+		wantExtensions: map[string]any{
+			"action":          "open",
+			"mergerequesturl": "https://gitlab.com/group/project/-/merge_requests/1",
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewGitLabServer(client, [][]byte{[]byte(token)}, ServerOptions{}).ServeHTTP(rec, newGitLabRequest(t, tt.event, token, tt.payload))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			event := client.events[0]
+			if got := event.Type(); got != tt.wantType {
+				t.Errorf("Type() = %q, wanted %q", got, tt.wantType)
+			}
+			if got := event.Subject(); got != tt.wantSubject {
+				t.Errorf("Subject() = %q, wanted %q", got, tt.wantSubject)
+			}
+			if diff := cmp.Diff(tt.wantExtensions, event.Extensions(), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Extensions() (-want +got): %s", diff)
+			}
+
+			var data struct {
+				Body map[string]any `json:"body"`
+			}
+			if err := event.DataAs(&data); err != nil {
+				t.Fatalf("DataAs() = %v", err)
+			}
+			if diff := cmp.Diff(tt.payload, data.Body); diff != "" {
+				t.Errorf("forwarded body (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestGitLabTrampolineBadToken(t *testing.T) {
+	for _, token := range []string{"", "wrong"} {
+		client := &fakeClient{}
+		rec := httptest.NewRecorder()
+		NewGitLabServer(client, [][]byte{[]byte("hunter2")}, ServerOptions{}).ServeHTTP(rec, newGitLabRequest(t, "Push Hook", token, map[string]any{}))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("token %q: status = %d, wanted %d", token, rec.Code, http.StatusForbidden)
+		}
+		if len(client.events) != 0 {
+			t.Errorf("token %q: sent %d events, wanted 0", token, len(client.events))
+		}
+	}
+}
+
+func TestGitLabTrampolineOptions(t *testing.T) {
+	const token = "hunter2"
+	client := &fakeClient{}
+	srv := NewGitLabServer(client, [][]byte{[]byte(token)}, ServerOptions{
+		Source:            "https://gitlab.com/group",
+		AllowedEventTypes: []string{"push", "merge_request"},
+		BranchFilter:      []string{"main"},
+		EventTypePolicy:   EventTypeReject,
+	})
+
+	for _, tt := range []struct {
+		event    string
+		ref      string
+		want     int
+		wantSent int
+	}{
+		{"Tag Push Hook", "refs/tags/v1", http.StatusAccepted, 0},
+		{"Push Hook", "refs/heads/feature", http.StatusAccepted, 0},
+		{"Push Hook", "refs/heads/main", http.StatusOK, 1},
+		{"Merge Request Hook", "", http.StatusOK, 2},
+		{"Merge-Request Hook", "", http.StatusBadRequest, 2},
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, newGitLabRequest(t, tt.event, token, map[string]any{"ref": tt.ref}))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, wanted %d", tt.event, tt.ref, rec.Code, tt.want)
+		}
+		if len(client.events) != tt.wantSent {
+			t.Fatalf("%s %s: sent %d events, wanted %d", tt.event, tt.ref, len(client.events), tt.wantSent)
+		}
+	}
+	if got, want := client.events[0].Source(), "https://gitlab.com/group"; got != want {
+		t.Errorf("Source() = %q, wanted %q", got, want)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"net/http"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-github/v60/github"
+)
+
+// setDeliveryHeaderExtensions sets the extensions for the delivery headers
+// present on r, per webhook.DeliveryHeaderExtensions, and "ghsignaturealg" to
+// the strongest algorithm the delivery was signed with, if any.
+func setDeliveryHeaderExtensions(event *cloudevents.Event, r *http.Request) {
+	for _, h := range webhook.DeliveryHeaderExtensions {
+		if v := r.Header.Get(h.Header); v != "" {
+			event.SetExtension(h.Extension, v)
+		}
+	}
+	switch {
+	case r.Header.Get(github.SHA256SignatureHeader) != "":
+		event.SetExtension("ghsignaturealg", "sha256")
+	case r.Header.Get(github.SHA1SignatureHeader) != "":
+		event.SetExtension("ghsignaturealg", "sha1")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// extractPaths evaluates the JSON paths of extensions, which map extension
+// names to paths, against payload, returning the extensions whose path
+// leads to a non-empty scalar. Numbers and booleans are returned in their
+// JSON form.
+func extractPaths(payload []byte, extensions map[string]string) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(extensions))
+	for name, path := range extensions {
+		if v := lookupPath(doc, path); v != "" {
+			out[name] = v
+		}
+	}
+	return out, nil
+}
+
+// lookupPath returns the scalar at path in doc, or empty if there is none.
+// Paths are dot-separated object keys, e.g. "sender.login", and array
+// elements are selected by index, either as "labels[0]" or "labels.0".
+func lookupPath(doc any, path string) string {
+	v := doc
+	for _, seg := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(seg, "[")
+		if key != "" {
+			var ok bool
+			if v, ok = child(v, key); !ok {
+				return ""
+			}
+		}
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return ""
+			}
+			if v, ok = child(v, idx); !ok {
+				return ""
+			}
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+// child returns the member key of an object, or the element at index key of
+// an array.
+func child(v any, key string) (any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		c, ok := v[key]
+		return c, ok
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, false
+		}
+		return v[i], true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExtractPaths(t *testing.T) {
+	payload := []byte(`{
+		"ref": "refs/heads/main",
+		"forced": false,
+		"sender": {"login": "octocat", "id": 583231, "email": ""},
+		"pull_request": {
+			"labels": [{"name": "bug"}, {"name": "help wanted"}],
+			"head": {"repo": null}
+		}
+	}`)
+
+	got, err := extractPaths(payload, map[string]string{
+		"sender":     "sender.login",
+		"senderid":   "sender.id",
+		"ref":        "ref",
+		"forced":     "forced",
+		"firstlabel": "pull_request.labels[0].name",
+		"lastlabel":  "pull_request.labels.1.name",
+		"labels":     "pull_request.labels",
+		"missing":    "sender.name",
+		"outofrange": "pull_request.labels[2].name",
+		"notarray":   "sender[0]",
+		"null":       "pull_request.head.repo.name",
+		"empty":      "sender.email",
+	})
+	if err != nil {
+		t.Fatalf("extractPaths() = %v", err)
+	}
+	want := map[string]string{
+		"sender":     "octocat",
+		"senderid":   "583231",
+		"ref":        "refs/heads/main",
+		"forced":     "false",
+		"firstlabel": "bug",
+		"lastlabel":  "help wanted",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("extractPaths() (-want +got): %s", diff)
+	}
+}
+
+func TestExtractPathsInvalidPayload(t *testing.T) {
+	if _, err := extractPaths([]byte(`{`), map[string]string{"sender": "sender.login"}); err == nil {
+		t.Error("extractPaths() = nil, wanted error")
+	}
+}
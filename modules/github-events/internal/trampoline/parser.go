@@ -0,0 +1,96 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	"github.com/google/go-github/v60/github"
+)
+
+// ErrInvalidEventType is returned by Parsers for deliveries whose event type
+// is missing or invalid, wrapped with details.
+var ErrInvalidEventType = errors.New("invalid event type")
+
+// Delivery is a verified webhook delivery, as parsed by a Parser.
+type Delivery struct {
+	// Type is the CloudEvents type of the event, e.g.
+	// "dev.chainguard.github.push".
+	Type string
+	// EventType is the provider's type of the event, e.g. "push", which
+	// AllowedEventTypes, RetryByEventType and metrics refer to.
+	EventType string
+	// ID identifies the delivery, if the provider sends an ID. It keys the
+	// event in the Queue, and is reported to the AuditSink.
+	ID string
+	// Info is the metadata of the payload, from which the Server computes
+	// the subject, the filters and the built-in extensions of the event.
+	Info webhook.PayloadInfo
+	// Extensions are set on the event in addition to the built-in ones,
+	// which they override.
+	Extensions map[string]string
+}
+
+// Parser parses verified deliveries of a webhook provider, so that the
+// Server can forward deliveries of providers other than GitHub.
+type Parser interface {
+	// Parse parses the delivery r with the verified payload. If the event
+	// type is missing or invalid, the error wraps ErrInvalidEventType, and
+	// the delivery is rejected. Other errors report payloads that could
+	// only be partially parsed, which are forwarded with the Info that
+	// could be parsed.
+	Parse(r *http.Request, payload []byte) (Delivery, error)
+}
+
+// GitHubParser parses GitHub webhook deliveries. Events carry the action of
+// the payload, if any, as the "action" extension, the delivery ID as
+// webhook.DeliveryExtension, and the App installation, if any, as
+// webhook.InstallationExtension.
+type GitHubParser struct {
+	// EventTypePolicy controls the handling of invalid event types.
+	EventTypePolicy EventTypePolicy
+}
+
+var _ Parser = GitHubParser{}
+
+func (p GitHubParser) Parse(r *http.Request, payload []byte) (Delivery, error) {
+	// https://docs.github.com/en/webhooks/webhook-events-and-payloads#delivery-headers
+	t := github.WebHookType(r)
+	if t == "" {
+		return Delivery{}, fmt.Errorf("%w: missing %s header", ErrInvalidEventType, github.EventTypeHeader)
+	}
+	if normalized := normalizeEventType(t); normalized != t {
+		if p.EventTypePolicy == EventTypeReject {
+			return Delivery{}, fmt.Errorf("%w: %q", ErrInvalidEventType, t)
+		}
+		clog.FromContext(r.Context()).Warnf("sanitized event type %q to %q", t, normalized)
+		t = normalized
+	}
+
+	d := Delivery{
+		Type:       webhook.EventTypePrefix + t,
+		EventType:  t,
+		ID:         github.DeliveryID(r),
+		Extensions: map[string]string{},
+	}
+	info, err := webhook.ParsePayload(payload)
+	d.Info = info
+	if info.Action != "" {
+		d.Extensions["action"] = info.Action
+	}
+	if d.ID != "" {
+		d.Extensions[webhook.DeliveryExtension] = d.ID
+	}
+	if info.Installation.ID != 0 {
+		d.Extensions[webhook.InstallationExtension] = strconv.FormatInt(info.Installation.ID, 10)
+	}
+	return d, err
+}
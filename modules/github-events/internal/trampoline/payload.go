@@ -0,0 +1,312 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+)
+
+// extractHead returns the head commit SHA and branch for check_suite and
+// check_run events. These are present even when the check isn't associated
+// with any pull request.
+func extractHead(eventType string, info webhook.PayloadInfo) (sha, branch string) {
+	switch eventType {
+	case "check_suite":
+		return info.CheckSuite.HeadSHA, info.CheckSuite.HeadBranch
+	case "check_run":
+		return info.CheckRun.HeadSHA, info.CheckRun.CheckSuite.HeadBranch
+	}
+	return "", ""
+}
+
+// extractPullRequestRefs returns the base (target) and head (source) branches
+// of the pull request that pull_request and pull_request_review events are
+// about.
+func extractPullRequestRefs(eventType string, info webhook.PayloadInfo) (base, head string) {
+	switch eventType {
+	case "pull_request", "pull_request_review":
+		return info.PullRequest.Base.Ref, info.PullRequest.Head.Ref
+	}
+	return "", ""
+}
+
+// extractBranch returns the branch that push and pull_request events are
+// about: the pushed branch, or the base branch of the pull request. It is
+// empty for other events, and for pushes of tags.
+func extractBranch(eventType string, info webhook.PayloadInfo) string {
+	switch eventType {
+	case "push":
+		branch, _ := strings.CutPrefix(info.Ref, "refs/heads/")
+		if branch == info.Ref {
+			return ""
+		}
+		return branch
+	case "pull_request":
+		return info.PullRequest.Base.Ref
+	}
+	return ""
+}
+
+// extractDeployment returns the environment of deployment and
+// deployment_status events, and the state of deployment_status events, e.g.
+// "success".
+func extractDeployment(eventType string, info webhook.PayloadInfo) (environment, state string) {
+	switch eventType {
+	case "deployment":
+		return info.Deployment.Environment, ""
+	case "deployment_status":
+		environment = info.DeploymentStatus.Environment
+		if environment == "" {
+			environment = info.Deployment.Environment
+		}
+		return environment, info.DeploymentStatus.State
+	}
+	return "", ""
+}
+
+// extractVisibility returns the visibility of the repository the event is
+// about, e.g. "public", "private" or "internal". It is empty for events that
+// carry no repository, such as organization-level events.
+func extractVisibility(info webhook.PayloadInfo) string {
+	if v := info.Repository.Visibility; v != "" {
+		return v
+	}
+	switch p := info.Repository.Private; {
+	case p == nil:
+		return ""
+	case *p:
+		return "private"
+	default:
+		return "public"
+	}
+}
+
+// extractTimestamp returns when the event described by the payload happened,
+// as far as the payload tells. It is the zero time if the payload carries no
+// suitable timestamp.
+func extractTimestamp(eventType string, info webhook.PayloadInfo) time.Time {
+	switch eventType {
+	case "pull_request", "pull_request_review", "pull_request_review_comment":
+		return info.PullRequest.UpdatedAt
+	case "issues", "issue_comment":
+		return info.Issue.UpdatedAt
+	case "push":
+		// The head commit's timestamp is when it was authored, which can
+		// be long before it was pushed, e.g. for rebases or release tags.
+		if info.Repository.PushedAt != nil {
+			return info.Repository.PushedAt.Time
+		}
+	case "check_suite":
+		return info.CheckSuite.UpdatedAt
+	case "check_run":
+		if !info.CheckRun.CompletedAt.IsZero() {
+			return info.CheckRun.CompletedAt
+		}
+		return info.CheckRun.StartedAt
+	}
+	return time.Time{}
+}
+
+// defaultGitHubHost is the base URL of github.com, used to build URLs for
+// events unless a GitHub Enterprise Server host is configured.
+const defaultGitHubHost = "https://github.com"
+
+// repositoryURL returns the URL on host of the repository the event is
+// about, or empty if the payload doesn't fully identify one. Some
+// organization and app events carry a partial repository block, e.g. after
+// the repository was deleted or transferred.
+func repositoryURL(host string, info webhook.PayloadInfo) string {
+	if info.Repository.Owner.Login == "" || info.Repository.Name == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(host, "/"), info.Repository.Owner.Login, info.Repository.Name)
+}
+
+// extractPullRequestURL returns the URL of the pull request that
+// pull_request* events are about, or empty if it can't be determined.
+func extractPullRequestURL(host, eventType string, info webhook.PayloadInfo) string {
+	switch eventType {
+	case "pull_request", "pull_request_review", "pull_request_review_comment":
+	default:
+		return ""
+	}
+	repo := repositoryURL(host, info)
+	if repo == "" || info.PullRequest.Number <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/pull/%d", repo, info.PullRequest.Number)
+}
+
+// extractIssueURL returns the URL of the issue that issues and issue_comment
+// events are about, or empty if it can't be determined.
+func extractIssueURL(host, eventType string, info webhook.PayloadInfo) string {
+	switch eventType {
+	case "issues", "issue_comment":
+	default:
+		return ""
+	}
+	repo := repositoryURL(host, info)
+	if repo == "" || info.Issue.Number <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/issues/%d", repo, info.Issue.Number)
+}
+
+// extractDiscussionURL returns the URL of the discussion that discussion and
+// discussion_comment events are about, or empty if it can't be determined.
+func extractDiscussionURL(host, eventType string, info webhook.PayloadInfo) string {
+	switch eventType {
+	case "discussion", "discussion_comment":
+	default:
+		return ""
+	}
+	repo := repositoryURL(host, info)
+	if repo == "" || info.Discussion.Number <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/discussions/%d", repo, info.Discussion.Number)
+}
+
+// extractWorkflow returns the URL of the workflow run that workflow_run and
+// workflow_job events are about, the conclusion of workflow_run events, e.g.
+// "failure", and the status of workflow_job events, e.g. "in_progress". The
+// run URL is the html_url of workflow runs, and is built from the run ID for
+// jobs, whose html_url is the URL of the job. The conclusion is empty until
+// the run completes.
+func extractWorkflow(host, eventType string, info webhook.PayloadInfo) (runURL, conclusion, jobStatus string) {
+	switch eventType {
+	case "workflow_run":
+		return info.WorkflowRun.HTMLURL, info.WorkflowRun.Conclusion, ""
+	case "workflow_job":
+		if repo := repositoryURL(host, info); repo != "" && info.WorkflowJob.RunID > 0 {
+			runURL = fmt.Sprintf("%s/actions/runs/%d", repo, info.WorkflowJob.RunID)
+		}
+		return runURL, "", info.WorkflowJob.Status
+	}
+	return "", "", ""
+}
+
+// extractInstallation returns the action of installation events, e.g.
+// "created" or "suspend", and the comma-separated full names of the
+// repositories added to and removed from the installation by
+// installation_repositories events.
+func extractInstallation(eventType string, info webhook.PayloadInfo) (action, added, removed string) {
+	switch eventType {
+	case "installation":
+		return info.Action, "", ""
+	case "installation_repositories":
+		return "", joinFullNames(info.RepositoriesAdded), joinFullNames(info.RepositoriesRemoved)
+	}
+	return "", "", ""
+}
+
+// extractStatus returns the commit SHA, state and context of status events.
+func extractStatus(eventType string, info webhook.PayloadInfo) (sha, state, context string) {
+	if eventType != "status" {
+		return "", "", ""
+	}
+	return info.SHA, info.State, info.Context
+}
+
+// joinFullNames returns the comma-separated full names of repos, skipping
+// any without one.
+func joinFullNames(repos []webhook.RepositoryInfo) string {
+	names := make([]string, 0, len(repos))
+	for _, r := range repos {
+		if r.FullName != "" {
+			names = append(names, r.FullName)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// FileChange is a file changed by a push, and how: "added", "removed" or
+// "modified".
+type FileChange struct {
+	Path   string
+	Change string
+}
+
+// changedFiles returns the files changed by the commits of push events, in
+// the order they were first changed. Files changed by several commits report
+// the change of the last one, except that files added and then modified are
+// still reported as added.
+func changedFiles(eventType string, info webhook.PayloadInfo) []FileChange {
+	if eventType != "push" {
+		return nil
+	}
+	var files []FileChange
+	index := map[string]int{}
+	record := func(path, change string) {
+		i, ok := index[path]
+		if !ok {
+			index[path] = len(files)
+			files = append(files, FileChange{Path: path, Change: change})
+			return
+		}
+		if change == "modified" && files[i].Change == "added" {
+			return
+		}
+		files[i].Change = change
+	}
+	for _, c := range info.Commits {
+		for _, p := range c.Added {
+			record(p, "added")
+		}
+		for _, p := range c.Removed {
+			record(p, "removed")
+		}
+		for _, p := range c.Modified {
+			record(p, "modified")
+		}
+	}
+	return files
+}
+
+// isPullRequestMerged returns whether the event reports that a pull request
+// was merged, i.e. closed with its changes merged.
+func isPullRequestMerged(eventType string, info webhook.PayloadInfo) bool {
+	return eventType == "pull_request" && info.Action == "closed" && info.PullRequest.Merged
+}
+
+// isDraftPullRequest returns whether the event is about a draft pull
+// request, except for the ready_for_review action that ends the draft.
+func isDraftPullRequest(eventType string, info webhook.PayloadInfo) bool {
+	switch eventType {
+	case "pull_request":
+		return info.PullRequest.Draft && info.Action != "ready_for_review"
+	case "pull_request_review", "pull_request_review_comment":
+		return info.PullRequest.Draft
+	}
+	return false
+}
+
+// extractLabels returns the label that was added or removed by labeled and
+// unlabeled pull_request and issues events, and the comma-separated names of
+// the labels the pull request or issue has afterwards.
+func extractLabels(eventType string, info webhook.PayloadInfo) (label, labels string) {
+	if info.Action != "labeled" && info.Action != "unlabeled" {
+		return "", ""
+	}
+	var current []webhook.LabelInfo
+	switch eventType {
+	case "pull_request":
+		current = info.PullRequest.Labels
+	case "issues":
+		current = info.Issue.Labels
+	default:
+		return "", ""
+	}
+	names := make([]string, 0, len(current))
+	for _, l := range current {
+		names = append(names, l.Name)
+	}
+	return info.Label.Name, strings.Join(names, ",")
+}
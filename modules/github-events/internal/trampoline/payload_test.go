@@ -0,0 +1,246 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"testing"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestURLBuilders(t *testing.T) {
+	info := webhook.PayloadInfo{
+		PullRequest: webhook.PullRequestInfo{Number: 42},
+		Issue:       webhook.IssueInfo{Number: 7},
+		Discussion:  webhook.DiscussionInfo{Number: 3},
+	}
+	info.Repository.Name = "repo"
+	info.Repository.Owner.Login = "org"
+
+	for _, tt := range []struct {
+		name, host string
+		fn         func(host, eventType string, info webhook.PayloadInfo) string
+		eventType  string
+		want       string
+	}{
+		{"pull request", defaultGitHubHost, extractPullRequestURL, "pull_request", "https://github.com/org/repo/pull/42"},
+		{"enterprise pull request", "https://github.example.com/", extractPullRequestURL, "pull_request", "https://github.example.com/org/repo/pull/42"},
+		{"enterprise issue", "https://github.example.com", extractIssueURL, "issues", "https://github.example.com/org/repo/issues/7"},
+		{"enterprise discussion", "https://github.example.com", extractDiscussionURL, "discussion", "https://github.example.com/org/repo/discussions/3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(tt.host, tt.eventType, info); got != tt.want {
+				t.Errorf("got %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPullRequestRefs(t *testing.T) {
+	info := webhook.PayloadInfo{
+		PullRequest: webhook.PullRequestInfo{
+			Base: webhook.RefInfo{Ref: "main"},
+			Head: webhook.RefInfo{Ref: "feature"},
+		},
+	}
+
+	for _, tt := range []struct {
+		name       string
+		eventType  string
+		info       webhook.PayloadInfo
+		base, head string
+	}{
+		{"pull request", "pull_request", info, "main", "feature"},
+		{"pull request review", "pull_request_review", info, "main", "feature"},
+		{"missing refs", "pull_request", webhook.PayloadInfo{}, "", ""},
+		{"other events are ignored", "issues", info, "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			base, head := extractPullRequestRefs(tt.eventType, tt.info)
+			if base != tt.base || head != tt.head {
+				t.Errorf("extractPullRequestRefs() = (%q, %q), wanted (%q, %q)", base, head, tt.base, tt.head)
+			}
+		})
+	}
+}
+
+func TestExtractWorkflow(t *testing.T) {
+	repo := webhook.RepositoryInfo{Name: "repo"}
+	repo.Owner.Login = "org"
+
+	for _, tt := range []struct {
+		name                          string
+		eventType                     string
+		info                          webhook.PayloadInfo
+		runURL, conclusion, jobStatus string
+	}{{
+		name:      "completed workflow run",
+		eventType: "workflow_run",
+		info: webhook.PayloadInfo{
+			Repository:  repo,
+			WorkflowRun: webhook.WorkflowRunInfo{HTMLURL: "https://github.com/org/repo/actions/runs/30433642", Conclusion: "failure"},
+		},
+		runURL:     "https://github.com/org/repo/actions/runs/30433642",
+		conclusion: "failure",
+	}, {
+		name:      "requested workflow run",
+		eventType: "workflow_run",
+		info: webhook.PayloadInfo{
+			Repository:  repo,
+			WorkflowRun: webhook.WorkflowRunInfo{HTMLURL: "https://github.com/org/repo/actions/runs/30433642"},
+		},
+		runURL: "https://github.com/org/repo/actions/runs/30433642",
+	}, {
+		name:      "workflow run without fields",
+		eventType: "workflow_run",
+		info:      webhook.PayloadInfo{Repository: repo},
+	}, {
+		name:      "workflow job",
+		eventType: "workflow_job",
+		info: webhook.PayloadInfo{
+			Repository:  repo,
+			WorkflowJob: webhook.WorkflowJobInfo{RunID: 30433642, Status: "in_progress"},
+		},
+		runURL:    "https://github.example.com/org/repo/actions/runs/30433642",
+		jobStatus: "in_progress",
+	}, {
+		name:      "workflow job without run ID",
+		eventType: "workflow_job",
+		info: webhook.PayloadInfo{
+			Repository:  repo,
+			WorkflowJob: webhook.WorkflowJobInfo{Status: "queued"},
+		},
+		jobStatus: "queued",
+	}, {
+		name:      "workflow job without repository",
+		eventType: "workflow_job",
+		info: webhook.PayloadInfo{
+			WorkflowJob: webhook.WorkflowJobInfo{RunID: 30433642},
+		},
+	}, {
+		name:      "other events are ignored",
+		eventType: "check_run",
+		info: webhook.PayloadInfo{
+			Repository:  repo,
+			WorkflowRun: webhook.WorkflowRunInfo{HTMLURL: "https://github.com/org/repo/actions/runs/30433642", Conclusion: "success"},
+			WorkflowJob: webhook.WorkflowJobInfo{RunID: 30433642, Status: "completed"},
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			runURL, conclusion, jobStatus := extractWorkflow("https://github.example.com", tt.eventType, tt.info)
+			if runURL != tt.runURL || conclusion != tt.conclusion || jobStatus != tt.jobStatus {
+				t.Errorf("extractWorkflow() = (%q, %q, %q), wanted (%q, %q, %q)", runURL, conclusion, jobStatus, tt.runURL, tt.conclusion, tt.jobStatus)
+			}
+		})
+	}
+}
+
+func TestExtractInstallation(t *testing.T) {
+	repos := func(names ...string) []webhook.RepositoryInfo {
+		var rs []webhook.RepositoryInfo
+		for _, n := range names {
+			rs = append(rs, webhook.RepositoryInfo{FullName: n})
+		}
+		return rs
+	}
+
+	for _, tt := range []struct {
+		name                   string
+		eventType              string
+		info                   webhook.PayloadInfo
+		action, added, removed string
+	}{{
+		name:      "installation created",
+		eventType: "installation",
+		info:      webhook.PayloadInfo{Action: "created", RepositoriesAdded: repos("org/repo")},
+		action:    "created",
+	}, {
+		name:      "installation without action",
+		eventType: "installation",
+	}, {
+		name:      "repositories added",
+		eventType: "installation_repositories",
+		info:      webhook.PayloadInfo{Action: "added", RepositoriesAdded: repos("org/a", "org/b")},
+		added:     "org/a,org/b",
+	}, {
+		name:      "repositories removed",
+		eventType: "installation_repositories",
+		info:      webhook.PayloadInfo{Action: "removed", RepositoriesRemoved: repos("org/a", "")},
+		removed:   "org/a",
+	}, {
+		name:      "repositories without changes",
+		eventType: "installation_repositories",
+		info:      webhook.PayloadInfo{Action: "added"},
+	}, {
+		name:      "other events are ignored",
+		eventType: "push",
+		info:      webhook.PayloadInfo{Action: "created", RepositoriesAdded: repos("org/a"), RepositoriesRemoved: repos("org/b")},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			action, added, removed := extractInstallation(tt.eventType, tt.info)
+			if action != tt.action || added != tt.added || removed != tt.removed {
+				t.Errorf("extractInstallation() = (%q, %q, %q), wanted (%q, %q, %q)", action, added, removed, tt.action, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestExtractStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name                string
+		eventType           string
+		info                webhook.PayloadInfo
+		sha, state, context string
+	}{{
+		name:      "status",
+		eventType: "status",
+		info:      webhook.PayloadInfo{SHA: "abc123", State: "success", Context: "ci/build"},
+		sha:       "abc123",
+		state:     "success",
+		context:   "ci/build",
+	}, {
+		name:      "status without context",
+		eventType: "status",
+		info:      webhook.PayloadInfo{SHA: "abc123", State: "pending"},
+		sha:       "abc123",
+		state:     "pending",
+	}, {
+		name:      "other events are ignored",
+		eventType: "deployment_status",
+		info:      webhook.PayloadInfo{SHA: "abc123", State: "success", Context: "ci/build"},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			sha, state, context := extractStatus(tt.eventType, tt.info)
+			if sha != tt.sha || state != tt.state || context != tt.context {
+				t.Errorf("extractStatus() = (%q, %q, %q), wanted (%q, %q, %q)", sha, state, context, tt.sha, tt.state, tt.context)
+			}
+		})
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	info := webhook.PayloadInfo{Commits: []webhook.CommitInfo{{
+		Added:    []string{"new.go", "tmp.go"},
+		Modified: []string{"main.go"},
+	}, {
+		Removed:  []string{"tmp.go", "old.go"},
+		Modified: []string{"new.go", "main.go"},
+	}}}
+
+	want := []FileChange{
+		{"new.go", "added"},
+		{"tmp.go", "removed"},
+		{"main.go", "modified"},
+		{"old.go", "removed"},
+	}
+	if diff := cmp.Diff(want, changedFiles("push", info)); diff != "" {
+		t.Errorf("changedFiles() (-want +got): %s", diff)
+	}
+	if got := changedFiles("pull_request", info); got != nil {
+		t.Errorf("changedFiles() = %v for pull_request, wanted nil", got)
+	}
+}
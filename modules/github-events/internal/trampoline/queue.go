@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/chainguard-dev/clog"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+)
+
+// Queue stores serialized events for later delivery, e.g. backed by GCS or
+// Pub/Sub, or delivers them in the background like AsyncQueue.
+type Queue interface {
+	// Enqueue stores the event under key. Enqueueing the same key again
+	// must not result in a second delivery. Events with the same non-empty
+	// orderingKey must be delivered in the order they were enqueued.
+	Enqueue(ctx context.Context, key, orderingKey string, event []byte) error
+}
+
+// enqueue wraps the payload in the event envelope and stores the event in
+// queue, writing 202 to w once it is stored, and 503 if it can't be. Payloads
+// that can't be wrapped are rejected with a 400, like forward does.
+func enqueue(ctx context.Context, queue Queue, key, orderingKey string, w http.ResponseWriter, event cloudevents.Event, payload []byte) {
+	log := clog.FromContext(ctx)
+
+	if key == "" {
+		key = uuid.NewString()
+	}
+	b, err := serialize(ctx, key, event, payload)
+	if err != nil {
+		log.Errorf("failed to serialize event: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := queue.Enqueue(context.WithoutCancel(ctx), key, orderingKey, b); err != nil {
+		log.Errorf("failed to enqueue event %s: %v", key, err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "failed to enqueue event: %v", err)
+		return
+	}
+	log.Debugf("event %s enqueued", key)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// deadLetter stores an event that failed delivery in queue under key, so
+// that it can be replayed. Failures are logged, as the delivery has already
+// failed.
+func deadLetter(ctx context.Context, queue Queue, key string, event cloudevents.Event, payload []byte) {
+	log := clog.FromContext(ctx)
+
+	if key == "" {
+		key = uuid.NewString()
+	}
+	b, err := serialize(ctx, key, event, payload)
+	if err != nil {
+		log.Errorf("failed to serialize dead-lettered event: %v", err)
+		return
+	}
+	if err := queue.Enqueue(context.WithoutCancel(ctx), key, "", b); err != nil {
+		log.Errorf("failed to dead-letter event %s: %v", key, err)
+		return
+	}
+	log.Infof("dead-lettered event %s", key)
+}
+
+// serialize wraps the payload in the event envelope and serializes the
+// event, whose ID defaults to key.
+func serialize(ctx context.Context, key string, event cloudevents.Event, payload []byte) ([]byte, error) {
+	if event.ID() == "" {
+		event.SetID(key)
+	}
+	if err := prepare(ctx, &event, payload); err != nil {
+		return nil, err
+	}
+	return json.Marshal(event)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// memQueue is an in-memory Queue.
+type memQueue struct {
+	m            sync.Mutex
+	items        map[string][]byte
+	orderingKeys map[string]string
+	err          error
+}
+
+func (q *memQueue) Enqueue(_ context.Context, key, orderingKey string, event []byte) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.m.Lock()
+	defer q.m.Unlock()
+	if q.items == nil {
+		q.items = make(map[string][]byte)
+		q.orderingKeys = make(map[string]string)
+	}
+	q.items[key] = event
+	q.orderingKeys[key] = orderingKey
+	return nil
+}
+
+func TestTrampolineQueue(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	queue := &memQueue{}
+
+	req := newRequest(t, "push", secret, map[string]any{"ref": "refs/heads/main"})
+	req.Header.Set("X-GitHub-Delivery", "delivery-1")
+	rec := httptest.NewRecorder()
+	NewServer(client, [][]byte{secret}, ServerOptions{Queue: queue}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+	if len(client.events) != 0 {
+		t.Errorf("sent %d events, wanted 0", len(client.events))
+	}
+
+	b, ok := queue.items["delivery-1"]
+	if !ok {
+		t.Fatalf("queue has no item for delivery-1: %v", queue.items)
+	}
+	var event cloudevents.Event
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+	if got, want := event.Type(), "dev.chainguard.github.push"; got != want {
+		t.Errorf("Type() = %q, wanted %q", got, want)
+	}
+	if got, want := event.ID(), "delivery-1"; got != want {
+		t.Errorf("ID() = %q, wanted %q", got, want)
+	}
+	var data struct {
+		Body map[string]any `json:"body"`
+	}
+	if err := event.DataAs(&data); err != nil {
+		t.Fatalf("DataAs() = %v", err)
+	}
+	if got, want := data.Body["ref"], "refs/heads/main"; got != want {
+		t.Errorf("body ref = %v, wanted %v", got, want)
+	}
+}
+
+func TestTrampolineQueueError(t *testing.T) {
+	secret := []byte("hunter2")
+	queue := &memQueue{err: errors.New("bucket unavailable")}
+
+	rec := httptest.NewRecorder()
+	NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{Queue: queue}).ServeHTTP(rec, newRequest(t, "push", secret, map[string]any{}))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestTrampolineDeadLetter(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name   string
+		result cloudevents.Result
+		opts   ServerOptions
+		want   bool
+	}{{
+		name: "delivered",
+		want: false,
+	}, {
+		name:   "nack",
+		result: cloudevents.NewReceipt(false, "nope"),
+		want:   true,
+	}, {
+		name:   "dropped nack",
+		result: cloudevents.NewReceipt(false, "nope"),
+		opts:   ServerOptions{DropNACKs: true},
+		want:   true,
+	}, {
+		name:   "undelivered",
+		result: errors.New("connection refused"),
+		want:   true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &memQueue{}
+			tt.opts.DeadLetter = queue
+
+			req := newRequest(t, "push", secret, map[string]any{"ref": "refs/heads/main"})
+			req.Header.Set("X-GitHub-Delivery", "delivery-1")
+			rec := httptest.NewRecorder()
+			NewServer(&fakeClient{result: tt.result}, [][]byte{secret}, tt.opts).ServeHTTP(rec, req)
+
+			b, ok := queue.items["delivery-1"]
+			if ok != tt.want {
+				t.Fatalf("dead-lettered = %t, wanted %t", ok, tt.want)
+			}
+			if !ok {
+				return
+			}
+			var event cloudevents.Event
+			if err := json.Unmarshal(b, &event); err != nil {
+				t.Fatalf("json.Unmarshal() = %v", err)
+			}
+			if got, want := event.Type(), "dev.chainguard.github.push"; got != want {
+				t.Errorf("Type() = %q, wanted %q", got, want)
+			}
+			var data struct {
+				Body map[string]any `json:"body"`
+			}
+			if err := event.DataAs(&data); err != nil {
+				t.Fatalf("DataAs() = %v", err)
+			}
+			if got, want := data.Body["ref"], "refs/heads/main"; got != want {
+				t.Errorf("body ref = %v, wanted %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTrampolineQueueOrderingKey(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name    string
+		opts    ServerOptions
+		payload map[string]any
+		want    string
+	}{{
+		name:    "repository",
+		payload: map[string]any{"repository": map[string]any{"full_name": "org/repo"}},
+		want:    "org/repo",
+	}, {
+		name:    "organization",
+		payload: map[string]any{"organization": map[string]any{"login": "org"}},
+		want:    "org",
+	}, {
+		name:    "none",
+		payload: map[string]any{},
+	}, {
+		name: "custom",
+		opts: ServerOptions{
+			OrderingKeyFunc: func(eventType string, info webhook.PayloadInfo) string {
+				return eventType + ":" + info.Repository.FullName
+			},
+		},
+		payload: map[string]any{"repository": map[string]any{"full_name": "org/repo"}},
+		want:    "push:org/repo",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &memQueue{}
+			tt.opts.Queue = queue
+
+			req := newRequest(t, "push", secret, tt.payload)
+			req.Header.Set("X-GitHub-Delivery", "delivery-1")
+			rec := httptest.NewRecorder()
+			NewServer(&fakeClient{}, [][]byte{secret}, tt.opts).ServeHTTP(rec, req)
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+			}
+
+			var event cloudevents.Event
+			if err := json.Unmarshal(queue.items["delivery-1"], &event); err != nil {
+				t.Fatalf("json.Unmarshal() = %v", err)
+			}
+			got, _ := event.Extensions()["orderingkey"].(string)
+			if got != tt.want {
+				t.Errorf("orderingkey = %q, wanted %q", got, tt.want)
+			}
+			if got := queue.orderingKeys["delivery-1"]; got != tt.want {
+				t.Errorf("Enqueue() ordering key = %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrampolineQueueMalformedPayload(t *testing.T) {
+	secret := []byte("hunter2")
+	queue := &memQueue{}
+
+	// The payload can never be wrapped, so it must not be retried.
+	body := []byte("not json")
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", signature(secret, body))
+	rec := httptest.NewRecorder()
+	NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{Queue: queue}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusBadRequest)
+	}
+	if len(queue.items) != 0 {
+		t.Errorf("enqueued %d events, wanted 0", len(queue.items))
+	}
+}
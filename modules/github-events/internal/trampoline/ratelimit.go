@@ -0,0 +1,106 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+var mRateLimited = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "trampoline_rate_limited_total",
+		Help: "The number of webhook deliveries rejected with a 429 by the per-organization rate limit, by bucket class: \"org\" or \"default\"",
+	},
+	[]string{"key_class"},
+)
+
+// RateLimit configures a token bucket rate limit.
+type RateLimit struct {
+	// Rate is the number of deliveries allowed per second on average.
+	// Zero disables the limit.
+	Rate float64
+	// Burst is the number of deliveries allowed at once, which defaults to
+	// one second's worth of Rate, rounded up.
+	Burst int
+}
+
+// orgLimiter rate limits deliveries per organization, with a token bucket
+// for each organization and a shared one for deliveries without one. Buckets
+// are evicted once they have been idle for long enough to be full again, so
+// that they don't accumulate for every organization ever seen.
+type orgLimiter struct {
+	limit RateLimit
+	// idle is how long an unused bucket takes to refill, after which it is
+	// the same as a new one.
+	idle time.Duration
+
+	m         sync.Mutex
+	buckets   map[string]*orgBucket
+	lastSweep time.Time
+}
+
+// orgBucket is the token bucket of an organization.
+type orgBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newOrgLimiter(limit RateLimit) *orgLimiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.Rate))
+	}
+	return &orgLimiter{
+		limit:   limit,
+		idle:    time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second)),
+		buckets: map[string]*orgBucket{},
+	}
+}
+
+// allow takes a token from the bucket of org, or the default bucket if org
+// is empty. If the bucket is empty, it returns false and how long until a
+// token is available.
+func (l *orgLimiter) allow(org string, now time.Time) (bool, time.Duration) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.idle {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) >= l.idle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[org]
+	if !ok {
+		b = &orgBucket{limiter: rate.NewLimiter(rate.Limit(l.limit.Rate), l.limit.Burst)}
+		l.buckets[org] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// retryAfter formats d as the value of a Retry-After header, in whole
+// seconds rounded up.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
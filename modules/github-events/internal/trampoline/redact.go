@@ -0,0 +1,81 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// redact removes the fields at the given dot-separated paths, e.g.
+// "sender.email", from the JSON object payload. Paths only descend through
+// objects, and missing fields are ignored. The rest of the payload is kept
+// byte for byte, so that fields unknown to the trampoline survive.
+func redact(payload []byte, paths []string) ([]byte, error) {
+	for _, p := range paths {
+		var err error
+		if payload, err = removePath(payload, strings.Split(p, ".")); err != nil {
+			return nil, fmt.Errorf("redacting %q: %w", p, err)
+		}
+	}
+	return payload, nil
+}
+
+// removePath removes the field at path from obj, if obj is an object and
+// the field exists.
+func removePath(obj []byte, path []string) ([]byte, error) {
+	if len(path) == 0 {
+		return obj, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(obj))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return obj, nil
+	}
+	for first := true; dec.More(); first = false {
+		// start is just after the previous member, or the opening brace,
+		// so [start, end) spans the member including its leading comma.
+		start := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		end := dec.InputOffset()
+		if tok != path[0] {
+			continue
+		}
+
+		if len(path) > 1 {
+			inner, err := removePath(value, path[1:])
+			if err != nil {
+				return nil, err
+			}
+			return splice(obj, end-int64(len(value)), end, inner), nil
+		}
+		if first && dec.More() {
+			// Drop the comma that follows instead of the one that leads.
+			end += int64(bytes.IndexByte(obj[end:], ',') + 1)
+		}
+		// Remove any repeats of the key too.
+		return removePath(splice(obj, start, end, nil), path)
+	}
+	return obj, nil
+}
+
+// splice returns b with b[start:end] replaced by repl.
+func splice(b []byte, start, end int64, repl []byte) []byte {
+	out := make([]byte, 0, int64(len(b))-(end-start)+int64(len(repl)))
+	out = append(out, b[:start]...)
+	out = append(out, repl...)
+	return append(out, b[end:]...)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		payload string
+		paths   []string
+		want    string
+	}{{
+		name:    "no paths",
+		payload: `{"a": 1}`,
+		want:    `{"a": 1}`,
+	}, {
+		name:    "first field",
+		payload: `{"a": 1, "b": {"c": 2}}`,
+		paths:   []string{"a"},
+		want:    `{ "b": {"c": 2}}`,
+	}, {
+		name:    "last field",
+		payload: `{"a": 1, "b": {"c": 2}}`,
+		paths:   []string{"b"},
+		want:    `{"a": 1}`,
+	}, {
+		name:    "only field",
+		payload: `{"a": 1}`,
+		paths:   []string{"a"},
+		want:    `{}`,
+	}, {
+		name:    "nested field",
+		payload: `{"sender": {"login": "octocat", "email": "octocat@example.com", "x": [1,2]}, "unknown": {"kept":   true}}`,
+		paths:   []string{"sender.email"},
+		want:    `{"sender": {"login": "octocat", "x": [1,2]}, "unknown": {"kept":   true}}`,
+	}, {
+		name:    "several fields",
+		payload: `{"a": 1, "b": 2, "c": 3}`,
+		paths:   []string{"a", "c"},
+		want:    `{ "b": 2}`,
+	}, {
+		name:    "repeated key",
+		payload: `{"a": 1, "b": 2, "a": 3}`,
+		paths:   []string{"a"},
+		want:    `{ "b": 2}`,
+	}, {
+		name:    "missing field",
+		payload: `{"a": {"b": 1}}`,
+		paths:   []string{"a.c", "d"},
+		want:    `{"a": {"b": 1}}`,
+	}, {
+		name:    "through a non-object",
+		payload: `{"a": [{"b": 1}]}`,
+		paths:   []string{"a.b"},
+		want:    `{"a": [{"b": 1}]}`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := redact([]byte(tt.payload), tt.paths)
+			if err != nil {
+				t.Fatalf("redact() = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("redact() = %s, wanted %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactInvalid(t *testing.T) {
+	if _, err := redact([]byte(`{"a": `), []string{"a"}); err == nil {
+		t.Error("redact() = nil, wanted error")
+	}
+}
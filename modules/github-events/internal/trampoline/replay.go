@@ -0,0 +1,92 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chainguard-dev/clog"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// ErrEventNotFound is returned by an EventStore that has no event for a key.
+var ErrEventNotFound = errors.New("event not found")
+
+// EventStore retrieves serialized events stored by delivery ID, e.g. events
+// that ServerOptions.DeadLetter stored after they failed delivery.
+type EventStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// ReplayServer re-sends stored events on request. Requests must carry one of
+// the tokens as a bearer token, and name the event with the delivery query
+// parameter:
+//
+//	POST /replay?delivery=<delivery ID>
+type ReplayServer struct {
+	client cloudevents.Client
+	store  EventStore
+	tokens [][]byte
+}
+
+var _ http.Handler = (*ReplayServer)(nil)
+
+// NewReplayServer returns a ReplayServer re-sending events from store to
+// client. The tokens should be distinct from the webhook secrets.
+func NewReplayServer(client cloudevents.Client, store EventStore, tokens [][]byte) *ReplayServer {
+	return &ReplayServer{
+		client: client,
+		store:  store,
+		tokens: tokens,
+	}
+}
+
+func (s *ReplayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := clog.FromContext(ctx)
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !matchesAny([]byte(token), s.tokens) {
+		log.Errorf("rejected replay request with missing or bad token")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	key := r.URL.Query().Get("delivery")
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "missing delivery parameter")
+		return
+	}
+	log = log.With("delivery", key)
+
+	b, err := s.store.Get(ctx, key)
+	if errors.Is(err, ErrEventNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Errorf("failed to read stored event: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var event cloudevents.Event
+	if err := json.Unmarshal(b, &event); err != nil {
+		log.Errorf("failed to parse stored event: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("replaying event")
+	deliver(clog.WithLogger(ctx, log), s.client, RetryPolicy{}.withDefaults(), false, w, event)
+}
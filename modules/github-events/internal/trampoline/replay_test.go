@@ -0,0 +1,95 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func (q *memQueue) Get(_ context.Context, key string) ([]byte, error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	b, ok := q.items[key]
+	if !ok {
+		return nil, ErrEventNotFound
+	}
+	return b, nil
+}
+
+func newReplayRequest(delivery, token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/replay?delivery="+delivery, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestReplay(t *testing.T) {
+	event := cloudevents.NewEvent()
+	event.SetID("delivery-1")
+	event.SetType("dev.chainguard.github.push")
+	event.SetSource("github.com")
+	event.SetExtension("headsha", "abc123")
+	if err := event.SetData(cloudevents.ApplicationJSON, map[string]any{"body": map[string]any{"ref": "main"}}); err != nil {
+		t.Fatalf("SetData() = %v", err)
+	}
+	b, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	store := &memQueue{items: map[string][]byte{"delivery-1": b}}
+
+	client := &fakeClient{}
+	srv := NewReplayServer(client, store, [][]byte{[]byte("replay-token")})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newReplayRequest("delivery-1", "replay-token"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+	if diff := cmp.Diff(event.String(), client.events[0].String()); diff != "" {
+		t.Errorf("replayed event (-want +got): %s", diff)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	store := &memQueue{items: map[string][]byte{}}
+	srv := NewReplayServer(&fakeClient{}, store, [][]byte{[]byte("replay-token")})
+
+	for _, tt := range []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"no token", newReplayRequest("delivery-1", ""), http.StatusForbidden},
+		{"webhook secret", newReplayRequest("delivery-1", "hunter2"), http.StatusForbidden},
+		{"missing delivery", newReplayRequest("", "replay-token"), http.StatusBadRequest},
+		{"unknown delivery", newReplayRequest("delivery-2", "replay-token"), http.StatusNotFound},
+		{"wrong method", func() *http.Request {
+			req := newReplayRequest("delivery-1", "replay-token")
+			req.Method = http.MethodGet
+			return req
+		}(), http.StatusMethodNotAllowed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
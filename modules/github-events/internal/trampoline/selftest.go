@@ -0,0 +1,122 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// SelfTestEventType is the type of the synthetic events sent by the
+// SelfTestServer, which consumers of GitHub events can safely ignore.
+const SelfTestEventType = "dev.chainguard.trampoline.selftest"
+
+var mSelfTests = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "trampoline_self_tests_total",
+		Help: "The number of self-test events sent to the ingress, by outcome",
+	},
+	[]string{"outcome"},
+)
+
+// SelfTestServer sends a synthetic event through the same client as real
+// deliveries on request, so that probers can detect a broken forwarding path
+// before webhooks fail. Requests must carry one of the tokens as a bearer
+// token:
+//
+//	POST /selftest
+//
+// It responds with a selfTestResult, with a 200 if the ingress acknowledged
+// the event and a 503 otherwise.
+type SelfTestServer struct {
+	client cloudevents.Client
+	source string
+	tokens [][]byte
+}
+
+var _ http.Handler = (*SelfTestServer)(nil)
+
+// NewSelfTestServer returns a SelfTestServer sending events to client, from
+// source if it is set and the Host of the request otherwise. The tokens
+// should be distinct from the webhook secrets and replay tokens.
+func NewSelfTestServer(client cloudevents.Client, source string, tokens [][]byte) *SelfTestServer {
+	return &SelfTestServer{
+		client: client,
+		source: source,
+		tokens: tokens,
+	}
+}
+
+// selfTestResult is the response body of the SelfTestServer.
+type selfTestResult struct {
+	ID       string  `json:"id"`
+	OK       bool    `json:"ok"`
+	Duration float64 `json:"duration_seconds"`
+	Result   string  `json:"result,omitempty"`
+}
+
+func (s *SelfTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := clog.FromContext(ctx)
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !matchesAny([]byte(token), s.tokens) {
+		log.Errorf("rejected self-test request with missing or bad token")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.New().String())
+	event.SetType(SelfTestEventType)
+	if s.source != "" {
+		event.SetSource(s.source)
+	} else {
+		event.SetSource(r.Host)
+	}
+	log = log.With("event-id", event.ID())
+	if err := prepare(ctx, &event, []byte("{}")); err != nil {
+		log.Errorf("failed to set data: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	start := time.Now()
+	ceresult := s.client.Send(ctx, event)
+	res := selfTestResult{
+		ID:       event.ID(),
+		OK:       cloudevents.IsACK(ceresult),
+		Duration: time.Since(start).Seconds(),
+	}
+	status := http.StatusOK
+	if res.OK {
+		mSelfTests.With(prometheus.Labels{"outcome": "success"}).Inc()
+		log.Debugf("self-test event forwarded in %.3fs", res.Duration)
+	} else {
+		mSelfTests.With(prometheus.Labels{"outcome": "failure"}).Inc()
+		log.Errorf("self-test event failed: %v", ceresult)
+		res.Result = ceresult.Error()
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Warnf("failed to write self-test result: %v", err)
+	}
+}
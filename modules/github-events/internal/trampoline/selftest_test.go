@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newSelfTestRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/selftest", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestSelfTest(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		result      cloudevents.Result
+		wantStatus  int
+		wantOutcome string
+	}{
+		{"success", cloudevents.ResultACK, http.StatusOK, "success"},
+		{"failure", cloudevents.NewReceipt(false, "nope"), http.StatusServiceUnavailable, "failure"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{result: tt.result}
+			srv := NewSelfTestServer(client, "trampoline.example.com", [][]byte{[]byte("selftest-token")})
+
+			before := testutil.ToFloat64(mSelfTests.WithLabelValues(tt.wantOutcome))
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newSelfTestRequest("selftest-token"))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, wanted %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := testutil.ToFloat64(mSelfTests.WithLabelValues(tt.wantOutcome)) - before; got != 1 {
+				t.Errorf("%s count increased by %f, wanted 1", tt.wantOutcome, got)
+			}
+
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			event := client.events[0]
+			if got := event.Type(); got != SelfTestEventType {
+				t.Errorf("Type() = %q, wanted %q", got, SelfTestEventType)
+			}
+			if got, want := event.Source(), "trampoline.example.com"; got != want {
+				t.Errorf("Source() = %q, wanted %q", got, want)
+			}
+
+			var res selfTestResult
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("Decode() = %v", err)
+			}
+			if res.ID != event.ID() || res.OK != (tt.wantOutcome == "success") || res.Duration < 0 {
+				t.Errorf("result = %+v, wanted ID %q and OK %t", res, event.ID(), tt.wantOutcome == "success")
+			}
+		})
+	}
+}
+
+func TestSelfTestErrors(t *testing.T) {
+	client := &fakeClient{}
+	srv := NewSelfTestServer(client, "", [][]byte{[]byte("selftest-token")})
+
+	for _, tt := range []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"missing token", newSelfTestRequest(""), http.StatusForbidden},
+		{"bad token", newSelfTestRequest("wrong"), http.StatusForbidden},
+		{"wrong method", func() *http.Request {
+			req := newSelfTestRequest("selftest-token")
+			req.Method = http.MethodGet
+			return req
+		}(), http.StatusMethodNotAllowed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+		})
+	}
+	if len(client.events) != 0 {
+		t.Errorf("sent %d events, wanted 0", len(client.events))
+	}
+}
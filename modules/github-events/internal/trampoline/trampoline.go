@@ -0,0 +1,880 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package trampoline verifies incoming webhook deliveries and forwards them
+// to an event ingress as CloudEvents.
+//
+// Senders, e.g. GitHub or a Pub/Sub push subscription, redeliver based on the
+// response status, so the servers in this package respond with:
+//
+//   - 2xx only once the event is durably handled: forwarded, enqueued, or
+//     deliberately dropped, e.g. by AllowedEventTypes.
+//   - 4xx for requests that can never succeed, e.g. bad signatures or
+//     malformed payloads, so that they aren't retried.
+//   - 5xx for failures that may succeed on redelivery, e.g. an unreachable
+//     or NACKing ingress.
+package trampoline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	retryDelay = 10 * time.Millisecond
+	maxRetry   = 3
+
+	defaultFanOutMaxFiles = 100
+
+	// shadowTimeout bounds each send to the shadow ingress, so that a hung
+	// shadow can't pile up goroutines and connections.
+	shadowTimeout = 10 * time.Second
+)
+
+var mDeliveryFailures = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "trampoline_delivery_failures_total",
+		Help: "The number of webhook deliveries that could not be forwarded, by reason",
+	},
+	[]string{"reason"},
+)
+
+var mVerificationFailures = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "github_webhook_verification_failures_total",
+		Help: "The number of GitHub webhook deliveries that failed verification, by reason",
+	},
+	[]string{"reason"},
+)
+
+var mShadowFailures = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "trampoline_shadow_failures_total",
+		Help: "The number of events that could not be mirrored to the shadow ingress",
+	},
+)
+
+var mForwardDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "github_event_forward_duration_seconds",
+		Help:    "The time from receiving a webhook to the completion of its delivery",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	},
+	[]string{"event_type", "outcome"},
+)
+
+var mRepoEvents = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "github_events_by_repo_total",
+		Help: "The number of webhook deliveries per watched repository, with other repositories counted as \"other\"",
+	},
+	[]string{"repo", "event_type"},
+)
+
+var mStaleEvents = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "trampoline_stale_events_dropped_total",
+		Help: "The number of webhook deliveries dropped for being older than the maximum event age",
+	},
+	[]string{"event_type"},
+)
+
+var mPayloadParseErrors = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "github_event_payload_parse_errors_total",
+		Help: "The number of webhook payloads that could not be fully parsed, which are forwarded with the metadata that could be",
+	},
+	[]string{"event_type"},
+)
+
+var mConcurrencyRejections = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "trampoline_concurrency_limit_rejections_total",
+		Help: "The number of webhook deliveries rejected with a 503 for exceeding the maximum number of concurrent requests",
+	},
+)
+
+// RetryPolicy configures the exponential backoff retries of deliveries to the
+// ingress.
+type RetryPolicy struct {
+	// Delay is the base delay before the first retry, which doubles with
+	// each retry. It defaults to 10ms.
+	Delay time.Duration
+	// MaxRetries is the maximum number of retries. It defaults to 3, and
+	// negative values disable retries.
+	MaxRetries int
+}
+
+// withDefaults returns p with unset fields defaulted.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Delay <= 0 {
+		p.Delay = retryDelay
+	}
+	switch {
+	case p.MaxRetries == 0:
+		p.MaxRetries = maxRetry
+	case p.MaxRetries < 0:
+		p.MaxRetries = 0
+	}
+	return p
+}
+
+// EventTypePolicy controls how the Server handles GitHub event types with
+// characters outside [a-z0-9_], which would make awkward CloudEvents types.
+type EventTypePolicy int
+
+const (
+	// EventTypeSanitize lowercases event types and replaces other invalid
+	// characters with underscores.
+	EventTypeSanitize EventTypePolicy = iota
+	// EventTypeReject rejects deliveries of invalid event types with 400.
+	EventTypeReject
+)
+
+// ServerOptions configures optional behavior of the Server.
+type ServerOptions struct {
+	// Verifier authenticates deliveries. It defaults to a GitHubVerifier
+	// using the secrets passed to NewServer.
+	Verifier Verifier
+
+	// Parser parses verified deliveries. It defaults to a GitHubParser with
+	// EventTypePolicy. The options that refer to GitHub event types and
+	// payloads, e.g. BranchFilter, apply to other providers as far as their
+	// Parser fills in the same PayloadInfo.
+	Parser Parser
+
+	// Source overrides the CloudEvents source of forwarded events, which
+	// otherwise is the Host of the delivery request. Behind a proxy or load
+	// balancer the latter is typically an internal hostname.
+	Source string
+
+	// AllowedEventTypes restricts forwarding to the listed GitHub event
+	// types, e.g. "pull_request". Deliveries of other types are accepted
+	// but dropped. Empty allows all event types.
+	//
+	// Entries of this and the other lists of ServerOptions can also be
+	// path.Match patterns, e.g. "pull_request*" or "org/*", and "*"
+	// matches everything.
+	AllowedEventTypes []string
+
+	// BranchFilter restricts forwarding of push and pull_request events to
+	// the listed branches, e.g. "main" or "release/*": the pushed branch,
+	// or the base branch of the pull request. Other events of these types
+	// are accepted but dropped, while pushes of tags and other event types
+	// are always forwarded. Empty allows all branches.
+	BranchFilter []string
+
+	// MaxEventAge drops deliveries whose payload timestamp is older than
+	// this, so that redeliveries after an outage aren't reprocessed.
+	// Deliveries without a payload timestamp are always forwarded. Zero
+	// disables the check.
+	MaxEventAge time.Duration
+
+	// PayloadEventTime sets the CloudEvents time of events, and the "when"
+	// of their envelope, to the payload timestamp, e.g. the updated_at of
+	// the pull request, so that the times of redeliveries reflect when the
+	// event happened. Events without a payload timestamp get the current
+	// time. By default, events have no time and "when" is the current time.
+	PayloadEventTime bool
+
+	// ShadowIngress, if set, receives a best-effort copy of every forwarded
+	// event, e.g. to mirror traffic to a new consumer during a rollout.
+	// Shadow deliveries are not retried, time out after 10s, and never affect
+	// the response.
+	ShadowIngress cloudevents.Client
+
+	// Queue, if set, receives each event instead of the client, keyed by the
+	// delivery ID. Deliveries are acknowledged with a 202 once enqueued, and
+	// the queue delivers them, e.g. an AsyncQueue, or a separate consumer
+	// drains it.
+	Queue Queue
+
+	// OrderingKeyFunc computes the ordering key of enqueued events from their
+	// GitHub event type and payload, which is passed to the Queue and
+	// reported as the "orderingkey" extension. The events of each key are
+	// delivered in order, while events of distinct keys can be delivered
+	// concurrently. It defaults to the full name of the repository, or the
+	// organization login for organization-level events, so that e.g. the
+	// check updates of a repository aren't applied out of order. Events get
+	// no key if it returns empty.
+	OrderingKeyFunc func(eventType string, info webhook.PayloadInfo) string
+
+	// DeadLetter, if set, stores the events whose delivery fails, keyed by
+	// the delivery ID, so that they can be re-sent with a ReplayServer
+	// reading the same store. The sender is still told that the delivery
+	// failed, unless DropNACKs drops it. Events of deliveries that were
+	// stored before, e.g. redeliveries, aren't stored again. It is unused
+	// if Queue is set.
+	DeadLetter Queue
+
+	// SubjectFunc computes the CloudEvents subject of an event from its
+	// GitHub event type, e.g. "pull_request", and payload. It defaults to
+	// the full name of the repository, or the organization login for
+	// organization-level events. Events get no subject if it returns empty.
+	SubjectFunc func(eventType string, info webhook.PayloadInfo) string
+
+	// MetricRepoAllowlist opts into per-repository delivery counts for the
+	// listed repositories, by full name, e.g. "org/repo". Deliveries for
+	// other repositories are counted under "other", which keeps the metric
+	// cardinality bounded, unless patterns match many repositories. Empty
+	// disables the metric.
+	MetricRepoAllowlist []string
+
+	// MergedOnlyPullRequests drops pull_request closed events for pull
+	// requests that were closed without being merged. Other pull_request
+	// actions are still forwarded.
+	MergedOnlyPullRequests bool
+
+	// FanOutPushFiles forwards push events as one event per file changed by
+	// the push's commits, instead of a single event, for consumers that
+	// process files individually. Each event carries the whole payload, and
+	// the "filepath" and "filechange" extensions, e.g. "added". Pushes that
+	// change more than FanOutPushMaxFiles files, which defaults to 100, or
+	// none, are forwarded as a single event. It is ignored if Queue is set.
+	// If an event fails, the sender redelivers all of them, so consumers
+	// must tolerate duplicates. It can't be combined with DropNACKs.
+	FanOutPushFiles    bool
+	FanOutPushMaxFiles int
+
+	// DropDraftPullRequests drops pull_request, pull_request_review and
+	// pull_request_review_comment events for draft pull requests. The
+	// ready_for_review action is still forwarded, so that consumers learn
+	// when a draft is ready.
+	DropDraftPullRequests bool
+
+	// EventTypePolicy controls the handling of invalid event types by the
+	// default Parser. It defaults to EventTypeSanitize.
+	EventTypePolicy EventTypePolicy
+
+	// GitHubHost is the base URL of the GitHub instance, used to build the
+	// URL extensions of events. It defaults to https://github.com, and must
+	// be set for GitHub Enterprise Server.
+	GitHubHost string
+
+	// RetryPolicy is the retry policy of deliveries, unless overridden for
+	// their event type by RetryByEventType.
+	RetryPolicy RetryPolicy
+
+	// RetryByEventType overrides RetryPolicy for the listed GitHub event
+	// types, e.g. "push", for consumers that are slow only for some types.
+	// Unset fields of the overrides take the defaults of RetryPolicy's
+	// fields, not the values of RetryPolicy.
+	RetryByEventType map[string]RetryPolicy
+
+	// RetryJitter randomly varies the base delay of each delivery's
+	// exponential backoff by up to this fraction, e.g. 0.5 for ±50%, so that
+	// deliveries failing together don't retry in lockstep. Zero disables
+	// jitter, and values above 1 are treated as 1.
+	RetryJitter float64
+
+	// DropBotSenders drops events sent by bot accounts, i.e. whose sender
+	// type is "Bot", so that automation doesn't loop on its own activity.
+	DropBotSenders bool
+
+	// DropSenders drops events sent by the listed logins, e.g.
+	// "renovate[bot]", regardless of DropBotSenders.
+	DropSenders []string
+
+	// ExpectedHookTargetType and ExpectedHookTargetID, if set, reject
+	// deliveries from webhooks installed on any other target, e.g. a
+	// different GitHub App pointed at this endpoint by mistake. They are
+	// compared to the X-GitHub-Hook-Installation-Target-Type and -ID
+	// headers, e.g. "integration" and the App ID.
+	ExpectedHookTargetType string
+	ExpectedHookTargetID   string
+
+	// DropNACKs acknowledges deliveries the ingress NACKs with a 202, so
+	// that they are dropped rather than redelivered. Such deliveries are
+	// still counted as delivery failures. Undeliverable events are always
+	// reported as retryable. It can't be combined with FanOutPushFiles.
+	DropNACKs bool
+
+	// RedactFields lists dot-separated paths of payload fields to remove
+	// before forwarding, e.g. "sender.email", for consumers that shouldn't
+	// see them. Extensions are still computed from the full payload.
+	// Deliveries whose payload can't be redacted are rejected with a 400
+	// rather than forwarded unredacted.
+	RedactFields []string
+
+	// IncludeDeliveryHeaders reports the GitHub delivery headers, e.g. the
+	// hook ID, as extensions of each event, e.g. for audit consumers.
+	// Extensions are named "gh" followed by the header name, such as
+	// "ghhookid", and "ghsignaturealg" is the algorithm the delivery was
+	// signed with. It is off by default to keep events lean, except for the
+	// delivery ID, which events always carry as "ghdelivery".
+	IncludeDeliveryHeaders bool
+
+	// Region and Instance, if set, are reported as the "region" and
+	// "instance" extensions of every forwarded event, to tell which
+	// trampoline forwarded it in multi-region deployments.
+	Region   string
+	Instance string
+
+	// ExtraExtensions sets additional extensions from the payload, mapping
+	// extension names, e.g. "sender", to paths into the payload, e.g.
+	// "sender.login". Paths are dot-separated object keys, and array
+	// elements are selected by index, e.g. "pull_request.labels[0].name".
+	// Extensions are only set when their path leads to a non-empty string,
+	// number or boolean, and override the built-in extensions of the same
+	// name. Names must be valid CloudEvents attribute names, i.e. lowercase
+	// letters and digits.
+	ExtraExtensions map[string]string
+
+	// VerboseResponses explains why deliveries were accepted without being
+	// forwarded, with a JSON body such as {"skipped":"filtered_sender"}, for
+	// webhook debugging tools. The reasons are "filtered_event_type",
+	// "filtered_sender", "filtered_branch", "unmerged_pull_request",
+	// "draft_pull_request" and "stale_event". Responses have no body by
+	// default.
+	VerboseResponses bool
+
+	// MaxConcurrent limits the number of deliveries handled at once, so that
+	// the server sheds load predictably under a webhook storm. Deliveries
+	// beyond the limit are rejected with a 503, which the sender retries.
+	// Zero means unlimited.
+	MaxConcurrent int
+
+	// OrgRateLimit limits the rate of deliveries of each organization, so
+	// that a single organization can't starve the others. Deliveries
+	// without an organization share a default bucket. Deliveries beyond
+	// the limit are rejected with a 429 and a Retry-After header, while
+	// deliveries that the filters drop don't count toward it. It is
+	// disabled by default.
+	OrgRateLimit RateLimit
+
+	// AuditSink receives the outcome of each verified delivery with an event
+	// type, whether forwarded, enqueued, dropped or failed. It defaults to
+	// discarding outcomes.
+	AuditSink AuditSink
+}
+
+// Server receives webhooks, from GitHub unless configured with another
+// Verifier and Parser, and forwards them as CloudEvents.
+type Server struct {
+	client  cloudevents.Client
+	opts    ServerOptions
+	allowed *matcher
+	repos   *matcher
+	senders *matcher
+	branch  *matcher
+	limiter *orgLimiter
+	// sem holds a token for each delivery being handled, if MaxConcurrent
+	// is set.
+	sem chan struct{}
+
+	// now is the clock used to compute event ages.
+	now func() time.Time
+	// random returns numbers in [0, 1) used to jitter retries.
+	random func() float64
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// NewServer returns a Server forwarding events to client. Unless overridden
+// by opts.Verifier, deliveries must be signed with one of the given secrets.
+func NewServer(client cloudevents.Client, secrets [][]byte, opts ServerOptions) *Server {
+	if opts.Verifier == nil {
+		opts.Verifier = GitHubVerifier{Secrets: secrets}
+	}
+	if opts.Parser == nil {
+		opts.Parser = GitHubParser{EventTypePolicy: opts.EventTypePolicy}
+	}
+	if opts.GitHubHost == "" {
+		opts.GitHubHost = defaultGitHubHost
+	}
+	if opts.SubjectFunc == nil {
+		opts.SubjectFunc = repositorySubject
+	}
+	if opts.OrderingKeyFunc == nil {
+		opts.OrderingKeyFunc = repositorySubject
+	}
+	if opts.AuditSink == nil {
+		opts.AuditSink = nopAuditSink{}
+	}
+	s := &Server{
+		client:  client,
+		opts:    opts,
+		allowed: newMatcher(opts.AllowedEventTypes),
+		repos:   newMatcher(opts.MetricRepoAllowlist),
+		senders: newMatcher(opts.DropSenders),
+		branch:  newMatcher(opts.BranchFilter),
+		limiter: newOrgLimiter(opts.OrgRateLimit),
+		now:     time.Now,
+		random:  rand.Float64,
+	}
+	if opts.MaxConcurrent > 0 {
+		s.sem = make(chan struct{}, opts.MaxConcurrent)
+	}
+	return s
+}
+
+// normalizeEventType lowercases t and replaces characters outside
+// [a-z0-9_] with underscores.
+func normalizeEventType(t string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return unicode.ToLower(r)
+		default:
+			return '_'
+		}
+	}, t)
+}
+
+// retryPolicy returns the retry policy for a delivery of the GitHub event
+// type, with its base delay jittered.
+func (s *Server) retryPolicy(eventType string) RetryPolicy {
+	p, ok := s.opts.RetryByEventType[eventType]
+	if !ok {
+		p = s.opts.RetryPolicy
+	}
+	p = p.withDefaults()
+	if j := min(s.opts.RetryJitter, 1); j > 0 {
+		// Scale by a random factor in [1-j, 1+j).
+		p.Delay = time.Duration(float64(p.Delay) * (1 - j + 2*j*s.random()))
+	}
+	return p
+}
+
+// repositorySubject is the default SubjectFunc and OrderingKeyFunc, which
+// returns the full name of the repository, e.g. "org/repo", or the
+// organization login for events that carry no repository, e.g. "member".
+func repositorySubject(_ string, info webhook.PayloadInfo) string {
+	if info.Repository.FullName != "" {
+		return info.Repository.FullName
+	}
+	return info.Organization.Login
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := requestContext(r)
+	log := clog.FromContext(ctx)
+	if id := ClientIdentity(r); id != "" {
+		log = log.With("client-identity", id)
+	}
+
+	defer r.Body.Close()
+
+	if s.sem != nil {
+		select {
+		case s.sem <- struct{}{}:
+			defer func() { <-s.sem }()
+		default:
+			log.Warnf("rejecting delivery: %d deliveries already in flight", s.opts.MaxConcurrent)
+			mConcurrencyRejections.Inc()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	// https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+	payload, err := s.opts.Verifier.Verify(r)
+	if err == nil {
+		err = verifyHookTarget(r, s.opts.ExpectedHookTargetType, s.opts.ExpectedHookTargetID)
+	}
+	if err != nil {
+		log.Errorf("failed to verify webhook: %v", err)
+		mVerificationFailures.With(prometheus.Labels{"reason": verificationFailureReason(err)}).Inc()
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "failed to verify webhook: %v", err)
+		return
+	}
+
+	d, parseErr := s.opts.Parser.Parse(r, payload)
+	if errors.Is(parseErr, ErrInvalidEventType) {
+		log.Errorf("rejecting delivery: %v", parseErr)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, parseErr)
+		return
+	}
+	ghType, info := d.EventType, d.Info
+
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+	defer func() {
+		s.opts.AuditSink.RecordDelivery(ctx, DeliveryOutcome{
+			DeliveryID: d.ID,
+			EventType:  ghType,
+			Status:     sw.Status(),
+			Latency:    time.Since(start),
+		})
+	}()
+
+	if s.allowed != nil && !s.allowed.match(ghType) {
+		log.Debugf("dropping event type not in allowlist: %s", ghType)
+		s.skip(w, http.StatusAccepted, "filtered_event_type")
+		return
+	}
+	log = log.With("event-type", d.Type)
+	log.Debugf("forwarding event: %s", d.Type)
+
+	event := cloudevents.NewEvent()
+	event.SetType(d.Type)
+	if s.opts.Source != "" {
+		event.SetSource(s.opts.Source)
+	} else {
+		event.SetSource(r.Host)
+	}
+
+	if parseErr != nil {
+		log.Warnf("failed to parse payload: %v", parseErr)
+		mPayloadParseErrors.With(prometheus.Labels{"event_type": ghType}).Inc()
+	}
+	if subject := s.opts.SubjectFunc(ghType, info); subject != "" {
+		event.SetSubject(subject)
+	}
+	if s.repos != nil {
+		repo := info.Repository.FullName
+		if !s.repos.match(repo) {
+			repo = "other"
+		}
+		mRepoEvents.With(prometheus.Labels{"repo": repo, "event_type": ghType}).Inc()
+	}
+	if (s.opts.DropBotSenders && info.Sender.Type == "Bot") || s.senders.match(info.Sender.Login) {
+		log.Debugf("dropping event from sender %q", info.Sender.Login)
+		s.skip(w, http.StatusAccepted, "filtered_sender")
+		return
+	}
+	if branch := extractBranch(ghType, info); s.branch != nil && branch != "" && !s.branch.match(branch) {
+		log.Debugf("dropping event for branch not in filter: %s", branch)
+		s.skip(w, http.StatusAccepted, "filtered_branch")
+		return
+	}
+	if s.opts.MergedOnlyPullRequests && ghType == "pull_request" && info.Action == "closed" && !isPullRequestMerged(ghType, info) {
+		log.Debugf("dropping pull request closed without merging")
+		s.skip(w, http.StatusAccepted, "unmerged_pull_request")
+		return
+	}
+	if s.opts.DropDraftPullRequests && isDraftPullRequest(ghType, info) {
+		log.Debugf("dropping event for draft pull request")
+		s.skip(w, http.StatusAccepted, "draft_pull_request")
+		return
+	}
+	if s.opts.MaxEventAge > 0 {
+		if ts := extractTimestamp(ghType, info); !ts.IsZero() {
+			if age := s.now().Sub(ts); age > s.opts.MaxEventAge {
+				log.Warnf("dropping stale event: age %v exceeds %v", age, s.opts.MaxEventAge)
+				mStaleEvents.With(prometheus.Labels{"event_type": ghType}).Inc()
+				s.skip(w, http.StatusOK, "stale_event")
+				return
+			}
+		}
+	}
+	// Deliveries are only limited once the filters have passed them, so that
+	// dropped events don't use up an organization's budget.
+	if s.limiter != nil {
+		org := info.Organization.Login
+		if ok, delay := s.limiter.allow(org, s.now()); !ok {
+			class := "org"
+			if org == "" {
+				class = "default"
+			}
+			log.Warnf("rate limiting delivery for organization %q", org)
+			mRateLimited.With(prometheus.Labels{"key_class": class}).Inc()
+			w.Header().Set("Retry-After", retryAfter(delay))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if s.opts.PayloadEventTime {
+		ts := extractTimestamp(ghType, info)
+		if ts.IsZero() {
+			ts = s.now()
+		}
+		event.SetTime(ts)
+	}
+
+	sha, branch := extractHead(ghType, info)
+	base, head := extractPullRequestRefs(ghType, info)
+	if head != "" {
+		branch = head
+	}
+	if sha != "" {
+		event.SetExtension("headsha", sha)
+	}
+	if branch != "" {
+		event.SetExtension("headbranch", branch)
+	}
+	if base != "" {
+		event.SetExtension("basebranch", base)
+	}
+	if org := info.Organization.Login; org != "" {
+		event.SetExtension("org", org)
+	}
+	environment, state := extractDeployment(ghType, info)
+	if environment != "" {
+		event.SetExtension("environment", environment)
+	}
+	if state != "" {
+		event.SetExtension("deploymentstate", state)
+	}
+	if v := extractVisibility(info); v != "" {
+		event.SetExtension("repovisibility", v)
+	}
+	if u := extractPullRequestURL(s.opts.GitHubHost, ghType, info); u != "" {
+		event.SetExtension("pullrequesturl", u)
+	}
+	if u := extractIssueURL(s.opts.GitHubHost, ghType, info); u != "" {
+		event.SetExtension("issueurl", u)
+	}
+	if u := extractDiscussionURL(s.opts.GitHubHost, ghType, info); u != "" {
+		event.SetExtension("discussionurl", u)
+	}
+	action, added, removed := extractInstallation(ghType, info)
+	if action != "" {
+		event.SetExtension("installationaction", action)
+	}
+	if added != "" {
+		event.SetExtension("repositoriesadded", added)
+	}
+	if removed != "" {
+		event.SetExtension("repositoriesremoved", removed)
+	}
+	statusSHA, statusState, statusContext := extractStatus(ghType, info)
+	if statusSHA != "" {
+		event.SetExtension("sha", statusSHA)
+	}
+	if statusState != "" {
+		event.SetExtension("statusstate", statusState)
+	}
+	if statusContext != "" {
+		event.SetExtension("statuscontext", statusContext)
+	}
+	runURL, conclusion, jobStatus := extractWorkflow(s.opts.GitHubHost, ghType, info)
+	if runURL != "" {
+		event.SetExtension("runurl", runURL)
+	}
+	if conclusion != "" {
+		event.SetExtension("runconclusion", conclusion)
+	}
+	if jobStatus != "" {
+		event.SetExtension("jobstatus", jobStatus)
+	}
+	if s.opts.IncludeDeliveryHeaders {
+		setDeliveryHeaderExtensions(&event, r)
+	}
+	if s.opts.Region != "" {
+		event.SetExtension("region", s.opts.Region)
+	}
+	if s.opts.Instance != "" {
+		event.SetExtension("instance", s.opts.Instance)
+	}
+	label, labels := extractLabels(ghType, info)
+	if label != "" {
+		event.SetExtension("label", label)
+	}
+	if labels != "" {
+		event.SetExtension("labels", labels)
+	}
+
+	for name, value := range d.Extensions {
+		event.SetExtension(name, value)
+	}
+
+	if len(s.opts.ExtraExtensions) > 0 {
+		extra, err := extractPaths(payload, s.opts.ExtraExtensions)
+		if err != nil {
+			log.Warnf("failed to extract extra extensions: %v", err)
+		}
+		for name, value := range extra {
+			event.SetExtension(name, value)
+		}
+	}
+
+	if len(s.opts.RedactFields) > 0 {
+		if payload, err = redact(payload, s.opts.RedactFields); err != nil {
+			log.Errorf("failed to redact payload: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx = clog.WithLogger(ctx, log)
+	if s.opts.Queue != nil {
+		orderingKey := s.opts.OrderingKeyFunc(ghType, info)
+		if orderingKey != "" {
+			event.SetExtension("orderingkey", orderingKey)
+		}
+		enqueue(ctx, s.opts.Queue, d.ID, orderingKey, w, event, payload)
+		return
+	}
+	var outcome string
+	for _, event := range s.fanOut(ghType, info, event) {
+		// A failure is written to w, and the rest of the events aren't sent.
+		// The sender then redelivers all of them, so consumers receive the
+		// events sent before the failure again. Validate rejects DropNACKs
+		// with FanOutPushFiles, which would drop the rest instead.
+		if outcome = forward(ctx, s.client, s.opts.ShadowIngress, s.retryPolicy(ghType), s.opts.DropNACKs, w, event, payload); outcome != "success" {
+			if s.opts.DeadLetter != nil && outcome != "error" {
+				deadLetter(ctx, s.opts.DeadLetter, d.ID, event, payload)
+			}
+			break
+		}
+	}
+	mForwardDuration.With(prometheus.Labels{
+		"event_type": ghType,
+		"outcome":    outcome,
+	}).Observe(time.Since(start).Seconds())
+}
+
+// fanOut returns the events to forward for a delivery: one per changed file
+// of push events with FanOutPushFiles, within the limit, and otherwise event
+// itself.
+func (s *Server) fanOut(eventType string, info webhook.PayloadInfo, event cloudevents.Event) []cloudevents.Event {
+	if !s.opts.FanOutPushFiles {
+		return []cloudevents.Event{event}
+	}
+	files := changedFiles(eventType, info)
+	limit := s.opts.FanOutPushMaxFiles
+	if limit <= 0 {
+		limit = defaultFanOutMaxFiles
+	}
+	if len(files) == 0 || len(files) > limit {
+		return []cloudevents.Event{event}
+	}
+	events := make([]cloudevents.Event, 0, len(files))
+	for _, f := range files {
+		e := event.Clone()
+		e.SetExtension("filepath", f.Path)
+		e.SetExtension("filechange", f.Change)
+		events = append(events, e)
+	}
+	return events
+}
+
+// skipResponse is the body of responses to deliveries that were
+// deliberately not forwarded, with ServerOptions.VerboseResponses.
+type skipResponse struct {
+	Skipped string `json:"skipped"`
+}
+
+// skip responds to a delivery that is deliberately not forwarded with
+// status, explaining the reason if VerboseResponses is set.
+func (s *Server) skip(w http.ResponseWriter, status int, reason string) {
+	if !s.opts.VerboseResponses {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(skipResponse{Skipped: reason})
+}
+
+// prepare wraps the payload in the event envelope and attaches the trace
+// context of ctx. The envelope's "when" is the time of the event, if set, and
+// the current time otherwise.
+func prepare(ctx context.Context, event *cloudevents.Event, payload []byte) error {
+	when := event.Time()
+	if when.IsZero() {
+		when = time.Now()
+	}
+	if err := event.SetData(cloudevents.ApplicationJSON, webhook.Envelope{
+		Version: webhook.EnvelopeVersion,
+		When:    when,
+		Body:    payload,
+	}); err != nil {
+		return err
+	}
+	setTracingExtension(ctx, event)
+	return nil
+}
+
+// forward wraps the payload in the event envelope and delivers it, writing an
+// error status to w on failure. A NACK from the ingress is reported as 503 so
+// that the sender retries, unless dropNACKs is set, while events that could
+// not be delivered at all are reported as 502. Payloads that can't be wrapped
+// are rejected with a 400, as redelivering them is futile.
+//
+// If shadow is non-nil, a copy of the event is sent to it in the background.
+//
+// It returns the outcome of the delivery: "success", "nack", "undelivered"
+// or "error".
+func forward(ctx context.Context, client, shadow cloudevents.Client, policy RetryPolicy, dropNACKs bool, w http.ResponseWriter, event cloudevents.Event, payload []byte) string {
+	log := clog.FromContext(ctx)
+
+	if err := prepare(ctx, &event, payload); err != nil {
+		log.Errorf("failed to set data: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return "error"
+	}
+
+	if shadow != nil {
+		go sendShadow(context.WithoutCancel(ctx), shadow, event.Clone())
+	}
+
+	return deliver(ctx, client, policy, dropNACKs, w, event)
+}
+
+// deliver sends the event with exponential backoff retries per policy,
+// writing an error status to w on failure, and returns the outcome as
+// described for forward.
+func deliver(ctx context.Context, client cloudevents.Client, policy RetryPolicy, dropNACKs bool, w http.ResponseWriter, event cloudevents.Event) string {
+	reason, ceresult := send(ctx, client, policy, event)
+	switch {
+	case reason == "success":
+		return reason
+	case reason == "nack" && dropNACKs:
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "dropped event the ingress did not acknowledge: %v", ceresult)
+	case reason == "nack":
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "failed to deliver event: %v", ceresult)
+	default:
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "failed to deliver event: %v", ceresult)
+	}
+	return reason
+}
+
+// send sends the event with exponential backoff retries per policy, logging
+// and counting failures. It returns the outcome, "success", "nack" or
+// "undelivered", and the result of the last attempt.
+func send(ctx context.Context, client cloudevents.Client, policy RetryPolicy, event cloudevents.Event) (string, cloudevents.Result) {
+	log := clog.FromContext(ctx)
+
+	rctx := cloudevents.ContextWithRetriesExponentialBackoff(context.WithoutCancel(ctx), policy.Delay, policy.MaxRetries)
+	ceresult := client.Send(rctx, event)
+	var reason string
+	switch {
+	case cloudevents.IsNACK(ceresult):
+		reason = "nack"
+	case cloudevents.IsUndelivered(ceresult):
+		reason = "undelivered"
+	default:
+		log.Debugf("event forwarded")
+		return "success", ceresult
+	}
+	mDeliveryFailures.With(prometheus.Labels{"reason": reason}).Inc()
+	log.With("reason", reason, "result", ceresult.Error()).Errorf("Failed to deliver event: %v", ceresult)
+	return reason, ceresult
+}
+
+// sendShadow delivers event to the shadow ingress once, within shadowTimeout,
+// logging and counting failures.
+func sendShadow(ctx context.Context, shadow cloudevents.Client, event cloudevents.Event) {
+	ctx, cancel := context.WithTimeout(ctx, shadowTimeout)
+	defer cancel()
+	if ceresult := shadow.Send(ctx, event); !cloudevents.IsACK(ceresult) {
+		mShadowFailures.Inc()
+		clog.FromContext(ctx).Warnf("failed to send event to shadow ingress: %v", ceresult)
+	}
+}
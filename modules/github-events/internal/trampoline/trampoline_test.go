@@ -0,0 +1,2085 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-bots/sdk"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-bots/sdk/sdktest"
+	"github.com/chainguard-dev/terraform-infra-common/modules/github-events/webhook"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeClient is a cloudevents.Client that records sent events.
+type fakeClient struct {
+	m       sync.Mutex
+	events  []cloudevents.Event
+	spans   []trace.SpanContext
+	periods []time.Duration
+	tries   []int
+	result  cloudevents.Result
+}
+
+var _ cloudevents.Client = (*fakeClient)(nil)
+
+func (f *fakeClient) Send(ctx context.Context, event cloudevents.Event) cloudevents.Result {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.events = append(f.events, event)
+	f.spans = append(f.spans, trace.SpanContextFromContext(ctx))
+	if rp := cecontext.RetriesFrom(ctx); rp != nil {
+		f.periods = append(f.periods, rp.Period)
+		f.tries = append(f.tries, rp.MaxTries)
+	}
+	return f.result
+}
+
+func (f *fakeClient) Request(context.Context, cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
+	panic("not implemented")
+}
+
+func (f *fakeClient) StartReceiver(context.Context, interface{}) error {
+	panic("not implemented")
+}
+
+// chanClient is a cloudevents.Client that hands sent events to a channel,
+// for asserting on deliveries that happen in the background.
+type chanClient struct {
+	fakeClient
+	sent chan cloudevents.Event
+}
+
+func (c *chanClient) Send(_ context.Context, event cloudevents.Event) cloudevents.Result {
+	c.sent <- event
+	return c.result
+}
+
+func signature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newRequest returns a webhook delivery request for the given event type,
+// signed with secret if it is non-nil.
+func newRequest(t *testing.T, eventType string, secret []byte, payload any) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if eventType != "" {
+		req.Header.Set("X-GitHub-Event", eventType)
+	}
+	if secret != nil {
+		req.Header.Set("X-Hub-Signature-256", signature(secret, body))
+	}
+	return req
+}
+
+func TestTrampoline(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	srv := NewServer(client, [][]byte{secret}, ServerOptions{})
+
+	payload := map[string]any{
+		"action": "opened",
+		"repository": map[string]any{
+			"full_name": "org/repo",
+		},
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "pull_request", secret, payload))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+	event := client.events[0]
+	if got, want := event.Type(), "dev.chainguard.github.pull_request"; got != want {
+		t.Errorf("Type() = %q, wanted %q", got, want)
+	}
+	if got, want := event.Source(), "example.com"; got != want {
+		t.Errorf("Source() = %q, wanted %q", got, want)
+	}
+	if got, want := event.Subject(), "org/repo"; got != want {
+		t.Errorf("Subject() = %q, wanted %q", got, want)
+	}
+
+	var data struct {
+		Version int            `json:"version"`
+		Body    map[string]any `json:"body"`
+	}
+	if err := event.DataAs(&data); err != nil {
+		t.Fatalf("DataAs() = %v", err)
+	}
+	if data.Version != webhook.EnvelopeVersion {
+		t.Errorf("forwarded version = %d, wanted %d", data.Version, webhook.EnvelopeVersion)
+	}
+	if diff := cmp.Diff(payload, data.Body); diff != "" {
+		t.Errorf("forwarded body (-want +got): %s", diff)
+	}
+}
+
+func TestTrampolineSecretRotation(t *testing.T) {
+	oldSecret, newSecret := []byte("old"), []byte("new")
+	srv := NewServer(&fakeClient{}, [][]byte{oldSecret, newSecret}, ServerOptions{})
+
+	for _, tt := range []struct {
+		name   string
+		secret []byte
+		want   int
+	}{
+		{"old secret", oldSecret, http.StatusOK},
+		{"new secret", newSecret, http.StatusOK},
+		{"wrong secret", []byte("wrong"), http.StatusForbidden},
+		{"unsigned", nil, http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, "push", tt.secret, map[string]any{}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrampolineEmptySecret(t *testing.T) {
+	client := &fakeClient{}
+	srv := NewServer(client, [][]byte{[]byte("real-secret"), {}}, ServerOptions{})
+
+	// Anyone can sign with an empty key, so it must not verify.
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "push", []byte{}, map[string]any{}))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusForbidden)
+	}
+	if len(client.events) != 0 {
+		t.Errorf("sent %d events, wanted 0", len(client.events))
+	}
+}
+
+func TestTrampolineVerificationFailures(t *testing.T) {
+	secret := []byte("hunter2")
+	srv := NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{})
+
+	for _, tt := range []struct {
+		name   string
+		secret []byte
+		reason string
+	}{
+		{"unsigned", nil, "missing_signature"},
+		{"wrong secret", []byte("wrong"), "bad_signature"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(mVerificationFailures.WithLabelValues(tt.reason))
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, "push", tt.secret, map[string]any{}))
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, wanted %d", rec.Code, http.StatusForbidden)
+			}
+			if got := testutil.ToFloat64(mVerificationFailures.WithLabelValues(tt.reason)) - before; got != 1 {
+				t.Errorf("%s failure count increased by %f, wanted 1", tt.reason, got)
+			}
+		})
+	}
+}
+
+func TestTrampolineHookTarget(t *testing.T) {
+	secret := []byte("hunter2")
+	srv := NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{
+		ExpectedHookTargetType: "integration",
+		ExpectedHookTargetID:   "1234",
+	})
+
+	for _, tt := range []struct {
+		name       string
+		targetType string
+		targetID   string
+		want       int
+	}{
+		{"match", "integration", "1234", http.StatusOK},
+		{"wrong type", "repository", "1234", http.StatusForbidden},
+		{"wrong ID", "integration", "5678", http.StatusForbidden},
+		{"missing headers", "", "", http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(mVerificationFailures.WithLabelValues("hook_target_mismatch"))
+
+			req := newRequest(t, "push", secret, map[string]any{})
+			req.Header.Set(HookTargetTypeHeader, tt.targetType)
+			req.Header.Set(HookTargetIDHeader, tt.targetID)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+
+			var want float64
+			if tt.want == http.StatusForbidden {
+				want = 1
+			}
+			if got := testutil.ToFloat64(mVerificationFailures.WithLabelValues("hook_target_mismatch")) - before; got != want {
+				t.Errorf("hook_target_mismatch count increased by %f, wanted %f", got, want)
+			}
+		})
+	}
+}
+
+func TestTrampolineErrors(t *testing.T) {
+	secret := []byte("hunter2")
+
+	t.Run("missing event type", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{}).ServeHTTP(rec, newRequest(t, "", secret, map[string]any{}))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, wanted %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+}
+
+func TestTrampolineDeliveryFailures(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name       string
+		result     cloudevents.Result
+		want       int
+		wantReason string
+	}{{
+		name:   "ack",
+		result: cloudevents.ResultACK,
+		want:   http.StatusOK,
+	}, {
+		name:       "nack",
+		result:     cloudevents.NewReceipt(false, "nope"),
+		want:       http.StatusServiceUnavailable,
+		wantReason: "nack",
+	}, {
+		name:       "http nack",
+		result:     cehttp.NewResult(http.StatusInternalServerError, "%w", cloudevents.ResultNACK),
+		want:       http.StatusServiceUnavailable,
+		wantReason: "nack",
+	}, {
+		name:       "undelivered",
+		result:     errors.New("connection refused"),
+		want:       http.StatusBadGateway,
+		wantReason: "undelivered",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			var before float64
+			if tt.wantReason != "" {
+				before = testutil.ToFloat64(mDeliveryFailures.WithLabelValues(tt.wantReason))
+			}
+
+			rec := httptest.NewRecorder()
+			NewServer(&fakeClient{result: tt.result}, [][]byte{secret}, ServerOptions{}).ServeHTTP(rec, newRequest(t, "push", secret, map[string]any{}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+			if tt.wantReason == "" {
+				return
+			}
+			if !strings.Contains(rec.Body.String(), tt.result.Error()) {
+				t.Errorf("body = %q, wanted it to contain %q", rec.Body, tt.result.Error())
+			}
+			if got := testutil.ToFloat64(mDeliveryFailures.WithLabelValues(tt.wantReason)) - before; got != 1 {
+				t.Errorf("failure count increased by %f, wanted 1", got)
+			}
+		})
+	}
+}
+
+func TestTrampolineDropNACKs(t *testing.T) {
+	secret := []byte("hunter2")
+	before := testutil.ToFloat64(mDeliveryFailures.WithLabelValues("nack"))
+
+	rec := httptest.NewRecorder()
+	NewServer(&fakeClient{result: cloudevents.NewReceipt(false, "nope")}, [][]byte{secret}, ServerOptions{DropNACKs: true}).ServeHTTP(rec, newRequest(t, "push", secret, map[string]any{}))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusAccepted)
+	}
+	if got := rec.Body.String(); !strings.HasPrefix(got, "dropped event") {
+		t.Errorf("body = %q, wanted it to report the event as dropped", got)
+	}
+	if got := testutil.ToFloat64(mDeliveryFailures.WithLabelValues("nack")) - before; got != 1 {
+		t.Errorf("failure count increased by %f, wanted 1", got)
+	}
+}
+
+func TestTrampolineResponseContract(t *testing.T) {
+	secret := []byte("hunter2")
+	nack := cloudevents.NewReceipt(false, "nope")
+	undelivered := errors.New("connection refused")
+
+	for _, tt := range []struct {
+		name    string
+		opts    ServerOptions
+		result  cloudevents.Result
+		request func(t *testing.T) *http.Request
+		want    int
+	}{{
+		name:   "delivered",
+		result: cloudevents.ResultACK,
+		want:   http.StatusOK,
+	}, {
+		name: "dropped",
+		opts: ServerOptions{AllowedEventTypes: []string{"pull_request"}},
+		want: http.StatusAccepted,
+	}, {
+		name:   "nack",
+		result: nack,
+		want:   http.StatusServiceUnavailable,
+	}, {
+		name:   "nack dropped",
+		opts:   ServerOptions{DropNACKs: true},
+		result: nack,
+		want:   http.StatusAccepted,
+	}, {
+		name:   "undelivered",
+		opts:   ServerOptions{DropNACKs: true},
+		result: undelivered,
+		want:   http.StatusBadGateway,
+	}, {
+		name: "bad signature",
+		request: func(t *testing.T) *http.Request {
+			return newRequest(t, "push", []byte("wrong"), map[string]any{})
+		},
+		want: http.StatusForbidden,
+	}, {
+		name: "malformed payload",
+		request: func(*testing.T) *http.Request {
+			body := []byte("{not json")
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature-256", signature(secret, body))
+			return req
+		},
+		want: http.StatusBadRequest,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(t, "push", secret, map[string]any{})
+			if tt.request != nil {
+				req = tt.request(t)
+			}
+			rec := httptest.NewRecorder()
+			NewServer(&fakeClient{result: tt.result}, [][]byte{secret}, tt.opts).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestTrampolineSource(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"default", "", "example.com"},
+		{"override", "https://github.com/chainguard-dev", "https://github.com/chainguard-dev"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewServer(client, [][]byte{secret}, ServerOptions{Source: tt.source}).ServeHTTP(rec, newRequest(t, "push", secret, map[string]any{}))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			if got := client.events[0].Source(); got != tt.want {
+				t.Errorf("Source() = %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrampolineAllowedEventTypes(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name      string
+		allowed   []string
+		eventType string
+		want      int
+		wantSent  int
+	}{
+		{"empty allows all", nil, "push", http.StatusOK, 1},
+		{"listed type", []string{"pull_request", "push"}, "push", http.StatusOK, 1},
+		{"unlisted type", []string{"pull_request", "push"}, "check_run", http.StatusAccepted, 0},
+		{"wildcard", []string{"*"}, "check_run", http.StatusOK, 1},
+		{"matching pattern", []string{"pull_request*"}, "pull_request_review", http.StatusOK, 1},
+		{"unmatched pattern", []string{"pull_request*"}, "push", http.StatusAccepted, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewServer(client, [][]byte{secret}, ServerOptions{AllowedEventTypes: tt.allowed}).ServeHTTP(rec, newRequest(t, tt.eventType, secret, map[string]any{}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+			if len(client.events) != tt.wantSent {
+				t.Errorf("sent %d events, wanted %d", len(client.events), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestTrampolineMaxEventAge(t *testing.T) {
+	secret := []byte("hunter2")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name        string
+		maxAge      time.Duration
+		eventType   string
+		payload     map[string]any
+		wantSent    int
+		wantDropped float64
+	}{{
+		name:      "disabled",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"pull_request": map[string]any{"updated_at": now.Add(-48 * time.Hour)},
+		},
+		wantSent: 1,
+	}, {
+		name:      "fresh",
+		maxAge:    time.Hour,
+		eventType: "pull_request",
+		payload: map[string]any{
+			"pull_request": map[string]any{"updated_at": now.Add(-time.Minute)},
+		},
+		wantSent: 1,
+	}, {
+		name:      "stale",
+		maxAge:    time.Hour,
+		eventType: "pull_request",
+		payload: map[string]any{
+			"pull_request": map[string]any{"updated_at": now.Add(-2 * time.Hour)},
+		},
+		wantDropped: 1,
+	}, {
+		name:      "stale push",
+		maxAge:    time.Hour,
+		eventType: "push",
+		payload: map[string]any{
+			"repository": map[string]any{"pushed_at": now.Add(-2 * time.Hour).Unix()},
+		},
+		wantDropped: 1,
+	}, {
+		name:      "old commit pushed now",
+		maxAge:    time.Hour,
+		eventType: "push",
+		payload: map[string]any{
+			"head_commit": map[string]any{"timestamp": now.Add(-48 * time.Hour)},
+			"repository":  map[string]any{"pushed_at": now.Unix()},
+		},
+		wantSent: 1,
+	}, {
+		name:      "no timestamp",
+		maxAge:    time.Hour,
+		eventType: "pull_request",
+		payload:   map[string]any{},
+		wantSent:  1,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(mStaleEvents.WithLabelValues(tt.eventType))
+
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, ServerOptions{MaxEventAge: tt.maxAge})
+			srv.now = func() time.Time { return now }
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, tt.eventType, secret, tt.payload))
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, wanted %d", rec.Code, http.StatusOK)
+			}
+			if len(client.events) != tt.wantSent {
+				t.Errorf("sent %d events, wanted %d", len(client.events), tt.wantSent)
+			}
+			if got := testutil.ToFloat64(mStaleEvents.WithLabelValues(tt.eventType)) - before; got != tt.wantDropped {
+				t.Errorf("dropped count increased by %f, wanted %f", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestTrampolineShadowIngress(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	shadow := &chanClient{
+		fakeClient: fakeClient{result: errors.New("connection refused")},
+		sent:       make(chan cloudevents.Event, 1),
+	}
+	before := testutil.ToFloat64(mShadowFailures)
+
+	rec := httptest.NewRecorder()
+	NewServer(client, [][]byte{secret}, ServerOptions{ShadowIngress: shadow}).ServeHTTP(rec, newRequest(t, "push", secret, map[string]any{}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+
+	select {
+	case got := <-shadow.sent:
+		if diff := cmp.Diff(client.events[0], got); diff != "" {
+			t.Errorf("shadow event (-want +got): %s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shadow ingress did not receive the event")
+	}
+
+	// The failure is counted after Send returns.
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(mShadowFailures)-before != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("shadow failure count increased by %f, wanted 1", testutil.ToFloat64(mShadowFailures)-before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// deadlineClient is a cloudevents.Client that records the deadline of the
+// context of each send.
+type deadlineClient struct {
+	fakeClient
+	deadlines []time.Time
+}
+
+func (c *deadlineClient) Send(ctx context.Context, _ cloudevents.Event) cloudevents.Result {
+	deadline, _ := ctx.Deadline()
+	c.deadlines = append(c.deadlines, deadline)
+	return c.result
+}
+
+func TestSendShadowTimeout(t *testing.T) {
+	shadow := &deadlineClient{}
+	start := time.Now()
+	sendShadow(context.Background(), shadow, cloudevents.NewEvent())
+
+	if len(shadow.deadlines) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(shadow.deadlines))
+	}
+	if d := shadow.deadlines[0]; d.IsZero() || d.After(start.Add(shadowTimeout).Add(time.Second)) {
+		t.Errorf("deadline = %v, wanted within %v of %v", d, shadowTimeout, start)
+	}
+}
+
+func TestTrampolineTraceContext(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	req := newRequest(t, "push", secret, map[string]any{})
+	req.Header.Set("traceparent", traceparent)
+
+	rec := httptest.NewRecorder()
+	NewServer(client, [][]byte{secret}, ServerOptions{}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+
+	sc := client.spans[0]
+	if got, want := sc.TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("TraceID() = %s, wanted %s", got, want)
+	}
+	if got, want := sc.SpanID().String(), "00f067aa0ba902b7"; got != want {
+		t.Errorf("SpanID() = %s, wanted %s", got, want)
+	}
+	sdktest.AssertExtension(t, client.events[0], "traceparent", traceparent)
+}
+
+func TestTrampolineForwardDuration(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		outcome string
+		result  cloudevents.Result
+	}{
+		{"success", nil},
+		{"nack", cloudevents.NewReceipt(false, "nope")},
+	} {
+		t.Run(tt.outcome, func(t *testing.T) {
+			count := func() uint64 {
+				var m dto.Metric
+				if err := mForwardDuration.WithLabelValues("deployment", tt.outcome).(prometheus.Histogram).Write(&m); err != nil {
+					t.Fatalf("Write() = %v", err)
+				}
+				return m.GetHistogram().GetSampleCount()
+			}
+			prev := count()
+
+			srv := NewServer(&fakeClient{result: tt.result}, [][]byte{secret}, ServerOptions{})
+			srv.ServeHTTP(httptest.NewRecorder(), newRequest(t, "deployment", secret, map[string]any{}))
+
+			if got := count() - prev; got != 1 {
+				t.Errorf("observations increased by %d, wanted 1", got)
+			}
+		})
+	}
+}
+
+func TestTrampolineSubjectFunc(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	srv := NewServer(client, [][]byte{secret}, ServerOptions{
+		SubjectFunc: func(eventType string, info webhook.PayloadInfo) string {
+			if eventType != "pull_request" {
+				return ""
+			}
+			return fmt.Sprintf("%s#%d", info.Repository.FullName, info.PullRequest.Number)
+		},
+	})
+
+	for _, tt := range []struct {
+		eventType string
+		want      string
+	}{
+		{"pull_request", "org/repo#42"},
+		{"push", ""},
+	} {
+		t.Run(tt.eventType, func(t *testing.T) {
+			client.events = nil
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, tt.eventType, secret, map[string]any{
+				"pull_request": map[string]any{"number": 42},
+				"repository":   map[string]any{"full_name": "org/repo"},
+			}))
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			if got := client.events[0].Subject(); got != tt.want {
+				t.Errorf("Subject() = %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrampolineDefaultSubject(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	srv := NewServer(client, [][]byte{secret}, ServerOptions{})
+
+	for _, tt := range []struct {
+		name      string
+		eventType string
+		payload   map[string]any
+		want      string
+	}{{
+		name:      "repository",
+		eventType: "push",
+		payload: map[string]any{
+			"repository":   map[string]any{"full_name": "org/repo"},
+			"organization": map[string]any{"login": "org"},
+		},
+		want: "org/repo",
+	}, {
+		name:      "organization",
+		eventType: "member",
+		payload: map[string]any{
+			"organization": map[string]any{"login": "org"},
+		},
+		want: "org",
+	}, {
+		name:      "neither",
+		eventType: "ping",
+		payload:   map[string]any{},
+		want:      "",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client.events = nil
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, tt.eventType, secret, tt.payload))
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			if got := client.events[0].Subject(); got != tt.want {
+				t.Errorf("Subject() = %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrampolinePayloadParseErrors(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	srv := NewServer(client, [][]byte{secret}, ServerOptions{})
+
+	before := testutil.ToFloat64(mPayloadParseErrors.WithLabelValues("push"))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "push", secret, map[string]any{
+		"repository": "org/repo",
+	}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+	if got := client.events[0].Subject(); got != "" {
+		t.Errorf("Subject() = %q, wanted empty", got)
+	}
+	if got := testutil.ToFloat64(mPayloadParseErrors.WithLabelValues("push")) - before; got != 1 {
+		t.Errorf("parse error count increased by %f, wanted 1", got)
+	}
+}
+
+func TestTrampolineRepoMetrics(t *testing.T) {
+	secret := []byte("hunter2")
+	srv := NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{
+		MetricRepoAllowlist: []string{"org/watched"},
+	})
+
+	for _, tt := range []struct {
+		repo string
+		want string
+	}{
+		{"org/watched", "org/watched"},
+		{"org/unwatched", "other"},
+		{"", "other"},
+	} {
+		t.Run(tt.repo, func(t *testing.T) {
+			before := testutil.ToFloat64(mRepoEvents.WithLabelValues(tt.want, "star"))
+			srv.ServeHTTP(httptest.NewRecorder(), newRequest(t, "star", secret, map[string]any{
+				"repository": map[string]any{"full_name": tt.repo},
+			}))
+			if got := testutil.ToFloat64(mRepoEvents.WithLabelValues(tt.want, "star")) - before; got != 1 {
+				t.Errorf("%s count increased by %f, wanted 1", tt.want, got)
+			}
+		})
+	}
+
+	if got := testutil.ToFloat64(mRepoEvents.WithLabelValues("org/unwatched", "star")); got != 0 {
+		t.Errorf("org/unwatched count = %f, wanted 0", got)
+	}
+}
+
+func TestTrampolineMergedOnlyPullRequests(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name     string
+		action   string
+		merged   bool
+		want     int
+		wantSent int
+	}{
+		{"merged", "closed", true, http.StatusOK, 1},
+		{"closed unmerged", "closed", false, http.StatusAccepted, 0},
+		{"opened", "opened", false, http.StatusOK, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewServer(client, [][]byte{secret}, ServerOptions{MergedOnlyPullRequests: true}).ServeHTTP(rec, newRequest(t, "pull_request", secret, map[string]any{
+				"action":       tt.action,
+				"pull_request": map[string]any{"number": 1, "merged": tt.merged},
+			}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+			if len(client.events) != tt.wantSent {
+				t.Errorf("sent %d events, wanted %d", len(client.events), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestTrampolineDropDraftPullRequests(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name      string
+		eventType string
+		action    string
+		draft     bool
+		want      int
+		wantSent  int
+	}{
+		{"draft", "pull_request", "synchronize", true, http.StatusAccepted, 0},
+		{"draft review", "pull_request_review", "submitted", true, http.StatusAccepted, 0},
+		{"ready for review", "pull_request", "ready_for_review", true, http.StatusOK, 1},
+		{"not a draft", "pull_request", "opened", false, http.StatusOK, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewServer(client, [][]byte{secret}, ServerOptions{DropDraftPullRequests: true}).ServeHTTP(rec, newRequest(t, tt.eventType, secret, map[string]any{
+				"action":       tt.action,
+				"pull_request": map[string]any{"number": 1, "draft": tt.draft},
+			}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+			if len(client.events) != tt.wantSent {
+				t.Errorf("sent %d events, wanted %d", len(client.events), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestTrampolineDropSenders(t *testing.T) {
+	secret := []byte("hunter2")
+	opts := ServerOptions{DropBotSenders: true, DropSenders: []string{"octocat"}}
+
+	for _, tt := range []struct {
+		name     string
+		sender   map[string]any
+		want     int
+		wantSent int
+	}{
+		{"bot", map[string]any{"login": "dependabot[bot]", "type": "Bot"}, http.StatusAccepted, 0},
+		{"listed login", map[string]any{"login": "octocat", "type": "User"}, http.StatusAccepted, 0},
+		{"human", map[string]any{"login": "hubot", "type": "User"}, http.StatusOK, 1},
+		{"no sender", nil, http.StatusOK, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewServer(client, [][]byte{secret}, opts).ServeHTTP(rec, newRequest(t, "issues", secret, map[string]any{
+				"action": "opened",
+				"sender": tt.sender,
+			}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+			if len(client.events) != tt.wantSent {
+				t.Errorf("sent %d events, wanted %d", len(client.events), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestTrampolineEventTypePolicy(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name      string
+		policy    EventTypePolicy
+		eventType string
+		want      int
+		wantType  string
+	}{
+		{"valid sanitize", EventTypeSanitize, "pull_request", http.StatusOK, "dev.chainguard.github.pull_request"},
+		{"valid reject", EventTypeReject, "pull_request", http.StatusOK, "dev.chainguard.github.pull_request"},
+		{"weird sanitize", EventTypeSanitize, "Custom.Event-Type/v2", http.StatusOK, "dev.chainguard.github.custom_event_type_v2"},
+		{"weird reject", EventTypeReject, "Custom.Event-Type/v2", http.StatusBadRequest, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewServer(client, [][]byte{secret}, ServerOptions{EventTypePolicy: tt.policy}).ServeHTTP(rec, newRequest(t, tt.eventType, secret, map[string]any{}))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, wanted %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.wantType == "" {
+				if len(client.events) != 0 {
+					t.Errorf("sent %d events, wanted 0", len(client.events))
+				}
+				return
+			}
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			if got := client.events[0].Type(); got != tt.wantType {
+				t.Errorf("Type() = %q, wanted %q", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestTrampolineRetryJitter(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name   string
+		jitter float64
+		want   []time.Duration
+	}{
+		{"disabled", 0, []time.Duration{retryDelay, retryDelay, retryDelay}},
+		{"half", 0.5, []time.Duration{retryDelay / 2, retryDelay, retryDelay * 5 / 4}},
+		{"capped", 2, []time.Duration{0, retryDelay, retryDelay * 3 / 2}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, ServerOptions{RetryJitter: tt.jitter})
+			randoms := []float64{0, 0.5, 0.75}
+			srv.random = func() float64 {
+				r := randoms[0]
+				randoms = randoms[1:]
+				return r
+			}
+
+			for range 3 {
+				srv.ServeHTTP(httptest.NewRecorder(), newRequest(t, "push", secret, map[string]any{}))
+			}
+			if diff := cmp.Diff(tt.want, client.periods); diff != "" {
+				t.Errorf("retry periods (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestTrampolineRetryByEventType(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	srv := NewServer(client, [][]byte{secret}, ServerOptions{
+		RetryPolicy: RetryPolicy{Delay: time.Second},
+		RetryByEventType: map[string]RetryPolicy{
+			"push":      {Delay: time.Minute, MaxRetries: 10},
+			"check_run": {MaxRetries: -1},
+		},
+	})
+
+	for _, eventType := range []string{"push", "pull_request", "check_run"} {
+		srv.ServeHTTP(httptest.NewRecorder(), newRequest(t, eventType, secret, map[string]any{}))
+	}
+	if diff := cmp.Diff([]time.Duration{time.Minute, time.Second, retryDelay}, client.periods); diff != "" {
+		t.Errorf("retry periods (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff([]int{10, maxRetry, 0}, client.tries); diff != "" {
+		t.Errorf("max tries (-want +got): %s", diff)
+	}
+}
+
+// staticVerifier accepts every delivery with a fixed payload.
+type staticVerifier struct {
+	payload []byte
+}
+
+func (v staticVerifier) Verify(*http.Request) ([]byte, error) {
+	return v.payload, nil
+}
+
+func TestTrampolineCustomVerifier(t *testing.T) {
+	client := &fakeClient{}
+	srv := NewServer(client, nil, ServerOptions{
+		Verifier: staticVerifier{payload: []byte(`{"custom":true}`)},
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "push", nil, map[string]any{}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+	var data struct {
+		Body map[string]any `json:"body"`
+	}
+	if err := client.events[0].DataAs(&data); err != nil {
+		t.Fatalf("DataAs() = %v", err)
+	}
+	if diff := cmp.Diff(map[string]any{"custom": true}, data.Body); diff != "" {
+		t.Errorf("forwarded body (-want +got): %s", diff)
+	}
+}
+
+func TestTrampolineRedactFields(t *testing.T) {
+	client := &fakeClient{}
+	srv := NewServer(client, nil, ServerOptions{
+		Verifier: staticVerifier{payload: []byte(`{
+			"repository": {"full_name": "org/repo", "description": "secret"},
+			"sender": {"login": "octocat", "email": "octocat@example.com"},
+			"unknown": 1
+		}`)},
+		RedactFields: []string{"sender.email", "repository.description"},
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "push", nil, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+	event := client.events[0]
+	if got, want := event.Subject(), "org/repo"; got != want {
+		t.Errorf("Subject() = %q, wanted %q", got, want)
+	}
+	var data struct {
+		Body map[string]any `json:"body"`
+	}
+	if err := event.DataAs(&data); err != nil {
+		t.Fatalf("DataAs() = %v", err)
+	}
+	want := map[string]any{
+		"repository": map[string]any{"full_name": "org/repo"},
+		"sender":     map[string]any{"login": "octocat"},
+		"unknown":    float64(1),
+	}
+	if diff := cmp.Diff(want, data.Body); diff != "" {
+		t.Errorf("forwarded body (-want +got): %s", diff)
+	}
+}
+
+func TestTrampolineDeliveryHeaders(t *testing.T) {
+	secret := []byte("hunter2")
+	for _, tt := range []struct {
+		name    string
+		include bool
+		want    map[string]any
+	}{{
+		name: "default",
+		want: map[string]any{
+			"ghdelivery": "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+		},
+	}, {
+		name:    "included",
+		include: true,
+		want: map[string]any{
+			"ghdelivery":       "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+			"ghhookid":         "292430182",
+			"ghhooktargettype": "integration",
+			"ghhooktargetid":   "79929171",
+			"ghsignaturealg":   "sha256",
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, ServerOptions{IncludeDeliveryHeaders: tt.include})
+
+			req := newRequest(t, "ping", secret, map[string]any{})
+			req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+			req.Header.Set("X-GitHub-Hook-ID", "292430182")
+			req.Header.Set(HookTargetTypeHeader, "integration")
+			req.Header.Set(HookTargetIDHeader, "79929171")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+
+			got := map[string]any{}
+			for k, v := range client.events[0].Extensions() {
+				if strings.HasPrefix(k, "gh") {
+					got[k] = v
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("extensions (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestTrampolineMuxLogger(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	req := newRequest(t, "pull_request", secret, map[string]any{
+		"action":     "opened",
+		"repository": map[string]any{"full_name": "org/repo"},
+	})
+	req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	NewServer(client, [][]byte{secret}, ServerOptions{}).ServeHTTP(httptest.NewRecorder(), req)
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+
+	// Handlers of the SDK log the action and delivery ID of forwarded events.
+	var buf bytes.Buffer
+	ctx := clog.WithLogger(context.Background(), clog.New(slog.NewJSONHandler(&buf, nil)))
+	m := sdk.NewMux()
+	m.Handle(string(sdk.PullRequestEvent), func(ctx context.Context, _ cloudevents.Event) error {
+		clog.FromContext(ctx).Info("handling")
+		return nil
+	})
+	if res := m.Receive(ctx, client.events[0]); !cloudevents.IsACK(res) {
+		t.Fatalf("Receive() = %v, wanted ACK", res)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%q) = %v", buf.String(), err)
+	}
+	for k, want := range map[string]string{
+		"subject":  "org/repo",
+		"action":   "opened",
+		"delivery": "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+	} {
+		if got[k] != want {
+			t.Errorf("log field %q = %v, wanted %q", k, got[k], want)
+		}
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		eventType string
+		payload   map[string]any
+		want      map[string]any
+	}{{
+		name:      "check_suite with pull requests",
+		eventType: "check_suite",
+		payload: map[string]any{
+			"check_suite": map[string]any{
+				"head_sha":      "abc123",
+				"head_branch":   "feature",
+				"pull_requests": []any{map[string]any{"number": 1}},
+			},
+		},
+		want: map[string]any{"headsha": "abc123", "headbranch": "feature"},
+	}, {
+		name:      "check_suite without pull requests",
+		eventType: "check_suite",
+		payload: map[string]any{
+			"check_suite": map[string]any{
+				"head_sha":      "abc123",
+				"head_branch":   "main",
+				"pull_requests": []any{},
+			},
+		},
+		want: map[string]any{"headsha": "abc123", "headbranch": "main"},
+	}, {
+		name:      "check_run",
+		eventType: "check_run",
+		payload: map[string]any{
+			"check_run": map[string]any{
+				"head_sha": "def456",
+				"check_suite": map[string]any{
+					"head_branch": "feature",
+				},
+			},
+		},
+		want: map[string]any{"headsha": "def456", "headbranch": "feature"},
+	}, {
+		name:      "check_run without suite branch",
+		eventType: "check_run",
+		payload: map[string]any{
+			"check_run": map[string]any{
+				"head_sha": "def456",
+			},
+		},
+		want: map[string]any{"headsha": "def456"},
+	}, {
+		name:      "check_suite missing head",
+		eventType: "check_suite",
+		payload:   map[string]any{"check_suite": map[string]any{}},
+		want:      map[string]any{},
+	}, {
+		name:      "head fields on other events are ignored",
+		eventType: "push",
+		payload: map[string]any{
+			"check_suite": map[string]any{"head_sha": "abc123"},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "pull_request refs",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"pull_request": map[string]any{
+				"base": map[string]any{"ref": "main"},
+				"head": map[string]any{"ref": "feature"},
+			},
+		},
+		want: map[string]any{"basebranch": "main", "headbranch": "feature"},
+	}, {
+		name:      "deployment",
+		eventType: "deployment",
+		payload: map[string]any{
+			"deployment": map[string]any{"environment": "production"},
+		},
+		want: map[string]any{"environment": "production"},
+	}, {
+		name:      "deployment_status",
+		eventType: "deployment_status",
+		payload: map[string]any{
+			"deployment":        map[string]any{"environment": "production"},
+			"deployment_status": map[string]any{"state": "success", "environment": "staging"},
+		},
+		want: map[string]any{"environment": "staging", "deploymentstate": "success"},
+	}, {
+		name:      "deployment_status environment from deployment",
+		eventType: "deployment_status",
+		payload: map[string]any{
+			"deployment":        map[string]any{"environment": "production"},
+			"deployment_status": map[string]any{"state": "failure"},
+		},
+		want: map[string]any{"environment": "production", "deploymentstate": "failure"},
+	}, {
+		name:      "deployment_status missing fields",
+		eventType: "deployment_status",
+		payload: map[string]any{
+			"deployment_status": map[string]any{},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "deployment fields on other events are ignored",
+		eventType: "push",
+		payload: map[string]any{
+			"deployment": map[string]any{"environment": "production"},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "private repository",
+		eventType: "push",
+		payload: map[string]any{
+			"repository": map[string]any{"private": true},
+		},
+		want: map[string]any{"repovisibility": "private"},
+	}, {
+		name:      "public repository",
+		eventType: "push",
+		payload: map[string]any{
+			"repository": map[string]any{"private": false},
+		},
+		want: map[string]any{"repovisibility": "public"},
+	}, {
+		name:      "repository visibility takes precedence",
+		eventType: "push",
+		payload: map[string]any{
+			"repository": map[string]any{"private": true, "visibility": "internal"},
+		},
+		want: map[string]any{"repovisibility": "internal"},
+	}, {
+		name:      "pull request url",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"pull_request": map[string]any{"number": 42},
+			"repository": map[string]any{
+				"name":  "repo",
+				"owner": map[string]any{"login": "org"},
+			},
+		},
+		want: map[string]any{"pullrequesturl": "https://github.com/org/repo/pull/42"},
+	}, {
+		name:      "pull request without repository owner",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"pull_request": map[string]any{"number": 42},
+			"repository": map[string]any{
+				"name":  "repo",
+				"owner": map[string]any{"login": ""},
+			},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "pull request without repository",
+		eventType: "pull_request_review",
+		payload: map[string]any{
+			"pull_request": map[string]any{"number": 42},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "pull request without number",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"repository": map[string]any{
+				"name":  "repo",
+				"owner": map[string]any{"login": "org"},
+			},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "issue url",
+		eventType: "issue_comment",
+		payload: map[string]any{
+			"issue": map[string]any{"number": 7},
+			"repository": map[string]any{
+				"name":  "repo",
+				"owner": map[string]any{"login": "org"},
+			},
+		},
+		want: map[string]any{"issueurl": "https://github.com/org/repo/issues/7"},
+	}, {
+		name:      "issue without repository name",
+		eventType: "issues",
+		payload: map[string]any{
+			"issue": map[string]any{"number": 7},
+			"repository": map[string]any{
+				"owner": map[string]any{"login": "org"},
+			},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "discussion url",
+		eventType: "discussion",
+		payload: map[string]any{
+			"discussion": map[string]any{"number": 3},
+			"repository": map[string]any{
+				"name":  "repo",
+				"owner": map[string]any{"login": "org"},
+			},
+		},
+		want: map[string]any{"discussionurl": "https://github.com/org/repo/discussions/3"},
+	}, {
+		name:      "discussion comment url",
+		eventType: "discussion_comment",
+		payload: map[string]any{
+			"discussion": map[string]any{"number": 3},
+			"comment":    map[string]any{"id": 1},
+			"repository": map[string]any{
+				"name":  "repo",
+				"owner": map[string]any{"login": "org"},
+			},
+		},
+		want: map[string]any{"discussionurl": "https://github.com/org/repo/discussions/3"},
+	}, {
+		name:      "discussion without number",
+		eventType: "discussion",
+		payload: map[string]any{
+			"discussion": map[string]any{},
+			"repository": map[string]any{
+				"name":  "repo",
+				"owner": map[string]any{"login": "org"},
+			},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "discussion comment without repository owner",
+		eventType: "discussion_comment",
+		payload: map[string]any{
+			"discussion": map[string]any{"number": 3},
+			"repository": map[string]any{"name": "repo"},
+		},
+		want: map[string]any{},
+	}, {
+		name:      "pull request labeled",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"action": "labeled",
+			"label":  map[string]any{"name": "automerge"},
+			"pull_request": map[string]any{
+				"labels": []any{
+					map[string]any{"name": "bug"},
+					map[string]any{"name": "automerge"},
+				},
+			},
+		},
+		want: map[string]any{"action": "labeled", "label": "automerge", "labels": "bug,automerge"},
+	}, {
+		name:      "issue unlabeled",
+		eventType: "issues",
+		payload: map[string]any{
+			"action": "unlabeled",
+			"label":  map[string]any{"name": "triage"},
+			"issue":  map[string]any{"labels": []any{}},
+		},
+		want: map[string]any{"action": "unlabeled", "label": "triage"},
+	}, {
+		name:      "labels on other actions are ignored",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"action": "opened",
+			"pull_request": map[string]any{
+				"labels": []any{map[string]any{"name": "bug"}},
+			},
+		},
+		want: map[string]any{"action": "opened"},
+	}, {
+		name:      "workflow job",
+		eventType: "workflow_job",
+		payload: map[string]any{
+			"workflow_job": map[string]any{"run_id": 30433642, "status": "completed"},
+			"repository":   map[string]any{"name": "repo", "owner": map[string]any{"login": "org"}},
+		},
+		want: map[string]any{
+			"runurl":    "https://github.com/org/repo/actions/runs/30433642",
+			"jobstatus": "completed",
+		},
+	}, {
+		name:      "installation",
+		eventType: "installation",
+		payload: map[string]any{
+			"action":       "suspend",
+			"organization": map[string]any{"login": "org"},
+		},
+		want: map[string]any{"action": "suspend", "installationaction": "suspend", "org": "org"},
+	}, {
+		name:      "installation repositories",
+		eventType: "installation_repositories",
+		payload: map[string]any{
+			"action":               "added",
+			"repositories_added":   []any{map[string]any{"full_name": "org/a"}, map[string]any{"full_name": "org/b"}},
+			"repositories_removed": []any{},
+		},
+		want: map[string]any{"action": "added", "repositoriesadded": "org/a,org/b"},
+	}, {
+		name:      "status",
+		eventType: "status",
+		payload: map[string]any{
+			"sha":     "abc123",
+			"state":   "failure",
+			"context": "ci/build",
+			"commit":  map[string]any{"sha": "abc123"},
+		},
+		want: map[string]any{"sha": "abc123", "statusstate": "failure", "statuscontext": "ci/build"},
+	}, {
+		name:      "installation id",
+		eventType: "push",
+		payload:   map[string]any{"installation": map[string]any{"id": 12345}},
+		want:      map[string]any{"installationid": "12345"},
+	}, {
+		name:      "no repository",
+		eventType: "organization",
+		payload:   map[string]any{"organization": map[string]any{"login": "org"}},
+		want:      map[string]any{"org": "org"},
+	}} {
+		for _, hc := range []struct{ name, host string }{
+			{"github.com", ""},
+			{"enterprise", "https://github.example.com"},
+		} {
+			host := hc.host
+			t.Run(tt.name+" on "+hc.name, func(t *testing.T) {
+				// URLs in want are for github.com, the default host.
+				want := make(map[string]any, len(tt.want))
+				for k, v := range tt.want {
+					if s, ok := v.(string); ok && host != "" {
+						v = strings.Replace(s, defaultGitHubHost, host, 1)
+					}
+					want[k] = v
+				}
+
+				secret := []byte("hunter2")
+				client := &fakeClient{}
+				rec := httptest.NewRecorder()
+				NewServer(client, [][]byte{secret}, ServerOptions{GitHubHost: host}).ServeHTTP(rec, newRequest(t, tt.eventType, secret, tt.payload))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+				}
+				if len(client.events) != 1 {
+					t.Fatalf("sent %d events, wanted 1", len(client.events))
+				}
+				if diff := cmp.Diff(want, client.events[0].Extensions(), cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("Extensions() (-want +got): %s", diff)
+				}
+			})
+		}
+	}
+}
+
+// recordingAuditSink is an AuditSink that records outcomes.
+type recordingAuditSink struct {
+	m        sync.Mutex
+	outcomes []DeliveryOutcome
+}
+
+func (s *recordingAuditSink) RecordDelivery(_ context.Context, outcome DeliveryOutcome) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.outcomes = append(s.outcomes, outcome)
+}
+
+func TestTrampolineAuditSink(t *testing.T) {
+	secret := []byte("hunter2")
+	sink := &recordingAuditSink{}
+	srv := NewServer(&fakeClient{result: cehttp.NewResult(http.StatusInternalServerError, "%w", cloudevents.ResultNACK)}, [][]byte{secret}, ServerOptions{
+		AllowedEventTypes: []string{"push"},
+		AuditSink:         sink,
+	})
+
+	for i, req := range []*http.Request{
+		newRequest(t, "push", secret, map[string]any{}),
+		newRequest(t, "issues", secret, map[string]any{}),
+		// Deliveries failing verification aren't recorded.
+		newRequest(t, "push", []byte("wrong"), map[string]any{}),
+	} {
+		req.Header.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", i))
+		srv.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	for _, o := range sink.outcomes {
+		if o.Latency <= 0 {
+			t.Errorf("Latency = %v, wanted > 0", o.Latency)
+		}
+	}
+	want := []DeliveryOutcome{{
+		DeliveryID: "delivery-0",
+		EventType:  "push",
+		Status:     http.StatusServiceUnavailable,
+	}, {
+		DeliveryID: "delivery-1",
+		EventType:  "issues",
+		Status:     http.StatusAccepted,
+	}}
+	if diff := cmp.Diff(want, sink.outcomes, cmpopts.IgnoreFields(DeliveryOutcome{}, "Latency")); diff != "" {
+		t.Errorf("outcomes (-want +got): %s", diff)
+	}
+}
+
+func TestTrampolineAuditSinkSuccess(t *testing.T) {
+	secret := []byte("hunter2")
+	sink := &recordingAuditSink{}
+	srv := NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{AuditSink: sink})
+
+	srv.ServeHTTP(httptest.NewRecorder(), newRequest(t, "Push", secret, map[string]any{}))
+	want := []DeliveryOutcome{{EventType: "push", Status: http.StatusOK}}
+	if diff := cmp.Diff(want, sink.outcomes, cmpopts.IgnoreFields(DeliveryOutcome{}, "Latency")); diff != "" {
+		t.Errorf("outcomes (-want +got): %s", diff)
+	}
+}
+
+func TestTrampolineExtraExtensions(t *testing.T) {
+	secret := []byte("hunter2")
+	client := &fakeClient{}
+	srv := NewServer(client, [][]byte{secret}, ServerOptions{
+		ExtraExtensions: map[string]string{
+			"sender":  "sender.login",
+			"label":   "issue.labels[1].name",
+			"missing": "issue.assignee.login",
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "issues", secret, map[string]any{
+		"action": "labeled",
+		"label":  map[string]any{"name": "bug"},
+		"issue": map[string]any{
+			"labels": []any{map[string]any{"name": "bug"}, map[string]any{"name": "triage"}},
+		},
+		"sender": map[string]any{"login": "octocat"},
+	}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("sent %d events, wanted 1", len(client.events))
+	}
+	sdktest.AssertExtension(t, client.events[0], "sender", "octocat")
+	// The configured path overrides the built-in extension.
+	sdktest.AssertExtension(t, client.events[0], "label", "triage")
+	sdktest.AssertNoExtension(t, client.events[0], "missing")
+}
+
+// blockingVerifier accepts every delivery once released, signalling on
+// started when a verification begins.
+type blockingVerifier struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (v blockingVerifier) Verify(*http.Request) ([]byte, error) {
+	v.started <- struct{}{}
+	<-v.release
+	return []byte(`{}`), nil
+}
+
+func TestTrampolineMaxConcurrent(t *testing.T) {
+	v := blockingVerifier{started: make(chan struct{}), release: make(chan struct{})}
+	srv := NewServer(&fakeClient{}, nil, ServerOptions{Verifier: v, MaxConcurrent: 2})
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for range 2 {
+		req := newRequest(t, "push", nil, map[string]any{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			codes <- rec.Code
+		}()
+		<-v.started
+	}
+
+	before := testutil.ToFloat64(mConcurrencyRejections)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "push", nil, map[string]any{}))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := testutil.ToFloat64(mConcurrencyRejections) - before; got != 1 {
+		t.Errorf("rejections = %v, wanted 1", got)
+	}
+
+	close(v.release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("held request status = %d, wanted %d", code, http.StatusOK)
+		}
+	}
+
+	// Capacity is freed once the held requests complete.
+	go func() { <-v.started }()
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, newRequest(t, "push", nil, map[string]any{}))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReconstructWebhook(t *testing.T) {
+	secret := []byte("hunter2")
+
+	for _, tt := range []struct {
+		name        string
+		opts        ServerOptions
+		wantHeaders http.Header
+	}{{
+		name: "default",
+		wantHeaders: http.Header{
+			"Content-Type":      []string{"application/json"},
+			"X-Github-Event":    []string{"issues"},
+			"X-Github-Delivery": []string{"72d3162e-cc78-11e3-81ab-4c9367dc0958"},
+		},
+	}, {
+		name: "delivery headers",
+		opts: ServerOptions{IncludeDeliveryHeaders: true},
+		wantHeaders: http.Header{
+			"Content-Type":      []string{"application/json"},
+			"X-Github-Event":    []string{"issues"},
+			"X-Github-Delivery": []string{"72d3162e-cc78-11e3-81ab-4c9367dc0958"},
+			"X-Github-Hook-Id":  []string{"292430182"},
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, tt.opts)
+
+			req := newRequest(t, "issues", secret, map[string]any{
+				"action": "opened",
+				"issue":  map[string]any{"number": 1, "title": "Flaky test"},
+			})
+			req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+			req.Header.Set("X-GitHub-Hook-ID", "292430182")
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("ReadAll() = %v", err)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			srv.ServeHTTP(httptest.NewRecorder(), req)
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+
+			headers, gotBody, err := webhook.ReconstructWebhook(client.events[0])
+			if err != nil {
+				t.Fatalf("ReconstructWebhook() = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantHeaders, headers); diff != "" {
+				t.Errorf("headers (-want +got): %s", diff)
+			}
+			if !bytes.Equal(gotBody, body) {
+				t.Errorf("body = %s, wanted %s", gotBody, body)
+			}
+		})
+	}
+}
+
+func TestTrampolineOrgRateLimit(t *testing.T) {
+	secret := []byte("hunter2")
+	srv := NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{
+		OrgRateLimit: RateLimit{Rate: 1, Burst: 2},
+	})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() time.Time { return now }
+
+	send := func(org string) *httptest.ResponseRecorder {
+		payload := map[string]any{}
+		if org != "" {
+			payload["organization"] = map[string]any{"login": org}
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, newRequest(t, "push", secret, payload))
+		return rec
+	}
+
+	orgBefore := testutil.ToFloat64(mRateLimited.With(prometheus.Labels{"key_class": "org"}))
+	defaultBefore := testutil.ToFloat64(mRateLimited.With(prometheus.Labels{"key_class": "default"}))
+
+	// The burst is allowed, and then the organization is limited.
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if rec := send("noisy"); rec.Code != want {
+			t.Errorf("delivery %d: status = %d, wanted %d", i, rec.Code, want)
+		} else if want == http.StatusTooManyRequests {
+			if got := rec.Header().Get("Retry-After"); got != "1" {
+				t.Errorf("Retry-After = %q, wanted %q", got, "1")
+			}
+		}
+	}
+	// Other organizations have their own buckets.
+	if rec := send("quiet"); rec.Code != http.StatusOK {
+		t.Errorf("other organization: status = %d, wanted %d", rec.Code, http.StatusOK)
+	}
+	// Deliveries without an organization share the default bucket.
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if rec := send(""); rec.Code != want {
+			t.Errorf("delivery %d without organization: status = %d, wanted %d", i, rec.Code, want)
+		}
+	}
+
+	if got := testutil.ToFloat64(mRateLimited.With(prometheus.Labels{"key_class": "org"})) - orgBefore; got != 1 {
+		t.Errorf("org rate limited = %v, wanted 1", got)
+	}
+	if got := testutil.ToFloat64(mRateLimited.With(prometheus.Labels{"key_class": "default"})) - defaultBefore; got != 1 {
+		t.Errorf("default rate limited = %v, wanted 1", got)
+	}
+
+	// The bucket refills over time.
+	now = now.Add(time.Second)
+	if rec := send("noisy"); rec.Code != http.StatusOK {
+		t.Errorf("after refill: status = %d, wanted %d", rec.Code, http.StatusOK)
+	}
+	if rec := send("noisy"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("after refill: status = %d, wanted %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestTrampolineOrgRateLimitAfterFilters(t *testing.T) {
+	secret := []byte("hunter2")
+	srv := NewServer(&fakeClient{}, [][]byte{secret}, ServerOptions{
+		OrgRateLimit: RateLimit{Rate: 1, Burst: 1},
+		DropSenders:  []string{"renovate[bot]"},
+	})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() time.Time { return now }
+
+	send := func(sender string) int {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, newRequest(t, "push", secret, map[string]any{
+			"organization": map[string]any{"login": "org"},
+			"sender":       map[string]any{"login": sender},
+		}))
+		return rec.Code
+	}
+
+	// Dropped deliveries don't use up the organization's budget.
+	for range 3 {
+		if got := send("renovate[bot]"); got != http.StatusAccepted {
+			t.Errorf("filtered delivery: status = %d, wanted %d", got, http.StatusAccepted)
+		}
+	}
+	if got := send("octocat"); got != http.StatusOK {
+		t.Errorf("status = %d, wanted %d", got, http.StatusOK)
+	}
+	if got := send("octocat"); got != http.StatusTooManyRequests {
+		t.Errorf("status = %d, wanted %d", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestOrgLimiterEvictsIdleBuckets(t *testing.T) {
+	l := newOrgLimiter(RateLimit{Rate: 1, Burst: 2})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, org := range []string{"a", "b", "c"} {
+		l.allow(org, now)
+	}
+	if got := len(l.buckets); got != 3 {
+		t.Fatalf("buckets = %d, wanted 3", got)
+	}
+
+	// Buckets idle for long enough to be full again are evicted, while
+	// those still refilling are kept.
+	now = now.Add(time.Second)
+	l.allow("a", now)
+	now = now.Add(time.Second)
+	l.allow("d", now)
+	if got := len(l.buckets); got != 2 {
+		t.Errorf("buckets = %d, wanted 2: %v", got, l.buckets)
+	}
+	for _, org := range []string{"a", "d"} {
+		if _, ok := l.buckets[org]; !ok {
+			t.Errorf("bucket of %q was evicted", org)
+		}
+	}
+}
+
+func TestTrampolineBranchFilter(t *testing.T) {
+	secret := []byte("hunter2")
+	pullRequest := func(base string) map[string]any {
+		return map[string]any{
+			"action":       "opened",
+			"pull_request": map[string]any{"base": map[string]any{"ref": base}, "head": map[string]any{"ref": "feature"}},
+		}
+	}
+	for _, tt := range []struct {
+		name      string
+		eventType string
+		payload   map[string]any
+		forwarded bool
+	}{
+		{"push to main", "push", map[string]any{"ref": "refs/heads/main"}, true},
+		{"push to release branch", "push", map[string]any{"ref": "refs/heads/release/v1.2"}, true},
+		{"push to feature branch", "push", map[string]any{"ref": "refs/heads/feature"}, false},
+		{"push to nested release branch", "push", map[string]any{"ref": "refs/heads/release/v1/hotfix"}, false},
+		{"push of tag", "push", map[string]any{"ref": "refs/tags/v1.2.0"}, true},
+		{"pull request to main", "pull_request", pullRequest("main"), true},
+		{"pull request to feature branch", "pull_request", pullRequest("develop"), false},
+		{"other event types pass", "check_suite", map[string]any{"check_suite": map[string]any{"head_branch": "develop"}}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, ServerOptions{BranchFilter: []string{"main", "release/*"}})
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, tt.eventType, secret, tt.payload))
+			want := http.StatusOK
+			if !tt.forwarded {
+				want = http.StatusAccepted
+			}
+			if rec.Code != want {
+				t.Errorf("status = %d, wanted %d", rec.Code, want)
+			}
+			if got := len(client.events) == 1; got != tt.forwarded {
+				t.Errorf("forwarded = %t, wanted %t", got, tt.forwarded)
+			}
+		})
+	}
+}
+
+func TestTrampolineRegionInstance(t *testing.T) {
+	secret := []byte("hunter2")
+	for _, tt := range []struct {
+		name             string
+		region, instance string
+		want             map[string]any
+	}{
+		{"unset", "", "", map[string]any{}},
+		{"region", "us-central1", "", map[string]any{"region": "us-central1"}},
+		{"both", "europe-west4", "trampoline-00042-abc", map[string]any{"region": "europe-west4", "instance": "trampoline-00042-abc"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, ServerOptions{Region: tt.region, Instance: tt.instance})
+			srv.ServeHTTP(httptest.NewRecorder(), newRequest(t, "push", secret, map[string]any{}))
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			if diff := cmp.Diff(tt.want, client.events[0].Extensions(), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Extensions() (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestTrampolineVerboseResponses(t *testing.T) {
+	secret := []byte("hunter2")
+	opts := ServerOptions{
+		AllowedEventTypes: []string{"push", "issues"},
+		DropSenders:       []string{"renovate[bot]"},
+		BranchFilter:      []string{"main"},
+	}
+	for _, tt := range []struct {
+		name      string
+		verbose   bool
+		eventType string
+		payload   map[string]any
+		want      string
+	}{
+		{"filtered event type", true, "star", map[string]any{}, `{"skipped":"filtered_event_type"}` + "\n"},
+		{"filtered sender", true, "issues", map[string]any{"sender": map[string]any{"login": "renovate[bot]"}}, `{"skipped":"filtered_sender"}` + "\n"},
+		{"filtered branch", true, "push", map[string]any{"ref": "refs/heads/feature"}, `{"skipped":"filtered_branch"}` + "\n"},
+		{"quiet by default", false, "issues", map[string]any{"sender": map[string]any{"login": "renovate[bot]"}}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := opts
+			opts.VerboseResponses = tt.verbose
+			client := &fakeClient{}
+			rec := httptest.NewRecorder()
+			NewServer(client, [][]byte{secret}, opts).ServeHTTP(rec, newRequest(t, tt.eventType, secret, tt.payload))
+			if rec.Code != http.StatusAccepted {
+				t.Errorf("status = %d, wanted %d", rec.Code, http.StatusAccepted)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("body = %q, wanted %q", got, tt.want)
+			}
+			if len(client.events) != 0 {
+				t.Errorf("sent %d events, wanted 0", len(client.events))
+			}
+		})
+	}
+}
+
+func TestTrampolinePayloadEventTime(t *testing.T) {
+	secret := []byte("hunter2")
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 5, 31, 8, 30, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name      string
+		eventType string
+		payload   map[string]any
+		want      time.Time
+	}{{
+		name:      "payload timestamp",
+		eventType: "pull_request",
+		payload: map[string]any{
+			"action":       "opened",
+			"pull_request": map[string]any{"number": 1, "updated_at": updated.Format(time.RFC3339)},
+		},
+		want: updated,
+	}, {
+		name:      "no payload timestamp",
+		eventType: "member",
+		payload:   map[string]any{"action": "added"},
+		want:      now,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, ServerOptions{PayloadEventTime: true})
+			srv.now = func() time.Time { return now }
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, tt.eventType, secret, tt.payload))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			if len(client.events) != 1 {
+				t.Fatalf("sent %d events, wanted 1", len(client.events))
+			}
+			event := client.events[0]
+			if got := event.Time(); !got.Equal(tt.want) {
+				t.Errorf("Time() = %v, wanted %v", got, tt.want)
+			}
+
+			var data webhook.Envelope
+			if err := event.DataAs(&data); err != nil {
+				t.Fatalf("DataAs() = %v", err)
+			}
+			if !data.When.Equal(tt.want) {
+				t.Errorf("when = %v, wanted %v", data.When, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrampolineFanOutPushFiles(t *testing.T) {
+	secret := []byte("hunter2")
+	payload := map[string]any{
+		"ref": "refs/heads/main",
+		"commits": []any{
+			map[string]any{"added": []any{"a.go"}, "modified": []any{"b.go"}},
+			map[string]any{"removed": []any{"c.go"}},
+		},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		maxFiles int
+		want     []map[string]any
+	}{{
+		name: "fan out",
+		want: []map[string]any{
+			{"filepath": "a.go", "filechange": "added"},
+			{"filepath": "b.go", "filechange": "modified"},
+			{"filepath": "c.go", "filechange": "removed"},
+		},
+	}, {
+		name:     "too many files",
+		maxFiles: 2,
+		want:     []map[string]any{{}},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{}
+			srv := NewServer(client, [][]byte{secret}, ServerOptions{FanOutPushFiles: true, FanOutPushMaxFiles: tt.maxFiles})
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, newRequest(t, "push", secret, payload))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+
+			got := make([]map[string]any, 0, len(client.events))
+			for _, event := range client.events {
+				if event.Type() != "dev.chainguard.github.push" {
+					t.Errorf("Type() = %q, wanted push", event.Type())
+				}
+				exts := map[string]any{}
+				for _, name := range []string{"filepath", "filechange"} {
+					if v, ok := event.Extensions()[name]; ok {
+						exts[name] = v
+					}
+				}
+				got = append(got, exts)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("forwarded extensions (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestTrampolineFanOutPushFilesNACK(t *testing.T) {
+	secret := []byte("hunter2")
+	payload := map[string]any{
+		"commits": []any{
+			map[string]any{"added": []any{"a.go", "b.go", "c.go"}},
+		},
+	}
+
+	var sent []string
+	client := sendFuncClient{send: func(event cloudevents.Event) cloudevents.Result {
+		path, _ := event.Extensions()["filepath"].(string)
+		sent = append(sent, path)
+		if path == "b.go" {
+			return cloudevents.NewReceipt(false, "nope")
+		}
+		return nil
+	}}
+	deadLetter := &memQueue{}
+	req := newRequest(t, "push", secret, payload)
+	req.Header.Set("X-GitHub-Delivery", "delivery-1")
+	rec := httptest.NewRecorder()
+	NewServer(client, [][]byte{secret}, ServerOptions{FanOutPushFiles: true, DeadLetter: deadLetter}).ServeHTTP(rec, req)
+
+	// The sender redelivers all of the events, so the rest aren't sent.
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, wanted %d: %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+	if diff := cmp.Diff([]string{"a.go", "b.go"}, sent); diff != "" {
+		t.Errorf("sent events (-want +got): %s", diff)
+	}
+	var event cloudevents.Event
+	if err := json.Unmarshal(deadLetter.items["delivery-1"], &event); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+	if got, want := event.Extensions()["filepath"], "b.go"; got != want {
+		t.Errorf("dead-lettered filepath = %v, wanted %v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Validate reports options that can't work, so that servers fail at startup
+// rather than on every delivery. secrets are the secrets passed to NewServer.
+// It reports:
+//
+//   - no secrets to verify deliveries with, or empty ones, which anyone can
+//     sign with, unless Verifier is set.
+//   - malformed patterns in the filters.
+//   - an invalid GitHubHost.
+//   - invalid extension names.
+//   - negative limits.
+//   - FanOutPushFiles with DropNACKs, which would drop the events after one
+//     that is NACKed.
+//
+// All problems are reported, joined.
+func (o ServerOptions) Validate(secrets [][]byte) error {
+	var errs []error
+	if o.Verifier == nil {
+		switch {
+		case len(secrets) == 0:
+			errs = append(errs, errors.New("no webhook secrets, so no delivery would verify"))
+		case slices.ContainsFunc(secrets, func(s []byte) bool { return len(s) == 0 }):
+			errs = append(errs, errors.New("empty webhook secret, which anyone can sign deliveries with"))
+		}
+	}
+
+	for name, entries := range map[string][]string{
+		"AllowedEventTypes":   o.AllowedEventTypes,
+		"BranchFilter":        o.BranchFilter,
+		"MetricRepoAllowlist": o.MetricRepoAllowlist,
+		"DropSenders":         o.DropSenders,
+	} {
+		for _, e := range entries {
+			if strings.ContainsAny(e, `*?[\`) && !validPattern(e) {
+				errs = append(errs, fmt.Errorf("%s: malformed pattern %q", name, e))
+			}
+		}
+	}
+
+	if o.GitHubHost != "" {
+		if u, err := url.Parse(o.GitHubHost); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("GitHubHost: %q is not a valid base URL", o.GitHubHost))
+		}
+	}
+
+	for name := range o.ExtraExtensions {
+		if !validExtensionName(name) {
+			errs = append(errs, fmt.Errorf("ExtraExtensions: %q is not a valid extension name", name))
+		}
+	}
+
+	if o.MaxEventAge < 0 {
+		errs = append(errs, fmt.Errorf("MaxEventAge: negative duration %v", o.MaxEventAge))
+	}
+	if o.MaxConcurrent < 0 {
+		errs = append(errs, fmt.Errorf("MaxConcurrent: negative limit %d", o.MaxConcurrent))
+	}
+	if o.OrgRateLimit.Rate < 0 || o.OrgRateLimit.Burst < 0 {
+		errs = append(errs, fmt.Errorf("OrgRateLimit: negative rate %v or burst %d", o.OrgRateLimit.Rate, o.OrgRateLimit.Burst))
+	}
+	if o.RetryJitter < 0 {
+		errs = append(errs, fmt.Errorf("RetryJitter: negative jitter %v", o.RetryJitter))
+	}
+	if o.RetryPolicy.Delay < 0 {
+		errs = append(errs, fmt.Errorf("RetryPolicy: negative delay %v", o.RetryPolicy.Delay))
+	}
+	for t, p := range o.RetryByEventType {
+		if p.Delay < 0 {
+			errs = append(errs, fmt.Errorf("RetryByEventType[%q]: negative delay %v", t, p.Delay))
+		}
+	}
+	if o.FanOutPushFiles && o.DropNACKs && o.Queue == nil {
+		errs = append(errs, errors.New("FanOutPushFiles: can't be combined with DropNACKs, which would drop the events after a NACK"))
+	}
+	return errors.Join(errs...)
+}
+
+// validExtensionName reports whether name is a valid CloudEvents attribute
+// name, i.e. lowercase letters and digits.
+func validExtensionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
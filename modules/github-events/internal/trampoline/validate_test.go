@@ -0,0 +1,101 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	secrets := [][]byte{[]byte("hunter2")}
+
+	for _, tt := range []struct {
+		name    string
+		secrets [][]byte
+		opts    ServerOptions
+		want    string
+	}{{
+		name:    "valid",
+		secrets: secrets,
+		opts: ServerOptions{
+			AllowedEventTypes: []string{"pull_request*", "push"},
+			DropSenders:       []string{"renovate[bot]", "*"},
+			GitHubHost:        "https://github.example.com",
+			ExtraExtensions:   map[string]string{"sender": "sender.login"},
+			MaxEventAge:       time.Hour,
+			MaxConcurrent:     10,
+			OrgRateLimit:      RateLimit{Rate: 1, Burst: 2},
+			RetryPolicy:       RetryPolicy{MaxRetries: -1},
+		},
+	}, {
+		name: "verifier without secrets",
+		opts: ServerOptions{Verifier: GitLabVerifier{}},
+	}, {
+		name: "no secrets",
+		want: "no webhook secrets",
+	}, {
+		name:    "empty secret",
+		secrets: [][]byte{[]byte("hunter2"), {}},
+		want:    "empty webhook secret",
+	}, {
+		name:    "malformed pattern",
+		secrets: secrets,
+		opts:    ServerOptions{BranchFilter: []string{"release-["}},
+		want:    `BranchFilter: malformed pattern "release-["`,
+	}, {
+		name:    "invalid host",
+		secrets: secrets,
+		opts:    ServerOptions{GitHubHost: "github.example.com"},
+		want:    "GitHubHost",
+	}, {
+		name:    "invalid extension name",
+		secrets: secrets,
+		opts:    ServerOptions{ExtraExtensions: map[string]string{"sender-login": "sender.login"}},
+		want:    "ExtraExtensions",
+	}, {
+		name:    "negative max event age",
+		secrets: secrets,
+		opts:    ServerOptions{MaxEventAge: -time.Minute},
+		want:    "MaxEventAge",
+	}, {
+		name:    "negative max concurrent",
+		secrets: secrets,
+		opts:    ServerOptions{MaxConcurrent: -1},
+		want:    "MaxConcurrent",
+	}, {
+		name:    "negative rate limit",
+		secrets: secrets,
+		opts:    ServerOptions{OrgRateLimit: RateLimit{Rate: -1}},
+		want:    "OrgRateLimit",
+	}, {
+		name:    "fan out with dropped NACKs",
+		secrets: secrets,
+		opts:    ServerOptions{FanOutPushFiles: true, DropNACKs: true},
+		want:    "FanOutPushFiles",
+	}, {
+		name:    "negative jitter",
+		secrets: secrets,
+		opts:    ServerOptions{RetryJitter: -0.5},
+		want:    "RetryJitter",
+	}, {
+		name:    "negative retry delay",
+		secrets: secrets,
+		opts:    ServerOptions{RetryByEventType: map[string]RetryPolicy{"push": {Delay: -time.Second}}},
+		want:    `RetryByEventType["push"]`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate(tt.secrets)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, wanted nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, wanted error containing %q", err, tt.want)
+			}
+		})
+	}
+}
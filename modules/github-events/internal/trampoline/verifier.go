@@ -0,0 +1,181 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trampoline
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// Errors returned by the verifiers in this package, wrapped with details.
+// Custom verifiers can wrap them too, so that failures are reported under
+// the right reason.
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrBadSignature     = errors.New("bad signature")
+	ErrReadBody         = errors.New("reading body")
+
+	ErrHookTargetMismatch = errors.New("unexpected hook installation target")
+)
+
+// Headers identifying what the webhook that sent a delivery is installed on,
+// e.g. an "integration" (GitHub App) or "repository", and its ID.
+//
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads#delivery-headers
+const (
+	HookTargetTypeHeader = "X-GitHub-Hook-Installation-Target-Type"
+	HookTargetIDHeader   = "X-GitHub-Hook-Installation-Target-ID"
+)
+
+// Verifier authenticates an incoming webhook delivery and returns its payload.
+type Verifier interface {
+	Verify(r *http.Request) (payload []byte, err error)
+}
+
+// GitHubVerifier verifies GitHub webhook signatures. Deliveries signed with
+// any of the non-empty secrets are accepted, which allows secrets to be
+// rotated without downtime. Empty secrets are skipped, as anyone can sign
+// with an empty key.
+//
+// https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+type GitHubVerifier struct {
+	Secrets [][]byte
+}
+
+var _ Verifier = GitHubVerifier{}
+
+func (v GitHubVerifier) Verify(r *http.Request) ([]byte, error) {
+	if r.Header.Get(github.SHA256SignatureHeader) == "" && r.Header.Get(github.SHA1SignatureHeader) == "" {
+		return nil, ErrMissingSignature
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadBody, err)
+	}
+
+	err = errors.New("no webhook secrets configured")
+	for _, secret := range v.Secrets {
+		if len(secret) == 0 {
+			continue
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var payload []byte
+		if payload, err = github.ValidatePayload(r, secret); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrBadSignature, err)
+}
+
+// GitLabVerifier verifies the secret token GitLab sends with each delivery.
+//
+// https://docs.gitlab.com/ee/user/project/integrations/webhooks.html#validate-payloads-by-using-a-secret-token
+type GitLabVerifier struct {
+	Tokens [][]byte
+}
+
+var _ Verifier = GitLabVerifier{}
+
+func (v GitLabVerifier) Verify(r *http.Request) ([]byte, error) {
+	got := r.Header.Get(GitLabTokenHeader)
+	if got == "" {
+		return nil, fmt.Errorf("%w: no %s header", ErrMissingSignature, GitLabTokenHeader)
+	}
+	if !matchesAny([]byte(got), v.Tokens) {
+		return nil, fmt.Errorf("%w: token does not match", ErrBadSignature)
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadBody, err)
+	}
+	return payload, nil
+}
+
+// BitbucketVerifier verifies the HMAC signatures Bitbucket Cloud sends with
+// deliveries of webhooks that have a secret. Like GitHubVerifier, deliveries
+// signed with any of the secrets are accepted.
+//
+// https://support.atlassian.com/bitbucket-cloud/docs/manage-webhooks/#Secure-webhooks
+type BitbucketVerifier struct {
+	Secrets [][]byte
+}
+
+var _ Verifier = BitbucketVerifier{}
+
+func (v BitbucketVerifier) Verify(r *http.Request) ([]byte, error) {
+	sig, ok := strings.CutPrefix(r.Header.Get(BitbucketSignatureHeader), "sha256=")
+	if !ok {
+		return nil, fmt.Errorf("%w: no sha256 %s header", ErrMissingSignature, BitbucketSignatureHeader)
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadBody, err)
+	}
+	for _, secret := range v.Secrets {
+		if len(secret) == 0 {
+			continue
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(payload)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: signature does not match", ErrBadSignature)
+}
+
+// matchesAny compares got to each non-empty candidate in constant time.
+func matchesAny(got []byte, candidates [][]byte) bool {
+	for _, c := range candidates {
+		if len(c) > 0 && subtle.ConstantTimeCompare(got, c) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// verificationFailureReason classifies a verification error for metrics.
+func verificationFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingSignature):
+		return "missing_signature"
+	case errors.Is(err, ErrReadBody):
+		return "read_error"
+	case errors.Is(err, ErrHookTargetMismatch):
+		return "hook_target_mismatch"
+	default:
+		return "bad_signature"
+	}
+}
+
+// verifyHookTarget checks that the delivery was sent by a webhook installed on
+// the expected target, if any.
+func verifyHookTarget(r *http.Request, wantType, wantID string) error {
+	if got := r.Header.Get(HookTargetTypeHeader); wantType != "" && got != wantType {
+		return fmt.Errorf("%w: type %q, wanted %q", ErrHookTargetMismatch, got, wantType)
+	}
+	if got := r.Header.Get(HookTargetIDHeader); wantID != "" && got != wantID {
+		return fmt.Errorf("%w: ID %q, wanted %q", ErrHookTargetMismatch, got, wantID)
+	}
+	return nil
+}
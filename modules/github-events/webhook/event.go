@@ -0,0 +1,94 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-github/v60/github"
+)
+
+// EventTypePrefix prefixes the GitHub event type, e.g. "push", in the types
+// of forwarded events.
+const EventTypePrefix = "dev.chainguard.github."
+
+// EnvelopeVersion is the version of the Envelope, which lets consumers tell
+// envelopes apart. It is bumped whenever the envelope changes.
+//
+// Version 1 is {"version": 1, "when": <time>, "body": <payload>}. Envelopes
+// without a version predate versioning, and are version 1 without the field.
+const EnvelopeVersion = 1
+
+// Envelope is the data of forwarded events, which wraps the webhook payload.
+type Envelope struct {
+	Version int             `json:"version"`
+	When    time.Time       `json:"when"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// DeliveryExtension is the extension carrying the X-GitHub-Delivery header,
+// i.e. the delivery ID, which forwarded events always have.
+const DeliveryExtension = "ghdelivery"
+
+// InstallationExtension is the extension carrying the ID of the GitHub App
+// installation that received the delivery, which forwarded events have if the
+// payload has an installation block.
+const InstallationExtension = "installationid"
+
+// DeliveryHeaderExtensions maps GitHub delivery headers to the extensions
+// forwarded events report them as. Except for DeliveryExtension, they are
+// only reported if the trampoline is configured to. Each extension is "gh"
+// followed by the lowercased header name without the X-GitHub- prefix,
+// dashes or, for brevity, "Installation-".
+//
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads#delivery-headers
+var DeliveryHeaderExtensions = []struct {
+	Header, Extension string
+}{
+	{github.DeliveryIDHeader, DeliveryExtension},
+	{"X-GitHub-Hook-ID", "ghhookid"},
+	{"X-GitHub-Hook-Installation-Target-Type", "ghhooktargettype"},
+	{"X-GitHub-Hook-Installation-Target-ID", "ghhooktargetid"},
+	{"X-GitHub-Enterprise-Host", "ghenterprisehost"},
+	{"X-GitHub-Enterprise-Version", "ghenterpriseversion"},
+}
+
+// ReconstructWebhook rebuilds the GitHub webhook request of a forwarded
+// event, e.g. to replay it into tools that expect raw webhooks. The headers
+// include the X-GitHub-Event and X-GitHub-Delivery headers, and the other
+// delivery headers the event reports. The body is the payload as forwarded,
+// so it may be redacted and its whitespace may differ from the original.
+// Signatures can't be reconstructed.
+func ReconstructWebhook(event cloudevents.Event) (http.Header, []byte, error) {
+	eventType, ok := strings.CutPrefix(event.Type(), EventTypePrefix)
+	if !ok || eventType == "" {
+		return nil, nil, fmt.Errorf("not a GitHub event: %q", event.Type())
+	}
+	var data Envelope
+	if err := event.DataAs(&data); err != nil {
+		return nil, nil, fmt.Errorf("decoding event data: %w", err)
+	}
+	if len(data.Body) == 0 {
+		return nil, nil, errors.New("event has no webhook body")
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set(github.EventTypeHeader, eventType)
+	ext := event.Extensions()
+	for _, h := range DeliveryHeaderExtensions {
+		if v, ok := ext[h.Extension].(string); ok && v != "" {
+			headers.Set(h.Header, v)
+		}
+	}
+	return headers, data.Body, nil
+}
@@ -0,0 +1,29 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func TestReconstructWebhookErrors(t *testing.T) {
+	other := cloudevents.NewEvent()
+	other.SetType("dev.chainguard.gitlab.push")
+	if err := other.SetData(cloudevents.ApplicationJSON, Envelope{Body: []byte(`{}`)}); err != nil {
+		t.Fatalf("SetData() = %v", err)
+	}
+	if _, _, err := ReconstructWebhook(other); err == nil {
+		t.Error("ReconstructWebhook() = nil, wanted error for a non-GitHub event")
+	}
+
+	empty := cloudevents.NewEvent()
+	empty.SetType("dev.chainguard.github.push")
+	if _, _, err := ReconstructWebhook(empty); err == nil {
+		t.Error("ReconstructWebhook() = nil, wanted error for an event without data")
+	}
+}
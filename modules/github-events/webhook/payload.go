@@ -0,0 +1,176 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package webhook holds types for consumers of the GitHub webhook events that
+// the github-events trampoline forwards as CloudEvents.
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// PayloadInfo holds the fields of GitHub webhook payloads that the trampoline
+// uses to populate CloudEvent attributes and extensions, so that consumers
+// can parse the same fields from forwarded bodies with ParsePayload. Fields
+// are left empty when the payload doesn't carry them.
+type PayloadInfo struct {
+	Action       string           `json:"action"`
+	Ref          string           `json:"ref"`
+	Label        LabelInfo        `json:"label"`
+	CheckSuite   CheckSuiteInfo   `json:"check_suite"`
+	CheckRun     CheckRunInfo     `json:"check_run"`
+	PullRequest  PullRequestInfo  `json:"pull_request"`
+	Issue        IssueInfo        `json:"issue"`
+	Discussion   DiscussionInfo   `json:"discussion"`
+	Deployment   DeploymentInfo   `json:"deployment"`
+	Commits      []CommitInfo     `json:"commits"`
+	WorkflowRun  WorkflowRunInfo  `json:"workflow_run"`
+	WorkflowJob  WorkflowJobInfo  `json:"workflow_job"`
+	Repository   RepositoryInfo   `json:"repository"`
+	Sender       SenderInfo       `json:"sender"`
+	Organization OrganizationInfo `json:"organization"`
+	Installation InstallationInfo `json:"installation"`
+
+	DeploymentStatus DeploymentStatusInfo `json:"deployment_status"`
+
+	RepositoriesAdded   []RepositoryInfo `json:"repositories_added"`
+	RepositoriesRemoved []RepositoryInfo `json:"repositories_removed"`
+
+	// SHA, State and Context are the commit, e.g. "pending" or "success",
+	// and name of the commit status that status events are about.
+	SHA     string `json:"sha"`
+	State   string `json:"state"`
+	Context string `json:"context"`
+}
+
+// CheckSuiteInfo is the check_suite block of check_suite events.
+type CheckSuiteInfo struct {
+	HeadSHA    string    `json:"head_sha"`
+	HeadBranch string    `json:"head_branch"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CheckRunInfo is the check_run block of check_run events, whose check_suite
+// block is the check suite the run belongs to.
+type CheckRunInfo struct {
+	HeadSHA     string         `json:"head_sha"`
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt time.Time      `json:"completed_at"`
+	CheckSuite  CheckSuiteInfo `json:"check_suite"`
+}
+
+// PullRequestInfo is the pull_request block of pull_request* events.
+type PullRequestInfo struct {
+	Number    int         `json:"number"`
+	Merged    bool        `json:"merged"`
+	Draft     bool        `json:"draft"`
+	Labels    []LabelInfo `json:"labels"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Base      RefInfo     `json:"base"`
+	Head      RefInfo     `json:"head"`
+}
+
+// RefInfo is the base or head block of a pull request.
+type RefInfo struct {
+	Ref string `json:"ref"`
+}
+
+// IssueInfo is the issue block of issues and issue_comment events.
+type IssueInfo struct {
+	Number    int         `json:"number"`
+	Labels    []LabelInfo `json:"labels"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// LabelInfo is a label, e.g. the label block of labeled and unlabeled
+// events.
+type LabelInfo struct {
+	Name string `json:"name"`
+}
+
+// DiscussionInfo is the discussion block of discussion and
+// discussion_comment events.
+type DiscussionInfo struct {
+	Number int `json:"number"`
+}
+
+// DeploymentInfo is the deployment block of deployment and
+// deployment_status events.
+type DeploymentInfo struct {
+	Environment string `json:"environment"`
+}
+
+// DeploymentStatusInfo is the deployment_status block of deployment_status
+// events.
+type DeploymentStatusInfo struct {
+	State       string `json:"state"`
+	Environment string `json:"environment"`
+}
+
+// WorkflowRunInfo is the workflow_run block of workflow_run events.
+type WorkflowRunInfo struct {
+	HTMLURL    string `json:"html_url"`
+	Conclusion string `json:"conclusion"`
+}
+
+// WorkflowJobInfo is the workflow_job block of workflow_job events.
+type WorkflowJobInfo struct {
+	RunID  int64  `json:"run_id"`
+	Status string `json:"status"`
+}
+
+// CommitInfo is a commit of push events, with the paths of the files it
+// changed.
+type CommitInfo struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// OrganizationInfo is the organization block of events in organizations,
+// including organization-level events that carry no repository.
+type OrganizationInfo struct {
+	Login string `json:"login"`
+}
+
+// InstallationInfo is the installation block of deliveries to GitHub Apps.
+type InstallationInfo struct {
+	ID int64 `json:"id"`
+}
+
+// SenderInfo is the sender block of the account that triggered the event.
+type SenderInfo struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// OwnerInfo is the owner block of a repository.
+type OwnerInfo struct {
+	Login string `json:"login"`
+}
+
+// RepositoryInfo is the repository block most events carry.
+type RepositoryInfo struct {
+	Name       string    `json:"name"`
+	FullName   string    `json:"full_name"`
+	Owner      OwnerInfo `json:"owner"`
+	Private    *bool     `json:"private"`
+	Visibility string    `json:"visibility"`
+	// PushedAt is when the repository was last pushed to, which push events
+	// give in Unix seconds and other events as an RFC 3339 string.
+	PushedAt *github.Timestamp `json:"pushed_at"`
+}
+
+// ParsePayload extracts the PayloadInfo from a webhook payload. If some of
+// the fields have unexpected types, the error is returned along with the
+// fields that could be parsed.
+func ParsePayload(payload []byte) (PayloadInfo, error) {
+	var info PayloadInfo
+	err := json.Unmarshal(payload, &info)
+	return info, err
+}
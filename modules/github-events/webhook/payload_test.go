@@ -0,0 +1,69 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v60/github"
+)
+
+func TestParsePayload(t *testing.T) {
+	payload := []byte(`{
+		"action": "synchronize",
+		"number": 42,
+		"pull_request": {
+			"number": 42,
+			"updated_at": "2024-05-01T12:00:00Z",
+			"head": {"sha": "abc123"}
+		},
+		"repository": {
+			"name": "repo",
+			"full_name": "org/repo",
+			"owner": {"login": "org"},
+			"private": true,
+			"visibility": "private",
+			"pushed_at": "2024-05-01T11:00:00Z"
+		}
+	}`)
+
+	got, err := ParsePayload(payload)
+	if err != nil {
+		t.Fatalf("ParsePayload() = %v", err)
+	}
+
+	private := true
+	want := PayloadInfo{
+		Action: "synchronize",
+		PullRequest: PullRequestInfo{
+			Number:    42,
+			UpdatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+		Repository: RepositoryInfo{
+			Name:       "repo",
+			FullName:   "org/repo",
+			Private:    &private,
+			Visibility: "private",
+			PushedAt:   &github.Timestamp{Time: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)},
+		},
+	}
+	want.Repository.Owner.Login = "org"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParsePayload() (-want +got): %s", diff)
+	}
+}
+
+func TestParsePayloadPartial(t *testing.T) {
+	got, err := ParsePayload([]byte(`{"repository": {"name": 1, "visibility": "public"}}`))
+	if err == nil {
+		t.Fatal("ParsePayload() = nil, wanted error")
+	}
+	if got.Repository.Visibility != "public" {
+		t.Errorf("Repository.Visibility = %q, wanted %q", got.Repository.Visibility, "public")
+	}
+}
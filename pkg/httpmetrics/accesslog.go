@@ -0,0 +1,56 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultAccessLogLevel logs server errors at Info, and everything else at Debug.
+func DefaultAccessLogLevel(status int) slog.Level {
+	if status >= 500 {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// AccessLog wraps handler to log a structured line for every request once it
+// completes, with its method, path, status, duration, bytes written and trace
+// ID. The level for each line is chosen by level, or DefaultAccessLogLevel if
+// it is nil.
+//
+// When wrapped around Handler, the response writer wrapper used to capture the
+// status is shared with the metrics rather than wrapping twice:
+//
+//	httpmetrics.AccessLog(nil, httpmetrics.Handler("trampoline", h))
+func AccessLog(level func(status int) slog.Level, handler http.Handler) http.Handler {
+	if level == nil {
+		level = DefaultAccessLogLevel
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		d := wrapDelegator(w)
+		handler.ServeHTTP(d, r)
+
+		ctx := r.Context()
+		args := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", d.Status,
+			"duration", time.Since(start),
+			"bytes", d.Written,
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			args = append(args, "trace_id", sc.TraceID().String())
+		}
+		clog.FromContext(ctx).Log(ctx, level(d.Status), "request completed", args...)
+	})
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// recordingHandler is a slog.Handler that keeps every record.
+type recordingHandler struct {
+	m       sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler            { return h }
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func attrs(r slog.Record) map[string]slog.Value {
+	m := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		m[a.Key] = a.Value
+		return true
+	})
+	return m
+}
+
+func TestAccessLog(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		status    int
+		body      string
+		wantLevel slog.Level
+	}{
+		{"ok", http.StatusOK, "hello", slog.LevelDebug},
+		{"client error", http.StatusNotFound, "", slog.LevelDebug},
+		{"server error", http.StatusInternalServerError, "oops", slog.LevelInfo},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &recordingHandler{}
+			h := AccessLog(nil, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+			req = req.WithContext(clog.WithLogger(req.Context(), clog.New(rh)))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(rh.records) != 1 {
+				t.Fatalf("got %d log records, wanted 1", len(rh.records))
+			}
+			r := rh.records[0]
+			if r.Level != tt.wantLevel {
+				t.Errorf("level = %v, wanted %v", r.Level, tt.wantLevel)
+			}
+			a := attrs(r)
+			if got := a["method"].String(); got != http.MethodPost {
+				t.Errorf("method = %q, wanted %q", got, http.MethodPost)
+			}
+			if got := a["path"].String(); got != "/webhook" {
+				t.Errorf("path = %q, wanted /webhook", got)
+			}
+			if got := a["status"].Int64(); got != int64(tt.status) {
+				t.Errorf("status = %d, wanted %d", got, tt.status)
+			}
+			if got := a["bytes"].Int64(); got != int64(len(tt.body)) {
+				t.Errorf("bytes = %d, wanted %d", got, len(tt.body))
+			}
+			if _, ok := a["duration"]; !ok {
+				t.Error("missing duration")
+			}
+		})
+	}
+}
+
+func TestAccessLogSharesDelegator(t *testing.T) {
+	rh := &recordingHandler{}
+	var inner http.ResponseWriter
+	h := AccessLog(func(int) slog.Level { return slog.LevelWarn }, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		inner = w
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	d := wrapDelegator(httptest.NewRecorder())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(clog.WithLogger(req.Context(), clog.New(rh)))
+	h.ServeHTTP(d, req)
+
+	if inner != d {
+		t.Error("AccessLog wrapped an existing delegator")
+	}
+	if d.Status != http.StatusTeapot {
+		t.Errorf("Status = %d, wanted %d", d.Status, http.StatusTeapot)
+	}
+	if len(rh.records) != 1 || rh.records[0].Level != slog.LevelWarn {
+		t.Errorf("records = %v, wanted one at %v", rh.records, slog.LevelWarn)
+	}
+}
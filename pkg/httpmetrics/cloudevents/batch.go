@@ -0,0 +1,106 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/uuid"
+)
+
+// BatchClient is a cloudevents.Client that can also deliver several events in
+// a single round-trip using the batched content mode.
+type BatchClient struct {
+	cloudevents.Client
+
+	protocol *cehttp.Protocol
+}
+
+// NewBatchClientHTTP is like NewClientHTTP, but returns a client that also
+// supports SendBatch. Like NewClientHTTP, retries of Send are counted by
+// cloudevents_send_retries_total.
+func NewBatchClientHTTP(name string, opts ...cehttp.Option) (*BatchClient, error) {
+	p, err := cehttp.New(clientOptions(name, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cloudevents.NewClient(p, cloudevents.WithTimeNow(), cloudevents.WithUUIDs())
+	if err != nil {
+		return nil, err
+	}
+	return &BatchClient{Client: retryMetricsClient{Client: c}, protocol: p}, nil
+}
+
+// SendBatch delivers the events in a single application/cloudevents-batch+json
+// request. If the target rejects the batch content type, the events are sent
+// individually instead.
+//
+// The returned results correspond 1:1 to the given events, so callers can
+// retry only the failures.
+func (c *BatchClient) SendBatch(ctx context.Context, events []cloudevents.Event) []cloudevents.Result {
+	results := make([]cloudevents.Result, len(events))
+	if len(events) == 0 {
+		return results
+	}
+
+	target := c.protocol.Target
+	if t := cloudevents.TargetFromContext(ctx); t != nil {
+		target = t
+	}
+	if target == nil {
+		return fill(results, cloudevents.NewReceipt(false, "no target specified for batch"))
+	}
+
+	// Apply the same defaults that Send would.
+	batch := make([]cloudevents.Event, 0, len(events))
+	for _, e := range events {
+		e = e.Clone()
+		if e.ID() == "" {
+			e.SetID(uuid.New().String())
+		}
+		if e.Time().IsZero() {
+			e.SetTime(time.Now())
+		}
+		batch = append(batch, e)
+	}
+
+	req, err := cloudevents.NewHTTPRequestFromEvents(ctx, target.String(), batch)
+	if err != nil {
+		return fill(results, cloudevents.NewReceipt(false, "creating batch request: %w", err))
+	}
+	resp, err := c.protocol.Client.Do(req)
+	if err != nil {
+		return fill(results, cloudevents.NewReceipt(false, "%w", err))
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return fill(results, cloudevents.NewHTTPResult(resp.StatusCode, "%w", cloudevents.ResultACK))
+
+	case resp.StatusCode == http.StatusUnsupportedMediaType:
+		// The target doesn't support batches, so fall back to sending each
+		// event on its own.
+		for i, e := range batch {
+			results[i] = c.Send(ctx, e)
+		}
+		return results
+
+	default:
+		return fill(results, cloudevents.NewHTTPResult(resp.StatusCode, "%w", cloudevents.ResultNACK))
+	}
+}
+
+func fill(results []cloudevents.Result, r cloudevents.Result) []cloudevents.Result {
+	for i := range results {
+		results[i] = r
+	}
+	return results
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+)
+
+func testEvents(n int) []cloudevents.Event {
+	events := make([]cloudevents.Event, 0, n)
+	for i := range n {
+		event := cloudevents.NewEvent()
+		event.SetID(fmt.Sprintf("id-%d", i))
+		event.SetType("dev.chainguard.test")
+		event.SetSource("test")
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestSendBatch(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cloudevents.IsHTTPBatch(r.Header) {
+			t.Errorf("Content-Type = %q, wanted batch", r.Header.Get("Content-Type"))
+		}
+		events, err := cloudevents.NewEventsFromHTTPRequest(r)
+		if err != nil {
+			t.Errorf("NewEventsFromHTTPRequest() = %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, e := range events {
+			got = append(got, e.ID())
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c, err := NewBatchClientHTTP("test", cehttp.WithTarget(srv.URL))
+	if err != nil {
+		t.Fatalf("NewBatchClientHTTP() = %v", err)
+	}
+
+	results := c.SendBatch(context.Background(), testEvents(3))
+	if len(results) != 3 {
+		t.Fatalf("got %d results, wanted 3", len(results))
+	}
+	for i, res := range results {
+		if !cloudevents.IsACK(res) {
+			t.Errorf("results[%d] = %v, wanted ACK", i, res)
+		}
+	}
+	if diff := cmp.Diff([]string{"id-0", "id-1", "id-2"}, got); diff != "" {
+		t.Errorf("received events (-want +got): %s", diff)
+	}
+}
+
+func TestSendBatchFallback(t *testing.T) {
+	var m sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cloudevents.IsHTTPBatch(r.Header) {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		event, err := cloudevents.NewEventFromHTTPRequest(r)
+		if err != nil {
+			t.Errorf("NewEventFromHTTPRequest() = %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Reject one of the events, to check per-event results.
+		if event.ID() == "id-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.Lock()
+		defer m.Unlock()
+		got = append(got, event.ID())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c, err := NewBatchClientHTTP("test", cehttp.WithTarget(srv.URL))
+	if err != nil {
+		t.Fatalf("NewBatchClientHTTP() = %v", err)
+	}
+
+	results := c.SendBatch(context.Background(), testEvents(3))
+	if len(results) != 3 {
+		t.Fatalf("got %d results, wanted 3", len(results))
+	}
+	for i, wantACK := range []bool{true, false, true} {
+		if gotACK := cloudevents.IsACK(results[i]); gotACK != wantACK {
+			t.Errorf("results[%d] = %v, wanted ACK: %t", i, results[i], wantACK)
+		}
+	}
+	if diff := cmp.Diff([]string{"id-0", "id-2"}, got); diff != "" {
+		t.Errorf("received events (-want +got): %s", diff)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrCircuitOpen is returned for requests short-circuited by an open circuit
+// breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// The states of a circuit, as reported by the mce_circuit_breaker_state
+// metric.
+const (
+	circuitClosed = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+var mCircuitState = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "mce_circuit_breaker_state",
+		Help: "The state of the circuit breaker of each target host: 0 closed, 1 half-open, 2 open",
+	},
+	[]string{"host"},
+)
+
+// BreakerOption configures WithCircuitBreaker.
+type BreakerOption func(*breaker)
+
+// WithFailureThreshold sets the number of consecutive failures that open the
+// circuit, which defaults to 5.
+func WithFailureThreshold(n int) BreakerOption {
+	return func(b *breaker) { b.threshold = n }
+}
+
+// WithFailureWindow sets the time within which the consecutive failures must
+// happen to open the circuit, which defaults to a minute. A success, or a
+// failure after the window has passed, starts counting again.
+func WithFailureWindow(d time.Duration) BreakerOption {
+	return func(b *breaker) { b.window = d }
+}
+
+// WithOpenTimeout sets how long an open circuit short-circuits requests
+// before letting a single probe request through, which defaults to 30s.
+func WithOpenTimeout(d time.Duration) BreakerOption {
+	return func(b *breaker) { b.timeout = d }
+}
+
+// WithCircuitBreaker fails sends fast with ErrCircuitOpen while the target is
+// down, rather than waiting out the retries of every send. Each target host
+// has its own circuit, which opens after consecutive failures, i.e. transport
+// errors and 5xx responses. Once open, it lets a probe request through after
+// a timeout, and closes again if the probe succeeds.
+//
+// Like WithCompression, this decorates the transport of the client
+// configured at the time the option is applied.
+func WithCircuitBreaker(opts ...BreakerOption) cehttp.Option {
+	return cehttp.WithRoundTripperDecorator(func(rt http.RoundTripper) http.RoundTripper {
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		return newBreaker(rt, opts...)
+	})
+}
+
+type breaker struct {
+	inner     http.RoundTripper
+	threshold int
+	window    time.Duration
+	timeout   time.Duration
+
+	// now is the clock, which tests replace.
+	now func() time.Time
+
+	m        sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the state of the circuit of a host.
+type circuit struct {
+	state    int
+	failures int
+	// first is when the current run of failures started.
+	first time.Time
+	// openedAt is when the circuit last opened.
+	openedAt time.Time
+	// probing is set while the probe request of a half-open circuit is in
+	// flight.
+	probing bool
+}
+
+func newBreaker(inner http.RoundTripper, opts ...BreakerOption) *breaker {
+	b := &breaker{
+		inner:     inner,
+		threshold: 5,
+		window:    time.Minute,
+		timeout:   30 * time.Second,
+		now:       time.Now,
+		circuits:  map[string]*circuit{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *breaker) RoundTrip(r *http.Request) (*http.Response, error) {
+	host := r.URL.Host
+	if !b.allow(host) {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, ErrCircuitOpen
+	}
+	resp, err := b.inner.RoundTrip(r)
+	b.record(host, err == nil && resp.StatusCode < 500)
+	return resp, err
+}
+
+// allow reports whether a request to host may be sent.
+func (b *breaker) allow(host string) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	c := b.circuit(host)
+	switch c.state {
+	case circuitOpen:
+		if b.now().Sub(c.openedAt) < b.timeout {
+			return false
+		}
+		b.setState(host, c, circuitHalfOpen)
+		fallthrough
+	case circuitHalfOpen:
+		if c.probing {
+			return false
+		}
+		c.probing = true
+	}
+	return true
+}
+
+// record updates the circuit of host with the outcome of a request.
+func (b *breaker) record(host string, ok bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	c := b.circuit(host)
+	now := b.now()
+	switch {
+	case ok:
+		c.failures = 0
+		c.probing = false
+		b.setState(host, c, circuitClosed)
+	case c.state == circuitHalfOpen:
+		c.probing = false
+		c.openedAt = now
+		b.setState(host, c, circuitOpen)
+	default:
+		if c.failures == 0 || now.Sub(c.first) > b.window {
+			c.failures, c.first = 0, now
+		}
+		c.failures++
+		if c.failures >= b.threshold {
+			c.openedAt = now
+			b.setState(host, c, circuitOpen)
+		}
+	}
+}
+
+func (b *breaker) circuit(host string) *circuit {
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{}
+		b.circuits[host] = c
+	}
+	return c
+}
+
+func (b *breaker) setState(host string, c *circuit, state int) {
+	c.state = state
+	mCircuitState.WithLabelValues(host).Set(float64(state))
+}
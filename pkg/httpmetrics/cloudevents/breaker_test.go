@@ -0,0 +1,145 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// statusTransport responds with its status without making a request.
+type statusTransport struct {
+	status   int
+	requests int
+}
+
+func (t *statusTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests++
+	return &http.Response{StatusCode: t.status, Body: http.NoBody, Request: r}, nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	inner := &statusTransport{status: http.StatusServiceUnavailable}
+	b := newBreaker(inner, WithFailureThreshold(3), WithFailureWindow(time.Minute), WithOpenTimeout(10*time.Second))
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	send := func() error {
+		req, err := http.NewRequest(http.MethodPost, "http://ingress.example.com/", nil)
+		if err != nil {
+			t.Fatalf("NewRequest() = %v", err)
+		}
+		_, err = b.RoundTrip(req)
+		return err
+	}
+	state := func() float64 {
+		return testutil.ToFloat64(mCircuitState.WithLabelValues("ingress.example.com"))
+	}
+
+	// Failures below the threshold are passed through.
+	for range 3 {
+		if err := send(); err != nil {
+			t.Fatalf("RoundTrip() = %v", err)
+		}
+	}
+	if got := state(); got != circuitOpen {
+		t.Errorf("state = %v, wanted open", got)
+	}
+
+	// While open, requests fail fast.
+	if err := send(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("RoundTrip() = %v, wanted %v", err, ErrCircuitOpen)
+	}
+	if inner.requests != 3 {
+		t.Errorf("made %d requests, wanted 3", inner.requests)
+	}
+
+	// After the timeout a failed probe opens the circuit again.
+	now = now.Add(10 * time.Second)
+	if err := send(); err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	if err := send(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("RoundTrip() = %v, wanted %v", err, ErrCircuitOpen)
+	}
+
+	// A successful probe closes it.
+	now = now.Add(10 * time.Second)
+	inner.status = http.StatusAccepted
+	if err := send(); err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	if got := state(); got != circuitClosed {
+		t.Errorf("state = %v, wanted closed", got)
+	}
+	if err := send(); err != nil {
+		t.Errorf("RoundTrip() = %v", err)
+	}
+	if inner.requests != 6 {
+		t.Errorf("made %d requests, wanted 6", inner.requests)
+	}
+}
+
+func TestCircuitBreakerHalfOpenSingleProbe(t *testing.T) {
+	b := newBreaker(&statusTransport{status: http.StatusBadGateway}, WithFailureThreshold(1), WithOpenTimeout(time.Second))
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.record("ingress.example.com", false)
+	now = now.Add(time.Second)
+	if !b.allow("ingress.example.com") {
+		t.Fatal("allow() = false, wanted the probe to be allowed")
+	}
+	if b.allow("ingress.example.com") {
+		t.Error("allow() = true while the probe is in flight")
+	}
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	b := newBreaker(&statusTransport{}, WithFailureThreshold(2), WithFailureWindow(time.Minute))
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.record("ingress.example.com", false)
+	now = now.Add(2 * time.Minute)
+	b.record("ingress.example.com", false)
+	if !b.allow("ingress.example.com") {
+		t.Error("allow() = false, wanted failures outside the window not to open the circuit")
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c, err := NewClientHTTP("test", cloudevents.WithTarget(srv.URL), WithCircuitBreaker(WithFailureThreshold(2)))
+	if err != nil {
+		t.Fatalf("NewClientHTTP() = %v", err)
+	}
+	for range 2 {
+		if res := c.Send(context.Background(), testEvents(1)[0]); !cloudevents.IsNACK(res) {
+			t.Fatalf("Send() = %v, wanted NACK", res)
+		}
+	}
+	if res := c.Send(context.Background(), testEvents(1)[0]); !errors.Is(res, ErrCircuitOpen) {
+		t.Errorf("Send() = %v, wanted %v", res, ErrCircuitOpen)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("made %d requests, wanted 2", got)
+	}
+}
@@ -14,17 +14,32 @@ import (
 	metrics "github.com/chainguard-dev/terraform-infra-common/pkg/httpmetrics"
 )
 
+// NewClientHTTP returns a CloudEvents client that records metrics under name.
+// Outbound requests identify themselves with a User-Agent of
+// "<name>/<build ID>", which WithUserAgent overrides. Like any CloudEvents
+// client, it rejects structurally invalid events, e.g. with an empty type or
+// source, without sending them. Retries of sends, per the retry parameters
+// of the context, are counted by cloudevents_send_retries_total.
 func NewClientHTTP(name string, opts ...cehttp.Option) (cloudevents.Client, error) {
+	c, err := cloudevents.NewClientHTTP(clientOptions(name, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return retryMetricsClient{Client: c}, nil
+}
+
+func clientOptions(name string, opts []cehttp.Option) []cehttp.Option {
 	// If we don't specify a client, NewClientHTTP will use http.DefaultClient
 	// and may clobber its Transport. To avoid so, we pass a client with the
 	// the metrics transport instead.
 	metricsClient := http.Client{
 		Transport: metrics.Transport,
 	}
-	copt := append([]cehttp.Option{
+	return append([]cehttp.Option{
 		cehttp.WithClient(metricsClient),
 		cloudevents.WithMiddleware(func(next http.Handler) http.Handler {
 			return metrics.Handler(name, next)
-		})}, opts...)
-	return cloudevents.NewClientHTTP(copt...)
+		}),
+		WithUserAgent(name + "/" + buildID()),
+	}, opts...)
 }
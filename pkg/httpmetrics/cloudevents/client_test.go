@@ -0,0 +1,57 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// The CloudEvents SDK validates events before sending them, so structurally
+// invalid events are rejected without a request being made.
+func TestInvalidEventsRejectedLocally(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c, err := NewBatchClientHTTP("test", cloudevents.WithTarget(srv.URL))
+	if err != nil {
+		t.Fatalf("NewBatchClientHTTP() = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		mutate func(e *cloudevents.Event)
+	}{
+		{"empty source", func(e *cloudevents.Event) { e.SetSource("") }},
+		{"empty type", func(e *cloudevents.Event) { e.SetType("") }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			event := testEvents(1)[0]
+			tt.mutate(&event)
+
+			if res := c.Send(context.Background(), event); cloudevents.IsACK(res) || cloudevents.IsNACK(res) {
+				t.Errorf("Send() = %v, wanted a local validation error", res)
+			}
+			for _, res := range c.SendBatch(context.Background(), append(testEvents(1), event)) {
+				if cloudevents.IsACK(res) {
+					t.Errorf("SendBatch() = %v, wanted a local validation error", res)
+				}
+			}
+			if got := requests.Load(); got != 0 {
+				t.Errorf("made %d requests, wanted none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// WithCompression gzip-compresses outbound request bodies that are larger than
+// threshold bytes, and sets the Content-Encoding header accordingly. Bodies at
+// or below the threshold are sent as-is.
+//
+// This decorates the transport of the client configured at the time the
+// option is applied, so it must come after any option that replaces the
+// client (e.g. WithTarget).
+func WithCompression(threshold int) cehttp.Option {
+	return cehttp.WithRoundTripperDecorator(func(rt http.RoundTripper) http.RoundTripper {
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		return &gzipTransport{inner: rt, threshold: threshold}
+	})
+}
+
+type gzipTransport struct {
+	inner     http.RoundTripper
+	threshold int
+}
+
+func (t *gzipTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil || r.Header.Get("Content-Encoding") != "" {
+		return t.inner.RoundTrip(r)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	if len(body) <= t.threshold {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return t.inner.RoundTrip(r)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, fmt.Errorf("compressing request body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing request body: %w", err)
+	}
+	compressed := buf.Bytes()
+
+	// RoundTrippers must not modify the request, so send a copy.
+	cr := r.Clone(r.Context())
+	cr.Body = io.NopCloser(bytes.NewReader(compressed))
+	cr.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	cr.ContentLength = int64(len(compressed))
+	cr.Header.Set("Content-Encoding", "gzip")
+	return t.inner.RoundTrip(cr)
+}
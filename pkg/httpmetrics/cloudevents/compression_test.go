@@ -0,0 +1,87 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithCompression(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		size     int
+		wantGzip bool
+	}{{
+		name:     "below threshold",
+		size:     10,
+		wantGzip: false,
+	}, {
+		name:     "above threshold",
+		size:     10000,
+		wantGzip: true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotEncoding string
+			var got map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotEncoding = r.Header.Get("Content-Encoding")
+				body := io.Reader(r.Body)
+				if gotEncoding == "gzip" {
+					zr, err := gzip.NewReader(r.Body)
+					if err != nil {
+						t.Errorf("gzip.NewReader() = %v", err)
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					body = zr
+				}
+				if err := json.NewDecoder(body).Decode(&got); err != nil {
+					t.Errorf("Decode() = %v", err)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			c, err := NewClientHTTP("test", cehttp.WithTarget(srv.URL), WithCompression(1024))
+			if err != nil {
+				t.Fatalf("NewClientHTTP() = %v", err)
+			}
+
+			want := map[string]string{"payload": strings.Repeat("a", tt.size)}
+			event := cloudevents.NewEvent()
+			event.SetID("id")
+			event.SetType("dev.chainguard.test")
+			event.SetSource("test")
+			if err := event.SetData(cloudevents.ApplicationJSON, want); err != nil {
+				t.Fatalf("SetData() = %v", err)
+			}
+
+			if res := c.Send(context.Background(), event); !cloudevents.IsACK(res) {
+				t.Fatalf("Send() = %v", res)
+			}
+
+			if gotGzip := gotEncoding == "gzip"; gotGzip != tt.wantGzip {
+				t.Errorf("Content-Encoding = %q, wanted gzip: %t", gotEncoding, tt.wantGzip)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("received payload (-want +got): %s", diff)
+			}
+		})
+	}
+}
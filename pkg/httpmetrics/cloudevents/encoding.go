@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// WithEncoding sends events in the given content mode:
+// cloudevents.EncodingBinary, with the attributes in ce-* headers and the
+// data as the body, which is the default, or cloudevents.EncodingStructured,
+// with the whole event as an application/cloudevents+json body, e.g. for
+// proxies that mishandle the headers of binary mode. Other encodings are
+// rejected when the client is created. Batches are sent as is.
+//
+// Like WithCompression, this decorates the transport of the client
+// configured at the time the option is applied. Combined with
+// WithCompression, it must come after it, so that events are re-encoded
+// before they are compressed.
+func WithEncoding(encoding cloudevents.Encoding) cehttp.Option {
+	return func(p *cehttp.Protocol) error {
+		switch encoding {
+		case cloudevents.EncodingBinary, cloudevents.EncodingStructured:
+		default:
+			return fmt.Errorf("unsupported CloudEvents encoding %v", encoding)
+		}
+		return cehttp.WithRoundTripperDecorator(func(rt http.RoundTripper) http.RoundTripper {
+			if rt == nil {
+				rt = http.DefaultTransport
+			}
+			return &encodingTransport{inner: rt, encoding: encoding}
+		})(p)
+	}
+}
+
+type encodingTransport struct {
+	inner    http.RoundTripper
+	encoding binding.Encoding
+}
+
+func (t *encodingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil || r.Header.Get("Content-Encoding") != "" {
+		return t.inner.RoundTrip(r)
+	}
+	msg := cehttp.NewMessageFromHttpRequest(r)
+	if enc := msg.ReadEncoding(); enc == t.encoding || (enc != binding.EncodingBinary && enc != binding.EncodingStructured) {
+		return t.inner.RoundTrip(r)
+	}
+
+	ctx := r.Context()
+	event, err := binding.ToEvent(ctx, msg)
+	msg.Finish(nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading event: %w", err)
+	}
+	if t.encoding == binding.EncodingStructured {
+		ctx = binding.WithForceStructured(ctx)
+	} else {
+		ctx = binding.WithForceBinary(ctx)
+	}
+
+	// RoundTrippers must not modify the request, so send a copy without the
+	// attributes of the previous encoding.
+	cr := r.Clone(ctx)
+	for k := range cr.Header {
+		if strings.HasPrefix(strings.ToLower(k), "ce-") {
+			cr.Header.Del(k)
+		}
+	}
+	cr.Header.Del("Content-Type")
+	if err := cehttp.WriteRequest(ctx, binding.ToMessage(event), cr); err != nil {
+		return nil, fmt.Errorf("encoding event: %w", err)
+	}
+	return t.inner.RoundTrip(cr)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		opts            []cehttp.Option
+		wantContentType string
+		wantCEID        string
+		wantBody        map[string]any
+	}{{
+		name:            "default",
+		wantContentType: "application/json",
+		wantCEID:        "id-0",
+		wantBody:        map[string]any{"hello": "world"},
+	}, {
+		name:            "binary",
+		opts:            []cehttp.Option{WithEncoding(cloudevents.EncodingBinary)},
+		wantContentType: "application/json",
+		wantCEID:        "id-0",
+		wantBody:        map[string]any{"hello": "world"},
+	}, {
+		name:            "structured",
+		opts:            []cehttp.Option{WithEncoding(cloudevents.EncodingStructured)},
+		wantContentType: "application/cloudevents+json",
+		wantBody: map[string]any{
+			"specversion":     "1.0",
+			"id":              "id-0",
+			"type":            "dev.chainguard.test",
+			"source":          "test",
+			"datacontenttype": "application/json",
+			"data":            map[string]any{"hello": "world"},
+		},
+	}, {
+		name:            "structured and compressed",
+		opts:            []cehttp.Option{WithCompression(1 << 20), WithEncoding(cloudevents.EncodingStructured)},
+		wantContentType: "application/cloudevents+json",
+		wantBody: map[string]any{
+			"specversion":     "1.0",
+			"id":              "id-0",
+			"type":            "dev.chainguard.test",
+			"source":          "test",
+			"datacontenttype": "application/json",
+			"data":            map[string]any{"hello": "world"},
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			var contentType, ceID string
+			var body map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType, ceID = r.Header.Get("Content-Type"), r.Header.Get("Ce-Id")
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("Decode() = %v", err)
+				}
+				// The client sets the time of events.
+				delete(body, "time")
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			c, err := NewClientHTTP("test", append([]cehttp.Option{cloudevents.WithTarget(srv.URL)}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("NewClientHTTP() = %v", err)
+			}
+			event := testEvents(1)[0]
+			if err := event.SetData(cloudevents.ApplicationJSON, map[string]string{"hello": "world"}); err != nil {
+				t.Fatalf("SetData() = %v", err)
+			}
+			if res := c.Send(context.Background(), event); !cloudevents.IsACK(res) {
+				t.Fatalf("Send() = %v", res)
+			}
+
+			if contentType != tt.wantContentType {
+				t.Errorf("Content-Type = %q, wanted %q", contentType, tt.wantContentType)
+			}
+			if ceID != tt.wantCEID {
+				t.Errorf("Ce-Id = %q, wanted %q", ceID, tt.wantCEID)
+			}
+			if diff := cmp.Diff(tt.wantBody, body); diff != "" {
+				t.Errorf("body (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestWithEncodingInvalid(t *testing.T) {
+	if _, err := NewClientHTTP("test", WithEncoding(binding.EncodingBatch)); err == nil {
+		t.Error("NewClientHTTP() = nil, wanted error for batch encoding")
+	}
+}
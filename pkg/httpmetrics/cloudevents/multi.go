@@ -0,0 +1,103 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// MultiClient is a cloudevents.Client that fans each event out to several
+// underlying clients, e.g. one per event bus.
+type MultiClient struct {
+	clients []cloudevents.Client
+	quorum  int
+}
+
+var _ cloudevents.Client = (*MultiClient)(nil)
+
+// MultiOption configures a MultiClient.
+type MultiOption func(*MultiClient)
+
+// WithQuorum makes Send succeed once n of the clients have acknowledged the
+// event, rather than requiring all of them to.
+func WithQuorum(n int) MultiOption {
+	return func(c *MultiClient) {
+		c.quorum = n
+	}
+}
+
+// NewMultiClient returns a MultiClient delivering to each of clients.
+func NewMultiClient(clients []cloudevents.Client, opts ...MultiOption) *MultiClient {
+	c := &MultiClient{clients: clients}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewMultiClientHTTP is like NewClientHTTP, but returns a MultiClient that
+// delivers each event to every one of targets.
+func NewMultiClientHTTP(ctx context.Context, name string, targets []string, opts ...MultiOption) (*MultiClient, error) {
+	clients := make([]cloudevents.Client, 0, len(targets))
+	for _, target := range targets {
+		c, err := NewClientHTTP(name, WithTarget(ctx, target)...)
+		if err != nil {
+			return nil, fmt.Errorf("creating client for %s: %w", target, err)
+		}
+		clients = append(clients, c)
+	}
+	return NewMultiClient(clients, opts...), nil
+}
+
+// Send delivers the event to all clients concurrently. It returns ACK if
+// every client (or the configured quorum) acknowledged the event, and
+// otherwise the joined results of the failed deliveries.
+func (c *MultiClient) Send(ctx context.Context, event cloudevents.Event) cloudevents.Result {
+	results := make([]cloudevents.Result, len(c.clients))
+	var wg sync.WaitGroup
+	for i, client := range c.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = client.Send(ctx, event)
+		}()
+	}
+	wg.Wait()
+
+	required := len(c.clients)
+	if c.quorum > 0 && c.quorum < required {
+		required = c.quorum
+	}
+
+	acked := 0
+	var errs []error
+	for _, res := range results {
+		if cloudevents.IsACK(res) {
+			acked++
+		} else {
+			errs = append(errs, res)
+		}
+	}
+	if acked >= required {
+		return cloudevents.ResultACK
+	}
+	return fmt.Errorf("%d of %d deliveries acknowledged, wanted %d: %w", acked, len(c.clients), required, errors.Join(errs...))
+}
+
+// Request is not supported, as there is no single response to return.
+func (c *MultiClient) Request(context.Context, cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
+	return nil, errors.New("request is not supported by MultiClient")
+}
+
+// StartReceiver is not supported, MultiClient is for sending only.
+func (c *MultiClient) StartReceiver(context.Context, interface{}) error {
+	return errors.New("receiving is not supported by MultiClient")
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// newTarget returns a server responding to every event with status, and a
+// count of the events it received.
+func newTarget(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		count.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &count
+}
+
+func TestMultiClient(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		opts    []MultiOption
+		wantACK bool
+	}{
+		{"all required", nil, false},
+		{"quorum of one", []MultiOption{WithQuorum(1)}, true},
+		{"quorum of two", []MultiOption{WithQuorum(2)}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, okCount := newTarget(t, http.StatusAccepted)
+			bad, badCount := newTarget(t, http.StatusInternalServerError)
+
+			ctx := context.Background()
+			c, err := NewMultiClientHTTP(ctx, "test", []string{ok.URL, bad.URL}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewMultiClientHTTP() = %v", err)
+			}
+
+			res := c.Send(ctx, testEvents(1)[0])
+			if got := cloudevents.IsACK(res); got != tt.wantACK {
+				t.Errorf("IsACK(%v) = %t, wanted %t", res, got, tt.wantACK)
+			}
+			if !tt.wantACK && !cloudevents.IsNACK(res) {
+				t.Errorf("IsNACK(%v) = false, wanted true", res)
+			}
+			if okCount.Load() != 1 || badCount.Load() != 1 {
+				t.Errorf("targets received %d and %d events, wanted 1 each", okCount.Load(), badCount.Load())
+			}
+		})
+	}
+}
+
+func TestMultiClientAllSucceed(t *testing.T) {
+	a, _ := newTarget(t, http.StatusOK)
+	b, _ := newTarget(t, http.StatusAccepted)
+
+	ctx := context.Background()
+	c, err := NewMultiClientHTTP(ctx, "test", []string{a.URL, b.URL})
+	if err != nil {
+		t.Fatalf("NewMultiClientHTTP() = %v", err)
+	}
+	if res := c.Send(ctx, testEvents(1)[0]); !cloudevents.IsACK(res) {
+		t.Errorf("Send() = %v, wanted ACK", res)
+	}
+}
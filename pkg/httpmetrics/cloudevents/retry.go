@@ -0,0 +1,69 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"errors"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var mSendRetries = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cloudevents_send_retries_total",
+		Help: "The number of retried attempts to send events, by the outcome of the send: success or failure",
+	},
+	[]string{"outcome"},
+)
+
+// WithRetryPredicate decides which failed sends are retried, when the
+// context carries retry parameters, e.g. from
+// cloudevents.ContextWithRetriesExponentialBackoff. The predicate is passed
+// the NACK result of each attempt, an *cehttp.Result carrying the status
+// code, and retries if it returns true. Requests that fail without a
+// response are always retried.
+//
+// Without this option the CloudEvents SDK's default applies, which retries
+// 404, 413, 425, 429, 502, 503 and 504.
+func WithRetryPredicate(retryable func(result cloudevents.Result) bool) cehttp.Option {
+	return cehttp.WithIsRetriableFunc(func(statusCode int) bool {
+		// This matches the result the SDK reports for the attempt.
+		return retryable(cehttp.NewResult(statusCode, "%w", cloudevents.ResultNACK))
+	})
+}
+
+// retryMetricsClient counts the retries the SDK reports for each send.
+type retryMetricsClient struct {
+	cloudevents.Client
+}
+
+func (c retryMetricsClient) Send(ctx context.Context, event cloudevents.Event) cloudevents.Result {
+	res := c.Client.Send(ctx, event)
+	observeRetries(res)
+	return res
+}
+
+func (c retryMetricsClient) Request(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
+	resp, res := c.Client.Request(ctx, event)
+	observeRetries(res)
+	return resp, res
+}
+
+func observeRetries(res cloudevents.Result) {
+	var rr *cehttp.RetriesResult
+	if !errors.As(res, &rr) || rr.Retries == 0 {
+		return
+	}
+	outcome := "failure"
+	if cloudevents.IsACK(res) {
+		outcome = "success"
+	}
+	mSendRetries.With(prometheus.Labels{"outcome": outcome}).Add(float64(rr.Retries))
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// retryServerErrors retries 5xx and 429 results.
+func retryServerErrors(result cloudevents.Result) bool {
+	var res *cehttp.Result
+	if !errors.As(result, &res) {
+		return true
+	}
+	return res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+}
+
+func TestWithRetryPredicate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status int
+		want   int32
+	}{
+		{"bad request is not retried", http.StatusBadRequest, 1},
+		{"too many requests is retried", http.StatusTooManyRequests, 3},
+		{"internal error is retried", http.StatusInternalServerError, 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			c, err := NewClientHTTP("test", WithRetryPredicate(retryServerErrors), cloudevents.WithTarget(srv.URL))
+			if err != nil {
+				t.Fatalf("NewClientHTTP() = %v", err)
+			}
+			ctx := cloudevents.ContextWithRetriesLinearBackoff(context.Background(), time.Millisecond, 2)
+			if res := c.Send(ctx, testEvents(1)[0]); cloudevents.IsACK(res) {
+				t.Fatalf("Send() = %v, wanted NACK", res)
+			}
+			if got := attempts.Load(); got != tt.want {
+				t.Errorf("attempts = %d, wanted %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendRetriesMetric(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		newClient func(opts ...cehttp.Option) (cloudevents.Client, error)
+	}{{
+		name: "client",
+		newClient: func(opts ...cehttp.Option) (cloudevents.Client, error) {
+			return NewClientHTTP("test", opts...)
+		},
+	}, {
+		name: "batch client",
+		newClient: func(opts ...cehttp.Option) (cloudevents.Client, error) {
+			return NewBatchClientHTTP("test", opts...)
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			mSendRetries.Reset()
+
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			c, err := tt.newClient(cloudevents.WithTarget(srv.URL))
+			if err != nil {
+				t.Fatalf("newClient() = %v", err)
+			}
+			ctx := cloudevents.ContextWithRetriesLinearBackoff(context.Background(), time.Millisecond, 3)
+			if res := c.Send(ctx, testEvents(1)[0]); !cloudevents.IsACK(res) {
+				t.Fatalf("Send() = %v, wanted ACK", res)
+			}
+
+			if got := testutil.ToFloat64(mSendRetries.With(prometheus.Labels{"outcome": "success"})); got != 1 {
+				t.Errorf("success retries = %f, wanted 1", got)
+			}
+			if got := testutil.ToFloat64(mSendRetries.With(prometheus.Labels{"outcome": "failure"})); got != 0 {
+				t.Errorf("failure retries = %f, wanted 0", got)
+			}
+		})
+	}
+}
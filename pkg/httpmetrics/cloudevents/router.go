@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// ErrNoRoute is returned by Router.Send for events whose type matches no
+// route when the router has no default.
+var ErrNoRoute = errors.New("no route for event type")
+
+// Router is a cloudevents.Client that delivers each event to the clients
+// routed for its type, e.g. to send different events to different brokers.
+type Router struct {
+	routes   map[string]cloudevents.Client
+	fallback cloudevents.Client
+}
+
+var _ cloudevents.Client = (*Router)(nil)
+
+// RouterOption configures a Router.
+type RouterOption func(*Router)
+
+// WithDefaultRoute sets the client receiving events whose type matches no
+// route.
+func WithDefaultRoute(c cloudevents.Client) RouterOption {
+	return func(r *Router) {
+		r.fallback = c
+	}
+}
+
+// NewRouter returns a Router delivering events to the clients of routes,
+// which are keyed by event type. Keys may be path.Match patterns, e.g.
+// "dev.chainguard.github.*", and an event matching several of them is
+// delivered to each.
+func NewRouter(routes map[string]cloudevents.Client, opts ...RouterOption) (*Router, error) {
+	for pattern := range routes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid route %q: %w", pattern, err)
+		}
+	}
+	r := &Router{routes: routes}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// clients returns the clients routed for the event type t, keyed by route.
+func (r *Router) clients(t string) map[string]cloudevents.Client {
+	matched := map[string]cloudevents.Client{}
+	for pattern, c := range r.routes {
+		if ok, _ := path.Match(pattern, t); ok {
+			matched[pattern] = c
+		}
+	}
+	if len(matched) == 0 && r.fallback != nil {
+		matched["default"] = r.fallback
+	}
+	return matched
+}
+
+// Send delivers the event to the clients routed for its type concurrently,
+// falling back to the default route. It returns ACK if every delivery was
+// acknowledged, and otherwise the joined results of the failed deliveries.
+func (r *Router) Send(ctx context.Context, event cloudevents.Event) cloudevents.Result {
+	matched := r.clients(event.Type())
+	if len(matched) == 0 {
+		return fmt.Errorf("%w %q", ErrNoRoute, event.Type())
+	}
+
+	var (
+		m    sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for route, client := range matched {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := client.Send(ctx, event); !cloudevents.IsACK(res) {
+				m.Lock()
+				defer m.Unlock()
+				errs = append(errs, fmt.Errorf("route %q: %w", route, res))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return cloudevents.ResultACK
+}
+
+// Request is not supported, as there may be no single response to return.
+func (r *Router) Request(context.Context, cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
+	return nil, errors.New("request is not supported by Router")
+}
+
+// StartReceiver is not supported, Router is for sending only.
+func (r *Router) StartReceiver(context.Context, interface{}) error {
+	return errors.New("receiving is not supported by Router")
+}
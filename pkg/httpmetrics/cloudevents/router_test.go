@@ -0,0 +1,103 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// newTargetClient returns a client sending to a target responding with
+// status, and a count of the events the target received.
+func newTargetClient(t *testing.T, status int) (cloudevents.Client, *atomic.Int32) {
+	t.Helper()
+	srv, count := newTarget(t, status)
+	c, err := NewClientHTTP("test", WithTarget(context.Background(), srv.URL)...)
+	if err != nil {
+		t.Fatalf("NewClientHTTP() = %v", err)
+	}
+	return c, count
+}
+
+func TestRouter(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		eventType string
+		want      [3]int32
+	}{
+		{"exact", "dev.chainguard.github.push", [3]int32{1, 1, 0}},
+		{"glob", "dev.chainguard.github.issues", [3]int32{0, 1, 0}},
+		{"default", "dev.chainguard.test", [3]int32{0, 0, 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			push, pushCount := newTargetClient(t, http.StatusAccepted)
+			github, githubCount := newTargetClient(t, http.StatusAccepted)
+			fallback, fallbackCount := newTargetClient(t, http.StatusAccepted)
+
+			r, err := NewRouter(map[string]cloudevents.Client{
+				"dev.chainguard.github.push": push,
+				"dev.chainguard.github.*":    github,
+			}, WithDefaultRoute(fallback))
+			if err != nil {
+				t.Fatalf("NewRouter() = %v", err)
+			}
+
+			event := testEvents(1)[0]
+			event.SetType(tt.eventType)
+			if res := r.Send(context.Background(), event); !cloudevents.IsACK(res) {
+				t.Errorf("Send() = %v, wanted ACK", res)
+			}
+			if got := [3]int32{pushCount.Load(), githubCount.Load(), fallbackCount.Load()}; got != tt.want {
+				t.Errorf("targets received %v events, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	push, count := newTargetClient(t, http.StatusAccepted)
+	r, err := NewRouter(map[string]cloudevents.Client{
+		"dev.chainguard.github.push": push,
+	})
+	if err != nil {
+		t.Fatalf("NewRouter() = %v", err)
+	}
+
+	if res := r.Send(context.Background(), testEvents(1)[0]); !errors.Is(res, ErrNoRoute) {
+		t.Errorf("Send() = %v, wanted %v", res, ErrNoRoute)
+	}
+	if got := count.Load(); got != 0 {
+		t.Errorf("target received %d events, wanted 0", got)
+	}
+}
+
+func TestRouterFailure(t *testing.T) {
+	ok, _ := newTargetClient(t, http.StatusAccepted)
+	bad, _ := newTargetClient(t, http.StatusBadRequest)
+	r, err := NewRouter(map[string]cloudevents.Client{
+		"dev.chainguard.test": ok,
+		"dev.chainguard.*":    bad,
+	})
+	if err != nil {
+		t.Fatalf("NewRouter() = %v", err)
+	}
+
+	res := r.Send(context.Background(), testEvents(1)[0])
+	if cloudevents.IsACK(res) || !cloudevents.IsNACK(res) {
+		t.Errorf("Send() = %v, wanted NACK", res)
+	}
+}
+
+func TestRouterInvalidPattern(t *testing.T) {
+	if _, err := NewRouter(map[string]cloudevents.Client{"dev.[": nil}); err == nil {
+		t.Error("NewRouter() = nil, wanted error")
+	}
+}
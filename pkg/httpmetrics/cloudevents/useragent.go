@@ -0,0 +1,51 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"runtime/debug"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// WithUserAgent overrides the User-Agent of outbound requests, which
+// defaults to "<name>/<build ID>" for the name passed to NewClientHTTP.
+func WithUserAgent(ua string) cehttp.Option {
+	return func(p *cehttp.Protocol) error {
+		if p == nil {
+			return errors.New("user agent option can not set nil protocol")
+		}
+		if p.RequestTemplate == nil {
+			p.RequestTemplate = &http.Request{Method: http.MethodPost}
+		}
+		if p.RequestTemplate.Header == nil {
+			p.RequestTemplate.Header = http.Header{}
+		}
+		// Unlike cehttp.WithHeader, replace rather than add to any value set
+		// previously, so that this overrides the default.
+		p.RequestTemplate.Header.Set("User-Agent", ua)
+		return nil
+	}
+}
+
+// buildID identifies the running build: the Cloud Run revision if set,
+// otherwise the VCS revision stamped into the binary.
+func buildID() string {
+	if rev := os.Getenv("K_REVISION"); rev != "" {
+		return rev
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && s.Value != "" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+func TestUserAgent(t *testing.T) {
+	t.Setenv("K_REVISION", "trampoline-00042-abc")
+
+	for _, tt := range []struct {
+		name string
+		opts []cehttp.Option
+		want string
+	}{
+		{"default", nil, "trampoline/trampoline-00042-abc"},
+		{"override", []cehttp.Option{WithUserAgent("custom/1.0")}, "custom/1.0"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Values("User-Agent")
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			c, err := NewClientHTTP("trampoline", append(tt.opts, cloudevents.WithTarget(srv.URL))...)
+			if err != nil {
+				t.Fatalf("NewClientHTTP() = %v", err)
+			}
+			if res := c.Send(context.Background(), testEvents(1)[0]); !cloudevents.IsACK(res) {
+				t.Fatalf("Send() = %v", res)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("User-Agent = %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	mGRPCClientCalls = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_client_calls_total",
+			Help: "The total number of completed gRPC client calls, by status code",
+		},
+		[]string{"grpc_method", "grpc_code"},
+	)
+	mGRPCClientDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grpc_client_handling_seconds",
+			Help:    "The duration of gRPC client calls until the server's response is received",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"grpc_method", "grpc_code"},
+	)
+)
+
+// UnaryClientInterceptor records metrics for unary gRPC client calls, by full
+// method name and status code. It can be combined with other interceptors
+// using grpc.WithChainUnaryInterceptor.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observeGRPCClientCall(method, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor records metrics for streaming gRPC client calls, by
+// full method name and status code, timed until the stream ends. It can be
+// combined with other interceptors using grpc.WithChainStreamInterceptor.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			observeGRPCClientCall(method, start, err)
+			return nil, err
+		}
+		return &monitoredClientStream{ClientStream: s, method: method, start: start}, nil
+	}
+}
+
+// monitoredClientStream records the metrics of a stream once RecvMsg reports
+// its end, either io.EOF or an error.
+type monitoredClientStream struct {
+	grpc.ClientStream
+
+	method string
+	start  time.Time
+	once   sync.Once
+}
+
+func (s *monitoredClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		return nil
+	}
+	s.once.Do(func() {
+		if errors.Is(err, io.EOF) {
+			observeGRPCClientCall(s.method, s.start, nil)
+		} else {
+			observeGRPCClientCall(s.method, s.start, err)
+		}
+	})
+	return err
+}
+
+func observeGRPCClientCall(method string, start time.Time, err error) {
+	labels := prometheus.Labels{
+		"grpc_method": method,
+		"grpc_code":   status.Code(err).String(),
+	}
+	mGRPCClientDuration.With(labels).Observe(time.Since(start).Seconds())
+	mGRPCClientCalls.With(labels).Inc()
+}
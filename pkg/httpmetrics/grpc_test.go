@@ -0,0 +1,142 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// streamDesc describes a server-streaming method that sends a single message,
+// or fails if the request names a service.
+var streamDesc = grpc.StreamDesc{
+	StreamName:    "Stream",
+	ServerStreams: true,
+	Handler: func(_ any, stream grpc.ServerStream) error {
+		var req healthpb.HealthCheckRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		if req.Service != "" {
+			return status.Error(codes.Unavailable, "unavailable")
+		}
+		return stream.SendMsg(&healthpb.HealthCheckResponse{})
+	},
+}
+
+func newGRPCTestClient(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Streamer",
+		HandlerType: (*any)(nil),
+		Streams:     []grpc.StreamDesc{streamDesc},
+	}, struct{}{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	client := healthpb.NewHealthClient(newGRPCTestClient(t))
+	const method = "/grpc.health.v1.Health/Check"
+
+	for _, tt := range []struct {
+		service string
+		code    codes.Code
+	}{
+		{"", codes.OK},
+		{"unknown", codes.NotFound},
+	} {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			before := testutil.ToFloat64(mGRPCClientCalls.WithLabelValues(method, tt.code.String()))
+			beforeSeries := testutil.CollectAndCount(mGRPCClientDuration)
+
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: tt.service})
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("Check() = %v, wanted %v", err, tt.code)
+			}
+
+			if got := testutil.ToFloat64(mGRPCClientCalls.WithLabelValues(method, tt.code.String())) - before; got != 1 {
+				t.Errorf("call count increased by %f, wanted 1", got)
+			}
+			if got := testutil.CollectAndCount(mGRPCClientDuration) - beforeSeries; got != 1 {
+				t.Errorf("duration series increased by %d, wanted 1", got)
+			}
+		})
+	}
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	cc := newGRPCTestClient(t)
+	const method = "/test.Streamer/Stream"
+
+	for _, tt := range []struct {
+		service string
+		code    codes.Code
+	}{
+		{"", codes.OK},
+		{"failing", codes.Unavailable},
+	} {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			before := testutil.ToFloat64(mGRPCClientCalls.WithLabelValues(method, tt.code.String()))
+
+			stream, err := cc.NewStream(context.Background(), &streamDesc, method)
+			if err != nil {
+				t.Fatalf("NewStream() = %v", err)
+			}
+			if err := stream.SendMsg(&healthpb.HealthCheckRequest{Service: tt.service}); err != nil {
+				t.Fatalf("SendMsg() = %v", err)
+			}
+			if err := stream.CloseSend(); err != nil {
+				t.Fatalf("CloseSend() = %v", err)
+			}
+			for {
+				err = stream.RecvMsg(&healthpb.HealthCheckResponse{})
+				if err != nil {
+					break
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("RecvMsg() = %v, wanted %v", err, tt.code)
+			}
+
+			if got := testutil.ToFloat64(mGRPCClientCalls.WithLabelValues(method, tt.code.String())) - before; got != 1 {
+				t.Errorf("call count increased by %f, wanted 1", got)
+			}
+		})
+	}
+}
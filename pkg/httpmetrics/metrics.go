@@ -3,7 +3,6 @@ package httpmetrics
 import (
 	"context"
 	"fmt"
-	"log"
 	"log/slog"
 	"net/http"
 	"os"
@@ -31,21 +30,23 @@ import (
 )
 
 // ServeMetrics serves the metrics endpoint if the METRICS_PORT env var is set.
+// If ENABLE_PPROF is true, the runtime profiles are served under /debug/pprof/
+// too, as by net/http/pprof, on the metrics port, which isn't publicly
+// exposed.
 func ServeMetrics() {
 	// Start the metrics server on the metrics port, if defined.
 	var env struct {
-		MetricsPort int `envconfig:"METRICS_PORT" default:"2112" required:"true"`
+		MetricsPort int  `envconfig:"METRICS_PORT" default:"2112" required:"true"`
+		EnablePprof bool `envconfig:"ENABLE_PPROF"`
 	}
 	if err := envconfig.Process("", &env); err != nil {
 		slog.Error("Failed to process environment variables", "error", err)
 		return
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", env.MetricsPort),
-		Handler:           mux,
+		Handler:           metricsMux(env.EnablePprof),
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
@@ -54,6 +55,18 @@ func ServeMetrics() {
 	}
 }
 
+// metricsMux returns the handler of the metrics server, which serves the
+// pprof endpoints if enablePprof is set.
+func metricsMux(enablePprof bool) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", servePprof)
+		mux.HandleFunc("/debug/pprof/profile", serveCPUProfile)
+	}
+	return mux
+}
+
 var (
 	inFlightGauge = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -205,16 +218,47 @@ func HandlerFunc(name string, f func(http.ResponseWriter, *http.Request)) http.H
 // Expected usage:
 //
 //	defer metrics.SetupTracer(ctx)()
+//
+// Failures to set up tracing are logged, and the process runs without it.
+// Use SetupTracerE to handle them instead.
 func SetupTracer(ctx context.Context) func() {
+	shutdown, err := SetupTracerE(ctx)
+	if err != nil {
+		slog.Error("Error setting up tracer provider, tracing is disabled", "error", err)
+		return func() {}
+	}
+	return func() {
+		if err := shutdown(context.Background()); err != nil {
+			slog.Error("Error shutting down tracer provider", "error", err)
+		}
+	}
+}
+
+// SetupTracerE is like SetupTracer, but returns errors constructing the
+// exporter or provider, e.g. for a misconfigured OTLP endpoint, so that the
+// caller can decide whether to fail fast. On error the global tracer
+// provider is left unchanged.
+//
+// Expected usage:
+//
+//	shutdown, err := metrics.SetupTracerE(ctx)
+//	if err != nil {
+//		log.Fatalf("setting up tracing: %v", err)
+//	}
+//	defer shutdown(context.Background())
+func SetupTracerE(ctx context.Context) (shutdown func(context.Context) error, err error) {
 	traceEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
 	projectID, _ := metadata.ProjectID()
 	var options []trace.TracerProviderOption
 	if traceEndpoint == "" && projectID != "" {
 		// No trace endpoint provided and we are on GCP.
-		options = tracerOptionsGCP(ctx)
+		options, err = tracerOptionsGCP(ctx)
 	} else {
 		// We are either on KinD or GKE.
-		options = tracerOptions(ctx)
+		options, err = tracerOptions(ctx)
+	}
+	if err != nil {
+		return nil, err
 	}
 	tp := trace.NewTracerProvider(options...)
 	otel.SetTracerProvider(tp)
@@ -225,22 +269,28 @@ func SetupTracer(ctx context.Context) func() {
 	)
 	otel.SetTextMapPropagator(prp)
 
-	return func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			slog.Error("Error shutting down tracer provider", "error", err)
-		}
-	}
+	return tp.Shutdown, nil
 }
 
-func tracerOptionsGCP(ctx context.Context) []trace.TracerProviderOption {
+// The exporter constructors, which tests replace.
+var (
+	newGCPExporter = func(context.Context) (trace.SpanExporter, error) {
+		return texporter.New(
+			// Avoid infinite recursion in trace uploads
+			//   https://github.com/open-telemetry/opentelemetry-go/issues/1928
+			texporter.WithTraceClientOptions([]option.ClientOption{option.WithTelemetryDisabled()}),
+		)
+	}
+	newOTLPExporter = func(ctx context.Context) (trace.SpanExporter, error) {
+		return otlptracehttp.New(ctx)
+	}
+)
+
+func tracerOptionsGCP(ctx context.Context) ([]trace.TracerProviderOption, error) {
 	// Else, we upload directly to Cloud Trace.
-	traceExporter, err := texporter.New(
-		// Avoid infinite recursion in trace uploads
-		//   https://github.com/open-telemetry/opentelemetry-go/issues/1928
-		texporter.WithTraceClientOptions([]option.ClientOption{option.WithTelemetryDisabled()}),
-	)
+	traceExporter, err := newGCPExporter(ctx)
 	if err != nil {
-		log.Panicf("tracerOptionsGCP(); texporter.New() = %v", err)
+		return nil, fmt.Errorf("creating Cloud Trace exporter: %w", err)
 	}
 	res, err := resource.New(ctx,
 		// Use the GCP resource detector to detect information about the GCP platform
@@ -249,7 +299,7 @@ func tracerOptionsGCP(ctx context.Context) []trace.TracerProviderOption {
 		resource.WithTelemetrySDK(),
 	)
 	if err != nil {
-		log.Panicf("tracerOptionsGCP(); resource.New() = %v", err)
+		return nil, fmt.Errorf("detecting GCP resource: %w", err)
 	}
 	bsp := trace.NewBatchSpanProcessor(traceExporter)
 	return []trace.TracerProviderOption{
@@ -258,13 +308,13 @@ func tracerOptionsGCP(ctx context.Context) []trace.TracerProviderOption {
 		// On Cloud Run, this gives fuller traces. We can tune this down
 		// in the future if cost becomes an issue.
 		trace.WithSampler(trace.AlwaysSample()),
-	}
+	}, nil
 }
 
-func tracerOptions(ctx context.Context) []trace.TracerProviderOption {
-	traceExporter, err := otlptracehttp.New(ctx)
+func tracerOptions(ctx context.Context) ([]trace.TracerProviderOption, error) {
+	traceExporter, err := newOTLPExporter(ctx)
 	if err != nil {
-		log.Panicf("traceOptions() = %v", err)
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
 	}
 	bsp := trace.NewBatchSpanProcessor(traceExporter)
 	res := resource.Default()
@@ -272,25 +322,45 @@ func tracerOptions(ctx context.Context) []trace.TracerProviderOption {
 	return []trace.TracerProviderOption{
 		trace.WithResource(res),
 		trace.WithSpanProcessor(bsp),
-	}
+	}, nil
 }
 
 type delegator struct {
 	http.ResponseWriter
-	Status int
+	Status  int
+	Written int64
+
+	wroteHeader bool
+}
+
+// wrapDelegator returns w if it is already a delegator, so that middlewares
+// can share a single wrapper, and wraps it otherwise.
+func wrapDelegator(w http.ResponseWriter) *delegator {
+	if d, ok := w.(*delegator); ok {
+		return d
+	}
+	return &delegator{
+		ResponseWriter: w,
+		Status:         200,
+	}
 }
 
 func (d *delegator) WriteHeader(status int) {
 	d.Status = status
+	d.wroteHeader = true
 	d.ResponseWriter.WriteHeader(status)
 }
 
+func (d *delegator) Write(b []byte) (int, error) {
+	d.wroteHeader = true
+	n, err := d.ResponseWriter.Write(b)
+	d.Written += int64(n)
+	return n, err
+}
+
 func instrumentHandlerCounter(counter *prometheus.CounterVec, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		d := &delegator{
-			ResponseWriter: w,
-			Status:         200,
-		}
+		d := wrapDelegator(w)
 
 		next.ServeHTTP(d, r)
 		counter.With(prometheus.Labels{
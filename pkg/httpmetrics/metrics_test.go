@@ -1,12 +1,16 @@
 package httpmetrics
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestServerMetrics(t *testing.T) {
@@ -34,6 +38,83 @@ func TestServerMetrics(t *testing.T) {
 	}
 }
 
+func TestMetricsMuxPprof(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		enable bool
+		want   int
+	}{
+		{"enabled", true, http.StatusOK},
+		{"disabled", false, http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			metricsMux(tt.enable).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+
+			rec = httptest.NewRecorder()
+			metricsMux(tt.enable).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap", nil))
+			if rec.Code != tt.want {
+				t.Errorf("heap profile status = %d, wanted %d", rec.Code, tt.want)
+			}
+
+			rec = httptest.NewRecorder()
+			metricsMux(tt.enable).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("/metrics status = %d, wanted %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestPprofNotOnDefaultServeMux(t *testing.T) {
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)); strings.HasPrefix(pattern, "/debug/pprof") {
+		t.Errorf("http.DefaultServeMux serves %s, wanted pprof only on the metrics server", pattern)
+	}
+}
+
+func TestServerMetricsHandlerLabel(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", Handler("webhook", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	mux.Handle("/replay", Handler("replay", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, path := range []string{"/webhook", "/webhook", "/replay"} {
+		resp, err := srv.Client().Post(srv.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	for _, c := range []struct {
+		handler, code string
+		want          float64
+	}{
+		{"webhook", "200", 2},
+		{"replay", "202", 1},
+	} {
+		if got := testutil.ToFloat64(counter.With(prometheus.Labels{
+			"handler":       c.handler,
+			"method":        http.MethodPost,
+			"code":          c.code,
+			"service_name":  env.KnativeServiceName,
+			"revision_name": env.KnativeRevisionName,
+			"ce_type":       "",
+			"email":         "unknown",
+		})); got != c.want {
+			t.Errorf("%s: want metric count = %f, got %f", c.handler, c.want, got)
+		}
+	}
+}
+
 func TestBucketize(t *testing.T) {
 	SetBuckets(map[string]string{
 		"api.github.com":                       "GH API",
@@ -81,3 +162,25 @@ func TestBucketize(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupTracerE(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://localhost:4318/v1/traces")
+
+	errExporter := errors.New("bad exporter")
+	orig := newOTLPExporter
+	t.Cleanup(func() { newOTLPExporter = orig })
+	newOTLPExporter = func(context.Context) (trace.SpanExporter, error) {
+		return nil, errExporter
+	}
+
+	shutdown, err := SetupTracerE(context.Background())
+	if !errors.Is(err, errExporter) {
+		t.Errorf("SetupTracerE() = %v, wanted %v", err, errExporter)
+	}
+	if shutdown != nil {
+		t.Error("SetupTracerE() returned a shutdown function on error")
+	}
+
+	// SetupTracer logs the error and returns a no-op.
+	SetupTracer(context.Background())()
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var mPanics = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_handler_panics_total",
+		Help: "The number of panics recovered from HTTP handlers",
+	},
+	[]string{"handler", "service_name", "revision_name"},
+)
+
+// Recover wraps handler so that panics are logged with their stack, counted,
+// and answered with a 500 if no response was written yet. Panics with
+// http.ErrAbortHandler are re-raised, so that the server still aborts the
+// response as documented.
+func Recover(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := wrapDelegator(w)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+
+			clog.FromContext(r.Context()).Errorf("panic in handler %s: %v\n%s", name, p, debug.Stack())
+			mPanics.With(prometheus.Labels{
+				"handler":       name,
+				"service_name":  env.KnativeServiceName,
+				"revision_name": env.KnativeRevisionName,
+			}).Inc()
+
+			if !d.wroteHeader {
+				http.Error(d, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		handler.ServeHTTP(d, r)
+	})
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecover(t *testing.T) {
+	const name = "test-recover"
+	h := Recover(name, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := testutil.ToFloat64(mPanics.MustCurryWith(prometheus.Labels{"handler": name})); got != 1 {
+		t.Errorf("want panic count = 1, got %f", got)
+	}
+}
+
+func TestRecoverAfterWrite(t *testing.T) {
+	h := Recover("test-recover-written", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// The status was already sent, so it can't be changed.
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusAccepted)
+	}
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	h := Recover("test-recover-abort", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recover() = %v, wanted %v", p, http.ErrAbortHandler)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("ServeHTTP did not re-panic")
+}
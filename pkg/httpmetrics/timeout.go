@@ -0,0 +1,118 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var mTimeouts = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_handler_timeouts_total",
+		Help: "The number of requests answered with a 503 because the handler didn't finish in time",
+	},
+	[]string{"service_name", "revision_name"},
+)
+
+// WithTimeout wraps h so that requests it doesn't finish within d are
+// answered with a 503 and counted. The request context of h is canceled at
+// the deadline, so that it can stop its work, and its writes after that fail
+// with http.ErrHandlerTimeout.
+//
+// Like http.TimeoutHandler, the response of h is buffered until it returns,
+// so this isn't suitable for streaming handlers. Panics in h are re-raised
+// on the calling goroutine, so that Recover and Handler can wrap WithTimeout,
+// e.g. Handler(name, Recover(name, WithTimeout(d, h))).
+func WithTimeout(d time.Duration, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			h.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.m.Lock()
+			defer tw.m.Unlock()
+			for k, v := range tw.header {
+				w.Header()[k] = v
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			if _, err := w.Write(tw.buf.Bytes()); err != nil {
+				clog.FromContext(r.Context()).Warnf("failed to write response: %v", err)
+			}
+		case <-ctx.Done():
+			tw.m.Lock()
+			defer tw.m.Unlock()
+			tw.timedOut = true
+			// If the client went away instead, there is no one to answer.
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				mTimeouts.With(prometheus.Labels{
+					"service_name":  env.KnativeServiceName,
+					"revision_name": env.KnativeRevisionName,
+				}).Inc()
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+		}
+	})
+}
+
+// timeoutWriter buffers the response of a handler wrapped by WithTimeout.
+type timeoutWriter struct {
+	m        sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.m.Lock()
+	defer tw.m.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.m.Lock()
+	defer tw.m.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httpmetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithTimeout(t *testing.T) {
+	mTimeouts.Reset()
+	canceled := make(chan error, 1)
+	h := WithTimeout(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		canceled <- r.Context().Err()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := testutil.ToFloat64(mTimeouts); got != 1 {
+		t.Errorf("timeout count = %f, wanted 1", got)
+	}
+	if err := <-canceled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("handler context error = %v, wanted %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWithTimeoutFast(t *testing.T) {
+	h := WithTimeout(time.Minute, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("done"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusAccepted)
+	}
+	if got := rec.Header().Get("X-Test"); got != "yes" {
+		t.Errorf("X-Test = %q, wanted %q", got, "yes")
+	}
+	if got := rec.Body.String(); got != "done" {
+		t.Errorf("body = %q, wanted %q", got, "done")
+	}
+}
+
+func TestWithTimeoutRecover(t *testing.T) {
+	h := Recover("test-timeout-recover", WithTimeout(time.Minute, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, wanted %d", rec.Code, http.StatusInternalServerError)
+	}
+}
@@ -64,7 +64,10 @@ type MetricsTransport struct {
 	inner http.RoundTripper
 }
 
-// WrapTransport wraps an http.RoundTripper with instrumentation.
+// WrapTransport wraps an http.RoundTripper with instrumentation, e.g. the
+// transport of a GitHub client. Request durations are recorded in
+// http_client_request_duration_seconds by status code and host, where hosts
+// are bucketed per SetBuckets and SetBucketSuffixes to bound cardinality.
 func WrapTransport(t http.RoundTripper) http.RoundTripper {
 	return &MetricsTransport{
 		RoundTripper: useGoogClientTraceparent(
@@ -3,10 +3,12 @@ package httpmetrics
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func TestTransport(t *testing.T) {
@@ -33,6 +35,53 @@ func TestTransport(t *testing.T) {
 	}
 }
 
+func TestTransportDuration(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bucket the test server's host:port, to show that the label is the
+	// bucket rather than the URL.
+	SetBuckets(map[string]string{u.Host: "test-server"})
+	defer SetBuckets(map[string]string{})
+
+	labels := prometheus.Labels{
+		"code":          "418",
+		"method":        http.MethodGet,
+		"host":          "test-server",
+		"service_name":  env.KnativeServiceName,
+		"revision_name": env.KnativeRevisionName,
+		"ce_type":       "",
+	}
+	before := testutil.CollectAndCount(mReqDuration)
+
+	resp, err := (&http.Client{Transport: WrapTransport(http.DefaultTransport)}).Get(s.URL + "/some/path?q=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := testutil.CollectAndCount(mReqDuration) - before; got != 1 {
+		t.Errorf("want 1 new duration series, got %d", got)
+	}
+	h, ok := mReqDuration.With(labels).(prometheus.Histogram)
+	if !ok {
+		t.Fatalf("want a histogram, got %T", mReqDuration.With(labels))
+	}
+	m := &dto.Metric{}
+	if err := h.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 1 {
+		t.Errorf("want 1 observation, got %d", got)
+	}
+}
+
 func TestExtractInnerTransport(t *testing.T) {
 	t.Run("not wrapped", func(t *testing.T) {
 		tr := &http.Transport{}
@@ -6,6 +6,7 @@ SPDX-License-Identifier: Apache-2.0
 package profiler
 
 import (
+	"context"
 	"os"
 
 	"cloud.google.com/go/profiler"
@@ -30,3 +31,43 @@ func SetupProfiler() {
 		}
 	}
 }
+
+// start starts the profiling agent, and is replaced by tests.
+var start = profiler.Start
+
+// Setup starts the Cloud Profiler agent if the ENABLE_PROFILER env var is
+// true, so that CPU and heap profiles are collected continuously. The service
+// and version name the profiles, and default to the K_SERVICE and K_REVISION
+// env vars of Cloud Run.
+//
+// Expected usage, like httpmetrics.SetupTracer:
+//
+//	defer profiler.Setup(ctx, "my-service", "")()
+//
+// Failures to start the agent are logged, and the process runs without it.
+// The agent can't be stopped, so the returned func does nothing, and is only
+// there for symmetry with SetupTracer.
+func Setup(ctx context.Context, service, version string) func() {
+	log := clog.FromContext(ctx)
+
+	var env envConfig
+	if err := envconfig.Process("", &env); err != nil {
+		log.Errorf("failed to process env var, profiling is disabled: %v", err)
+		return func() {}
+	}
+	if !env.EnableProfiler {
+		log.Debug("ENABLE_PROFILER is not set, profiling is disabled")
+		return func() {}
+	}
+
+	if service == "" {
+		service = os.Getenv("K_SERVICE")
+	}
+	if version == "" {
+		version = os.Getenv("K_REVISION")
+	}
+	if err := start(profiler.Config{Service: service, ServiceVersion: version}); err != nil {
+		log.Errorf("failed to start profiler, profiling is disabled: %v", err)
+	}
+	return func() {}
+}
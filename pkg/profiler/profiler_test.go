@@ -0,0 +1,50 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package profiler
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/profiler"
+	"google.golang.org/api/option"
+)
+
+func TestSetup(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		enabled string
+		want    *profiler.Config
+	}{
+		{"disabled", "false", nil},
+		{"enabled", "true", &profiler.Config{Service: "trampoline", ServiceVersion: "trampoline-00001"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENABLE_PROFILER", tt.enabled)
+			t.Setenv("K_REVISION", "trampoline-00001")
+
+			var got *profiler.Config
+			start = func(cfg profiler.Config, _ ...option.ClientOption) error {
+				got = &cfg
+				return nil
+			}
+			t.Cleanup(func() { start = profiler.Start })
+
+			shutdown := Setup(context.Background(), "trampoline", "")
+			if shutdown == nil {
+				t.Fatal("Setup() = nil, wanted a func")
+			}
+			shutdown()
+
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("profiler started with %+v, wanted it disabled", *got)
+			case tt.want != nil && (got == nil || got.Service != tt.want.Service || got.ServiceVersion != tt.want.ServiceVersion):
+				t.Errorf("profiler started with %+v, wanted %+v", got, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/chainguard-dev/clog"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// PushEnvelope is the body of a Pub/Sub push subscription request.
+// https://cloud.google.com/pubsub/docs/push#receive_push
+type PushEnvelope struct {
+	Message struct {
+		Attributes map[string]string `json:"attributes"`
+		// Data is base64-encoded on the wire, which encoding/json decodes
+		// into []byte.
+		Data        []byte    `json:"data"`
+		MessageID   string    `json:"messageId"`
+		PublishTime time.Time `json:"publishTime"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// ToCloudEvent is the inverse of FromCloudEvent: it reconstructs the
+// CloudEvent carried in the attributes and data of msg.
+func ToCloudEvent(msg *pubsub.Message) (cloudevents.Event, error) {
+	event := cloudevents.NewEvent()
+	for k, v := range msg.Attributes {
+		switch k {
+		case "ce-id":
+			event.SetID(v)
+		case "ce-specversion":
+			event.SetSpecVersion(v)
+		case "ce-type":
+			event.SetType(v)
+		case "ce-source":
+			event.SetSource(v)
+		case "ce-subject":
+			if v != "" {
+				event.SetSubject(v)
+			}
+		case "ce-time":
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return event, fmt.Errorf("parsing ce-time: %w", err)
+			}
+			event.SetTime(t)
+		case "content-type":
+		default:
+			if name, ok := strings.CutPrefix(k, "ce-"); ok {
+				if err := event.Context.SetExtension(name, v); err != nil {
+					return event, fmt.Errorf("setting extension %q: %w", name, err)
+				}
+			}
+		}
+	}
+	if err := event.SetData(msg.Attributes["content-type"], msg.Data); err != nil {
+		return event, fmt.Errorf("setting data: %w", err)
+	}
+	return event, event.Validate()
+}
+
+// DecodePush decodes a Pub/Sub push request body into the CloudEvent it
+// carries.
+func DecodePush(r io.Reader) (cloudevents.Event, error) {
+	var env PushEnvelope
+	if err := json.NewDecoder(r).Decode(&env); err != nil {
+		return cloudevents.Event{}, fmt.Errorf("decoding push envelope: %w", err)
+	}
+	return ToCloudEvent(&pubsub.Message{
+		ID:          env.Message.MessageID,
+		Attributes:  env.Message.Attributes,
+		Data:        env.Message.Data,
+		PublishTime: env.Message.PublishTime,
+	})
+}
+
+// PushHandler adapts a CloudEvents receiver, e.g. a bot's Receive method, to
+// be driven by a Pub/Sub push subscription. Malformed messages are rejected
+// with a 400, which Pub/Sub retries until they are dead-lettered, and
+// receiver errors with a 500 so that the message is redelivered.
+func PushHandler(fn func(context.Context, cloudevents.Event) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		event, err := DecodePush(r.Body)
+		if err != nil {
+			clog.WarnContextf(ctx, "rejecting push message: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := fn(ctx, event); err != nil {
+			clog.ErrorContextf(ctx, "failed to handle event %s: %v", event.ID(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
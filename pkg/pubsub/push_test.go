@@ -0,0 +1,115 @@
+/*
+Copyright 2024 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+// envelope is a push request body carrying `{"foo":"bar"}`.
+const envelope = `{
+	"message": {
+		"attributes": {
+			"ce-id": "id",
+			"ce-specversion": "1.0",
+			"ce-type": "dev.chainguard.github.pull_request",
+			"ce-source": "github.com",
+			"ce-subject": "org/repo",
+			"ce-time": "1973-11-29T21:33:09Z",
+			"ce-headsha": "abc123",
+			"content-type": "application/json"
+		},
+		"data": "eyJmb28iOiJiYXIifQ==",
+		"messageId": "123",
+		"publishTime": "2024-01-01T00:00:00Z"
+	},
+	"subscription": "projects/p/subscriptions/s"
+}`
+
+func TestDecodePush(t *testing.T) {
+	got, err := DecodePush(strings.NewReader(envelope))
+	if err != nil {
+		t.Fatalf("DecodePush() = %v", err)
+	}
+
+	want := cloudevents.NewEvent()
+	want.SetID("id")
+	want.SetType("dev.chainguard.github.pull_request")
+	want.SetSource("github.com")
+	want.SetSubject("org/repo")
+	want.SetTime(time.Unix(123456789, 0).UTC())
+	want.SetExtension("headsha", "abc123")
+	if err := want.SetData(cloudevents.ApplicationJSON, []byte(`{"foo":"bar"}`)); err != nil {
+		t.Fatalf("SetData() = %v", err)
+	}
+
+	if diff := cmp.Diff(want.String(), got.String()); diff != "" {
+		t.Errorf("DecodePush() (-want +got): %s", diff)
+	}
+}
+
+func TestToCloudEventRoundTrip(t *testing.T) {
+	in := cloudevents.NewEvent()
+	in.SetID("id")
+	in.SetType("type")
+	in.SetSource("source")
+	in.SetTime(time.Unix(123456789, 0).UTC())
+	in.SetExtension("foo", "bar")
+	if err := in.SetData(cloudevents.ApplicationJSON, map[string]any{"baz": 3}); err != nil {
+		t.Fatalf("SetData() = %v", err)
+	}
+
+	out, err := ToCloudEvent(FromCloudEvent(context.Background(), in))
+	if err != nil {
+		t.Fatalf("ToCloudEvent() = %v", err)
+	}
+	if diff := cmp.Diff(in.Context, out.Context); diff != "" {
+		t.Errorf("ToCloudEvent(FromCloudEvent()) context (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff(string(in.Data()), string(out.Data())); diff != "" {
+		t.Errorf("ToCloudEvent(FromCloudEvent()) data (-want +got): %s", diff)
+	}
+}
+
+func TestPushHandler(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"delivered", envelope, nil, http.StatusNoContent},
+		{"receiver error", envelope, errors.New("boom"), http.StatusInternalServerError},
+		{"malformed envelope", `{"message":`, nil, http.StatusBadRequest},
+		{"missing attributes", `{"message":{"data":"e30="}}`, nil, http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []cloudevents.Event
+			h := PushHandler(func(_ context.Context, event cloudevents.Event) error {
+				got = append(got, event)
+				return tt.err
+			})
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, wanted %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusBadRequest && len(got) != 1 {
+				t.Errorf("received %d events, wanted 1", len(got))
+			}
+		})
+	}
+}